@@ -68,6 +68,9 @@ type BenchmarkResult struct {
 	AvgCPUUsage     float64 `json:"avg_cpu_usage"`    // Average CPU usage (if available)
 	AvgMemoryUsage  int64   `json:"avg_memory_usage"` // Average memory usage (if available)
 	
+	// Server metrics over the run (if the pool reports server status)
+	ServerRates  *StatusRates   `json:"server_rates,omitempty"`
+	
 	// Error details
 	Errors       []BenchmarkError `json:"errors,omitempty"`
 	
@@ -200,6 +203,12 @@ type BenchmarkRunner struct {
 	config  BenchmarkConfig
 	metrics *BenchmarkMetrics
 	pool    DatabasePool
+	sampler *ServerStatusSampler
+}
+
+// serverStatusSource is implemented by pools that report server status
+type serverStatusSource interface {
+	ServerStatus(ctx context.Context) (*ServerStatus, error)
 }
 
 // NewBenchmarkRunner creates a new benchmark runner
@@ -211,6 +220,31 @@ func NewBenchmarkRunner(config BenchmarkConfig, pool DatabasePool) *BenchmarkRun
 	}
 }
 
+// SetServerStatusSampler takes the server status snapshots around each
+// test with sampler, so they also appear in its buffer. Without a sampler,
+// snapshots are read from the pool when it supports them.
+func (r *BenchmarkRunner) SetServerStatusSampler(sampler *ServerStatusSampler) {
+	r.sampler = sampler
+}
+
+// serverStatus takes a server status snapshot, or returns nil when
+// metrics are disabled or the server status is unavailable
+func (r *BenchmarkRunner) serverStatus(ctx context.Context) *ServerStatus {
+	if !r.config.CollectMetrics {
+		return nil
+	}
+	if r.sampler != nil {
+		status, _ := r.sampler.Sample(ctx)
+		return status
+	}
+	src, ok := r.pool.(serverStatusSource)
+	if !ok {
+		return nil
+	}
+	status, _ := src.ServerStatus(ctx)
+	return status
+}
+
 // RunBenchmark executes a benchmark test
 func (r *BenchmarkRunner) RunBenchmark(ctx context.Context, test BenchmarkTest) (*BenchmarkResult, error) {
 	// Setup test environment
@@ -280,6 +314,7 @@ func (r *BenchmarkRunner) runWarmup(ctx context.Context, test BenchmarkTest) err
 
 // runTest executes the main benchmark test
 func (r *BenchmarkRunner) runTest(ctx context.Context, test BenchmarkTest) (*BenchmarkResult, error) {
+	statusBefore := r.serverStatus(ctx)
 	startTime := time.Now()
 
 	// Create test context
@@ -323,7 +358,13 @@ func (r *BenchmarkRunner) runTest(ctx context.Context, test BenchmarkTest) (*Ben
 	endTime := time.Now()
 
 	// Generate result
-	return r.generateResult(test.Name(), startTime, endTime), nil
+	result := r.generateResult(test.Name(), startTime, endTime)
+	if statusBefore != nil {
+		if statusAfter := r.serverStatus(ctx); statusAfter != nil {
+			result.ServerRates, _ = StatusDiff(statusBefore, statusAfter)
+		}
+	}
+	return result, nil
 }
 
 // runWorker executes benchmark operations for a single worker
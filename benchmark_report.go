@@ -212,6 +212,10 @@ func (g *BenchmarkReportGenerator) WriteTextReport(w io.Writer) error {
 		fmt.Fprintf(w, "   Percentiles: P50=%v, P95=%v, P99=%v\n", 
 			result.P50Latency, result.P95Latency, result.P99Latency)
 		fmt.Fprintf(w, "   Peak Connections: %d\n", result.PeakConnections)
+		if sr := result.ServerRates; sr != nil {
+			fmt.Fprintf(w, "   Server: %.2f questions/sec, %.2f rows read/sec, %d threads running\n",
+				sr.Questions, sr.InnodbRowsRead, sr.ThreadsRunning)
+		}
 		
 		if len(result.Errors) > 0 {
 			fmt.Fprintf(w, "   Errors:\n")
@@ -1,13 +1,18 @@
 package ygggo_mysql
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"net/url"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
 )
 
 // PoolConfig holds connection pool-related settings.
@@ -107,6 +112,24 @@ type Config struct {
 	// Can be overridden with YGGGO_MYSQL_HOST environment variable.
 	Host string
 
+	// Hosts lists the servers to use for multi-host failover.
+	//
+	// Entries are "host" or "host:port"; entries without a port use Port
+	// (or 3306). When non-empty, Hosts takes precedence over Host and the
+	// pool switches to the next reachable server on connection failures.
+	// If DSN is set, its address is replaced by each host in turn.
+	// A comma-separated YGGGO_MYSQL_HOST fills this field.
+	//
+	// Example: []string{"db-1:3306", "db-2:3306", "db-3:3306"}
+	Hosts []string
+
+	// FailoverPolicy controls the order in which Hosts are tried.
+	//
+	// One of FailoverOrdered (default), FailoverRandom or
+	// FailoverPreferWritable.
+	// Can be overridden with YGGGO_MYSQL_FAILOVER_POLICY environment variable.
+	FailoverPolicy FailoverPolicy
+
 	// Port is the database server port number.
 	//
 	// Used for DSN construction when DSN field is empty.
@@ -126,6 +149,18 @@ type Config struct {
 	// Can be overridden with YGGGO_MYSQL_PASSWORD environment variable.
 	Password string
 
+	// Credentials supplies the user name and password for each new
+	// connection, enabling secret rotation without restarting the pool.
+	//
+	// When set, it overrides Username and Password (and those embedded in
+	// DSN) at connect time. Combine with Pool.ConnMaxLifetime so that
+	// connections opened with old credentials are recycled.
+	// Requires the "mysql" driver.
+	//
+	// Example:
+	//	Credentials: NewFileCredentialProvider("/run/secrets/db-password", "app", 0)
+	Credentials CredentialProvider
+
 	// Database is the name of the database to connect to.
 	//
 	// Used for DSN construction when DSN field is empty.
@@ -163,54 +198,105 @@ type Config struct {
 	// Slow queries are logged and can be recorded for analysis.
 	// If 0, slow query detection is disabled.
 	// Default is 0 (disabled).
+	// Logging.SlowQueryThreshold overrides it when both are set.
 	//
 	// Example: 100 * time.Millisecond
 	SlowQueryThreshold time.Duration
+
+	// TLS configures transport encryption.
+	//
+	// It applies when the DSN is built from fields; a raw DSN is used as
+	// is. An explicit "tls" entry in Params takes precedence. If nil, no
+	// "tls" parameter is added.
+	TLS *TLSConfig
+
+	// Health overrides the configuration used by Pool.HealthCheck.
+	//
+	// If Health.MonitoringEnabled is true, NewPool also starts background
+	// health monitoring. If nil, DefaultHealthCheckConfig is used.
+	Health *HealthCheckConfig
+
+	// Probe enables a ConnectionProbe that NewPool starts for the pool.
+	//
+	// The probe is available through Pool.Probe and stopped by Close.
+	// If nil, no probe is started.
+	Probe *ProbeConfig
+
+	// Redaction controls how query arguments, query text and secrets are
+	// masked in logs, slow query records, probe events and errors.
+	// If nil, DefaultRedactionConfig is used.
+	Redaction *RedactionConfig
+
+	// SessionInit lists statements run on every new physical connection
+	// before it is handed out, for example:
+	//
+	//	SessionInit: []string{
+	//		"SET time_zone = '+00:00'",
+	//		"SET SESSION sql_mode = 'STRICT_ALL_TABLES,NO_ZERO_DATE'",
+	//		"SET SESSION transaction_isolation = 'READ-COMMITTED'",
+	//		"SET NAMES utf8mb4 COLLATE utf8mb4_0900_ai_ci",
+	//	}
+	//
+	// A failing statement fails the connection attempt.
+	SessionInit []string
+
+	// ResetSession discards connections that ran a session-changing
+	// statement while borrowed, instead of returning them to the idle
+	// pool, so the change cannot leak to the next borrower. Replacement
+	// connections run SessionInit again. Only SET, USE, CREATE TEMPORARY
+	// TABLE, LOCK TABLES and user variable assignments (@x := ...) are
+	// detected; other session state, such as PREPARE or HANDLER, is not.
+	ResetSession bool
+
+	// QueryTagKeys limits the context query tags (see WithQueryTags) that
+	// are appended to statements. If empty, all tags are appended.
+	QueryTagKeys []string
+
+	// Guard enables the query guard (see Pool.SetGuard), which rejects
+	// unsafe statements with a *GuardViolation. If nil, no guard is used.
+	Guard *GuardConfig
+
+	// StatementStats enables in-process statistics for every statement
+	// (see Pool.StatementStats). If nil, no statistics are collected.
+	StatementStats *StatementStatsConfig
+
+	// Logging configures structured logging (see Pool.ConfigureLogging).
+	// If nil, logging stays off until EnableLogging is called.
+	Logging *LoggingConfig
+
+	// KillOnCancel stops statements on the server when their context is
+	// canceled. The driver only closes the client connection, leaving the
+	// statement running and holding its locks; with KillOnCancel the
+	// connection ID of every connection is recorded and KILL QUERY is sent
+	// from a separate management connection. Interrupted statements are
+	// logged and recorded by the slow query recorder.
+	KillOnCancel bool
+
+	// KillOnCancelRate limits the KILL QUERY statements sent per second.
+	// Defaults to 10.
+	KillOnCancelRate float64
+
+	// DisableMaxExecutionTimeFromDeadline stops adding a MAX_EXECUTION_TIME
+	// hint for the time left before the context deadline to SELECT
+	// statements. By default the hint is added, so the server stops them
+	// when the client gives up; statements run by Conn.QueryCached never
+	// get it (see WithMaxExecutionTime).
+	DisableMaxExecutionTimeFromDeadline bool
+
+	// CaptureDeadlocks reads the deadlock report from SHOW ENGINE INNODB
+	// STATUS when a transaction run by WithinTx is rolled back by a
+	// deadlock (error 1213). The report is logged under LogTransactions
+	// and stored by the slow query recorder, one record per transaction.
+	// The report is read in the background, so retries are not delayed.
+	// It requires the PROCESS privilege.
+	CaptureDeadlocks bool
 }
 
 // applyEnv overrides config with env vars (prefix YGGGO_MYSQL_*) when present.
-func applyEnv(c *Config) {
-	lookup := func(key string) (string, bool) { v, ok := os.LookupEnv(key); return v, ok }
-	if v, ok := lookup("YGGGO_MYSQL_DRIVER"); ok {
-		c.Driver = v
-	}
-	if v, ok := lookup("YGGGO_MYSQL_DSN"); ok {
-		c.DSN = v
-	}
-	if v, ok := lookup("YGGGO_MYSQL_HOST"); ok {
-		c.Host = v
-	}
-	if v, ok := lookup("YGGGO_MYSQL_PORT"); ok {
-		if p, err := strconv.Atoi(v); err == nil {
-			c.Port = p
-		}
-	}
-	if v, ok := lookup("YGGGO_MYSQL_USERNAME"); ok {
-		c.Username = v
-	}
-	if v, ok := lookup("YGGGO_MYSQL_PASSWORD"); ok {
-		c.Password = v
-	}
-	if v, ok := lookup("YGGGO_MYSQL_DATABASE"); ok {
-		c.Database = v
-	}
-	if v, ok := lookup("YGGGO_MYSQL_PARAMS"); ok {
-		// parse "k=v&k2=v2" into map
-		m := map[string]string{}
-		for _, pair := range strings.Split(v, "&") {
-			if pair == "" {
-				continue
-			}
-			kv := strings.SplitN(pair, "=", 2)
-			k := kv[0]
-			val := ""
-			if len(kv) == 2 {
-				val = kv[1]
-			}
-			m[k] = val
-		}
-		c.Params = m
-	}
+// Every key understood by LoadConfig has an env counterpart. Invalid values
+// are reported, naming the variable, after all valid ones are applied.
+func applyEnv(c *Config) error {
+	return applyEnvPrefix(c, envPrefix)
 }
 
 // dsnFromConfig returns a DSN string.
@@ -225,18 +311,32 @@ func dsnFromConfig(c Config) (string, error) {
 		addr = fmt.Sprintf("%s:%d", c.Host, c.Port)
 	}
 	dbEscaped := url.PathEscape(c.Database)
+	params := c.Params
+	if _, ok := params["tls"]; !ok && c.TLS != nil {
+		v, err := tlsParam(c.TLS)
+		if err != nil {
+			return "", err
+		}
+		if v != "" {
+			params = make(map[string]string, len(c.Params)+1)
+			for k, pv := range c.Params {
+				params[k] = pv
+			}
+			params["tls"] = v
+		}
+	}
 	// Build query params in stable order for test determinism
 	var q string
-	if len(c.Params) > 0 {
-		keys := make([]string, 0, len(c.Params))
-		for k := range c.Params {
+	if len(params) > 0 {
+		keys := make([]string, 0, len(params))
+		for k := range params {
 			keys = append(keys, k)
 		}
 		sort.Strings(keys)
 		parts := make([]string, 0, len(keys))
 		for _, k := range keys {
 			// mysql driver recognizes plain keys like parseTime=true
-			parts = append(parts, fmt.Sprintf("%s=%s", k, url.QueryEscape(c.Params[k])))
+			parts = append(parts, fmt.Sprintf("%s=%s", k, url.QueryEscape(params[k])))
 		}
 		q = strings.Join(parts, "&")
 	}
@@ -255,3 +355,80 @@ func dsnFromConfig(c Config) (string, error) {
 	}
 	return dsn, nil
 }
+
+// tlsParam returns the DSN "tls" value for t, registering a custom
+// *tls.Config with the mysql driver when certificates are involved.
+//
+// Modes follow MySQL's ssl-mode: "disabled", "preferred", "required"
+// (encrypt without verification), "verify-ca" (verify the chain only) and
+// "verify-identity" (verify chain and host name).
+func tlsParam(t *TLSConfig) (string, error) {
+	switch t.Mode {
+	case "", "disabled", "preferred", "required", "verify-ca", "verify-identity":
+	default:
+		return "", fmt.Errorf("invalid TLS mode: %s", t.Mode)
+	}
+	if t.Mode == "disabled" {
+		return "false", nil
+	}
+	if t.CertFile == "" && t.CAFile == "" && t.ServerName == "" {
+		// Built-in driver settings cover these without a custom config
+		switch {
+		case t.Mode == "":
+			return "", nil
+		case t.Mode == "preferred":
+			return "preferred", nil
+		case t.Mode == "required" || t.InsecureSkipVerify:
+			return "skip-verify", nil
+		case t.Mode == "verify-identity":
+			return "true", nil
+		}
+	}
+
+	tc := &tls.Config{ServerName: t.ServerName, InsecureSkipVerify: t.InsecureSkipVerify}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read TLS CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return "", errors.New("TLS CA file contains no certificates")
+		}
+		tc.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	switch t.Mode {
+	case "", "preferred", "required":
+		tc.InsecureSkipVerify = true
+	case "verify-ca":
+		// Verify the chain ourselves and skip the host name check
+		tc.InsecureSkipVerify = true
+		roots := tc.RootCAs
+		tc.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+			for _, c := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(c)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%+v", *t)
+	name := fmt.Sprintf("ygggo-%x", h.Sum64())
+	if err := mysql.RegisterTLSConfig(name, tc); err != nil {
+		return "", err
+	}
+	return name, nil
+}
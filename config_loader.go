@@ -0,0 +1,695 @@
+package ygggo_mysql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	mysql "github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of all configuration environment variables.
+const envPrefix = "YGGGO_MYSQL_"
+
+// configKey describes one setting that can be provided by a configuration
+// file (dotted path, e.g. "pool.max_open") or an environment variable
+// (prefix plus upper-cased path, e.g. YGGGO_MYSQL_POOL_MAX_OPEN).
+type configKey struct {
+	path string
+	set  func(c *Config, v string) error
+}
+
+// envName returns the environment variable name of k for prefix.
+func (k configKey) envName(prefix string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(k.path, ".", "_"))
+}
+
+// configKeys lists every setting understood by LoadConfig and applyEnv.
+var configKeys = []configKey{
+	{"driver", func(c *Config, v string) error { c.Driver = v; return nil }},
+	{"dsn", func(c *Config, v string) error { c.DSN = v; return nil }},
+	{"host", setHost},
+	{"hosts", func(c *Config, v string) error { c.Hosts = splitList(v); return nil }},
+	{"port", func(c *Config, v string) error { return parseIntInto(v, &c.Port) }},
+	{"username", func(c *Config, v string) error { c.Username = v; return nil }},
+	{"password", func(c *Config, v string) error { c.Password = v; return nil }},
+	{"database", func(c *Config, v string) error { c.Database = v; return nil }},
+	{"params", func(c *Config, v string) error { c.Params = parseParams(v); return nil }},
+	{"failover_policy", func(c *Config, v string) error { c.FailoverPolicy = FailoverPolicy(v); return nil }},
+	{"slow_query_threshold", func(c *Config, v string) error { return parseDurationInto(v, &c.SlowQueryThreshold) }},
+	{"session_init", func(c *Config, v string) error { c.SessionInit = splitStatements(v); return nil }},
+	{"reset_session", func(c *Config, v string) error { return parseBoolInto(v, &c.ResetSession) }},
+	{"kill_on_cancel", func(c *Config, v string) error { return parseBoolInto(v, &c.KillOnCancel) }},
+	{"kill_on_cancel_rate", func(c *Config, v string) error { return parseFloatInto(v, &c.KillOnCancelRate) }},
+	{"disable_max_execution_time_from_deadline", func(c *Config, v string) error { return parseBoolInto(v, &c.DisableMaxExecutionTimeFromDeadline) }},
+	{"capture_deadlocks", func(c *Config, v string) error { return parseBoolInto(v, &c.CaptureDeadlocks) }},
+	{"query_tag_keys", func(c *Config, v string) error { c.QueryTagKeys = splitList(v); return nil }},
+
+	{"pool.max_open", func(c *Config, v string) error { return parseIntInto(v, &c.Pool.MaxOpen) }},
+	{"pool.max_idle", func(c *Config, v string) error { return parseIntInto(v, &c.Pool.MaxIdle) }},
+	{"pool.conn_max_lifetime", func(c *Config, v string) error { return parseDurationInto(v, &c.Pool.ConnMaxLifetime) }},
+	{"pool.conn_max_idle_time", func(c *Config, v string) error { return parseDurationInto(v, &c.Pool.ConnMaxIdleTime) }},
+
+	{"retry.max_attempts", func(c *Config, v string) error { return parseIntInto(v, &c.Retry.MaxAttempts) }},
+	{"retry.base_backoff", func(c *Config, v string) error { return parseDurationInto(v, &c.Retry.BaseBackoff) }},
+	{"retry.max_backoff", func(c *Config, v string) error { return parseDurationInto(v, &c.Retry.MaxBackoff) }},
+	{"retry.jitter", func(c *Config, v string) error { return parseBoolInto(v, &c.Retry.Jitter) }},
+	{"retry.max_elapsed", func(c *Config, v string) error { return parseDurationInto(v, &c.Retry.MaxElapsed) }},
+
+	{"tls.mode", func(c *Config, v string) error { tlsOf(c).Mode = v; return nil }},
+	{"tls.cert_file", func(c *Config, v string) error { tlsOf(c).CertFile = v; return nil }},
+	{"tls.key_file", func(c *Config, v string) error { tlsOf(c).KeyFile = v; return nil }},
+	{"tls.ca_file", func(c *Config, v string) error { tlsOf(c).CAFile = v; return nil }},
+	{"tls.server_name", func(c *Config, v string) error { tlsOf(c).ServerName = v; return nil }},
+	{"tls.insecure_skip_verify", func(c *Config, v string) error { return parseBoolInto(v, &tlsOf(c).InsecureSkipVerify) }},
+
+	{"health.timeout", func(c *Config, v string) error { return parseDurationInto(v, &healthOf(c).Timeout) }},
+	{"health.retry_attempts", func(c *Config, v string) error { return parseIntInto(v, &healthOf(c).RetryAttempts) }},
+	{"health.retry_backoff", func(c *Config, v string) error { return parseDurationInto(v, &healthOf(c).RetryBackoff) }},
+	{"health.query_timeout", func(c *Config, v string) error { return parseDurationInto(v, &healthOf(c).QueryTimeout) }},
+	{"health.test_query", func(c *Config, v string) error { healthOf(c).TestQuery = v; return nil }},
+	{"health.monitoring_enabled", func(c *Config, v string) error { return parseBoolInto(v, &healthOf(c).MonitoringEnabled) }},
+	{"health.monitoring_interval", func(c *Config, v string) error { return parseDurationInto(v, &healthOf(c).MonitoringInterval) }},
+	{"health.expect_writable", func(c *Config, v string) error { return parseBoolInto(v, &healthOf(c).ExpectWritable) }},
+	{"health.max_replication_lag", func(c *Config, v string) error { return parseDurationInto(v, &healthOf(c).MaxReplicationLag) }},
+	{"health.max_threads_connected_ratio", func(c *Config, v string) error { return parseFloatInto(v, &healthOf(c).MaxThreadsConnectedRatio) }},
+	{"health.max_aborted_connects_rate", func(c *Config, v string) error { return parseFloatInto(v, &healthOf(c).MaxAbortedConnectsRate) }},
+	{"health.min_buffer_pool_hit_ratio", func(c *Config, v string) error { return parseFloatInto(v, &healthOf(c).MinBufferPoolHitRatio) }},
+
+	{"probe.interval", func(c *Config, v string) error { return parseDurationInto(v, &probeOf(c).Interval) }},
+	{"probe.timeout", func(c *Config, v string) error { return parseDurationInto(v, &probeOf(c).Timeout) }},
+	{"probe.failure_threshold", func(c *Config, v string) error { return parseIntInto(v, &probeOf(c).FailureThreshold) }},
+	{"probe.success_threshold", func(c *Config, v string) error { return parseIntInto(v, &probeOf(c).SuccessThreshold) }},
+	{"probe.enable_auto_reconnect", func(c *Config, v string) error { return parseBoolInto(v, &probeOf(c).EnableAutoReconnect) }},
+	{"probe.reconnect.max_attempts", func(c *Config, v string) error { return parseIntInto(v, &probeOf(c).ReconnectPolicy.MaxAttempts) }},
+	{"probe.reconnect.initial_backoff", func(c *Config, v string) error {
+		return parseDurationInto(v, &probeOf(c).ReconnectPolicy.InitialBackoff)
+	}},
+	{"probe.reconnect.max_backoff", func(c *Config, v string) error { return parseDurationInto(v, &probeOf(c).ReconnectPolicy.MaxBackoff) }},
+	{"probe.reconnect.backoff_multiplier", func(c *Config, v string) error {
+		return parseFloatInto(v, &probeOf(c).ReconnectPolicy.BackoffMultiplier)
+	}},
+	{"probe.reconnect.jitter", func(c *Config, v string) error { return parseBoolInto(v, &probeOf(c).ReconnectPolicy.Jitter) }},
+	{"probe.reconnect.max_elapsed", func(c *Config, v string) error { return parseDurationInto(v, &probeOf(c).ReconnectPolicy.MaxElapsed) }},
+	{"probe.reconnect.rebuild_pool", func(c *Config, v string) error { return parseBoolInto(v, &probeOf(c).ReconnectPolicy.RebuildPool) }},
+	{"probe.event_buffer", func(c *Config, v string) error { return parseIntInto(v, &probeOf(c).EventBuffer) }},
+
+	{"guard.require_where", func(c *Config, v string) error { return parseBoolInto(v, &guardOf(c).RequireWhere) }},
+	{"guard.reject_tautologies", func(c *Config, v string) error { return parseBoolInto(v, &guardOf(c).RejectTautologies) }},
+	{"guard.limit_tables", func(c *Config, v string) error { guardOf(c).LimitTables = splitList(v); return nil }},
+	{"guard.block_ddl", func(c *Config, v string) error { return parseBoolInto(v, &guardOf(c).BlockDDL) }},
+	{"guard.block_multi_statements", func(c *Config, v string) error {
+		return parseBoolInto(v, &guardOf(c).BlockMultiStatements)
+	}},
+
+	{"statement_stats.enabled", func(c *Config, v string) error {
+		var on bool
+		if err := parseBoolInto(v, &on); err != nil {
+			return err
+		}
+		if !on {
+			c.StatementStats = nil
+		} else {
+			stmtStatsOf(c)
+		}
+		return nil
+	}},
+	{"statement_stats.max_statements", func(c *Config, v string) error { return parseIntInto(v, &stmtStatsOf(c).MaxStatements) }},
+	{"statement_stats.normalization_mode", func(c *Config, v string) error { stmtStatsOf(c).NormalizationMode = v; return nil }},
+
+	{"logging.enabled", func(c *Config, v string) error { return parseBoolInto(v, &loggingOf(c).Enabled) }},
+	{"logging.level", func(c *Config, v string) error {
+		if err := loggingOf(c).Level.UnmarshalText([]byte(strings.TrimSpace(v))); err != nil {
+			return fmt.Errorf("invalid log level %q", v)
+		}
+		return nil
+	}},
+	{"logging.slow_query_threshold", func(c *Config, v string) error {
+		return parseDurationInto(v, &loggingOf(c).SlowQueryThreshold)
+	}},
+	{"logging.sample_rate", func(c *Config, v string) error { return parseIntInto(v, &loggingOf(c).SampleRate) }},
+	{"logging.max_query_length", func(c *Config, v string) error { return parseIntInto(v, &loggingOf(c).MaxQueryLength) }},
+	{"logging.args", func(c *Config, v string) error { loggingOf(c).Args = ArgLogMode(v); return nil }},
+	{"logging.operations", func(c *Config, v string) error { return parseLogOperationsInto(v, &loggingOf(c).Operations) }},
+
+	{"redaction.args", func(c *Config, v string) error { redactionOf(c).Args = ArgPolicy(v); return nil }},
+	{"redaction.column_pattern", func(c *Config, v string) error { redactionOf(c).ColumnPattern = v; return nil }},
+	{"redaction.types", func(c *Config, v string) error { redactionOf(c).Types = splitList(v); return nil }},
+	{"redaction.hash_key", func(c *Config, v string) error { redactionOf(c).HashKey = v; return nil }},
+	{"redaction.mask_literals", func(c *Config, v string) error { return parseBoolInto(v, &redactionOf(c).MaskLiterals) }},
+}
+
+// listKeys are the keys that may also be given as lists, with the
+// separator used for their string form.
+var listKeys = map[string]string{
+	"hosts":              ",",
+	"redaction.types":    ",",
+	"session_init":       ";",
+	"query_tag_keys":     ",",
+	"guard.limit_tables": ",",
+	"logging.operations": ",",
+}
+
+// configKeyIndex maps a dotted path to its configKey.
+var configKeyIndex = func() map[string]configKey {
+	m := make(map[string]configKey, len(configKeys))
+	for _, k := range configKeys {
+		m[k.path] = k
+	}
+	return m
+}()
+
+// setHost sets Host, or Hosts when v is a comma-separated list.
+func setHost(c *Config, v string) error {
+	if !strings.Contains(v, ",") {
+		c.Host = v
+		return nil
+	}
+	// comma-separated list enables multi-host failover
+	c.Hosts = splitList(v)
+	if len(c.Hosts) > 0 {
+		c.Host = c.Hosts[0]
+	}
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// parseParams parses "k=v&k2=v2" into a map.
+func parseParams(v string) map[string]string {
+	m := map[string]string{}
+	for _, pair := range strings.Split(v, "&") {
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		val := ""
+		if len(kv) == 2 {
+			val = kv[1]
+		}
+		m[kv[0]] = val
+	}
+	return m
+}
+
+func parseIntInto(v string, dst *int) error {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", v)
+	}
+	*dst = n
+	return nil
+}
+
+func parseBoolInto(v string, dst *bool) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", v)
+	}
+	*dst = b
+	return nil
+}
+
+func parseFloatInto(v string, dst *float64) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", v)
+	}
+	*dst = f
+	return nil
+}
+
+// parseDurationInto parses Go duration syntax such as "250ms" or "5m".
+func parseDurationInto(v string, dst *time.Duration) error {
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", v)
+	}
+	*dst = d
+	return nil
+}
+
+// splitStatements splits a ';'-separated list of SQL statements,
+// dropping empty entries.
+func splitStatements(v string) []string {
+	var out []string
+	for _, stmt := range strings.Split(v, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+// tlsOf returns c.TLS, allocating it on first use.
+func tlsOf(c *Config) *TLSConfig {
+	if c.TLS == nil {
+		c.TLS = &TLSConfig{}
+	}
+	return c.TLS
+}
+
+// healthOf returns c.Health, starting from DefaultHealthCheckConfig.
+func healthOf(c *Config) *HealthCheckConfig {
+	if c.Health == nil {
+		h := DefaultHealthCheckConfig()
+		c.Health = &h
+	}
+	return c.Health
+}
+
+// probeOf returns c.Probe, starting from DefaultProbeConfig.
+func probeOf(c *Config) *ProbeConfig {
+	if c.Probe == nil {
+		p := DefaultProbeConfig()
+		c.Probe = &p
+	}
+	return c.Probe
+}
+
+// guardOf returns c.Guard, starting from DefaultGuardConfig.
+func guardOf(c *Config) *GuardConfig {
+	if c.Guard == nil {
+		g := DefaultGuardConfig()
+		c.Guard = &g
+	}
+	return c.Guard
+}
+
+// stmtStatsOf returns c.StatementStats, starting from
+// DefaultStatementStatsConfig.
+func stmtStatsOf(c *Config) *StatementStatsConfig {
+	if c.StatementStats == nil {
+		s := DefaultStatementStatsConfig()
+		c.StatementStats = &s
+	}
+	return c.StatementStats
+}
+
+// loggingOf returns c.Logging, allocating it on first use.
+func loggingOf(c *Config) *LoggingConfig {
+	if c.Logging == nil {
+		c.Logging = &LoggingConfig{}
+	}
+	return c.Logging
+}
+
+// logOperationNames maps the names accepted by logging.operations.
+var logOperationNames = map[string]LogOperation{
+	"query":      LogQueries,
+	"exec":       LogExecs,
+	"tx":         LogTransactions,
+	"connection": LogConnections,
+	"pool_stats": LogPoolStats,
+	"all":        LogAllOperations,
+}
+
+// parseLogOperationsInto parses a comma-separated list of operation names
+// such as "query,exec,tx".
+func parseLogOperationsInto(v string, dst *LogOperation) error {
+	var ops LogOperation
+	for _, name := range splitList(v) {
+		op, ok := logOperationNames[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("unknown log operation %q", name)
+		}
+		ops |= op
+	}
+	*dst = ops
+	return nil
+}
+
+// redactionOf returns c.Redaction, starting from DefaultRedactionConfig.
+func redactionOf(c *Config) *RedactionConfig {
+	if c.Redaction == nil {
+		r := DefaultRedactionConfig()
+		c.Redaction = &r
+	}
+	return c.Redaction
+}
+
+// applyEnvPrefix overrides c with every environment variable named
+// prefix+KEY that is present. Invalid values leave the field unchanged
+// and are reported together.
+func applyEnvPrefix(c *Config, prefix string) error {
+	var errs []error
+	for _, k := range configKeys {
+		name := k.envName(prefix)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := k.set(c, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadConfig reads a configuration file and returns the resulting Config.
+//
+// The format is chosen by extension: .yaml/.yml, .json or .toml. Keys use
+// snake_case and nest by section, mirroring the environment variables:
+//
+//	host: db.internal
+//	username: app
+//	password: ${DB_PASSWORD}
+//	database: orders
+//	pool:
+//	  max_open: 50
+//	  conn_max_lifetime: 30m
+//	retry:
+//	  max_attempts: 3
+//	tls:
+//	  mode: verify-identity
+//	  ca_file: /etc/ssl/mysql-ca.pem
+//
+// String values may reference environment variables as ${NAME} or
+// ${NAME:-default}. Durations use Go syntax ("250ms", "5m").
+//
+// After the file is applied, YGGGO_MYSQL_* environment variables override
+// it (for example YGGGO_MYSQL_POOL_MAX_OPEN or YGGGO_MYSQL_RETRY_MAX_ATTEMPTS).
+// The result is checked with ValidateConfig. All problems found - unknown
+// keys, bad values, failed validation - are returned together; the Config
+// is returned as well so callers can inspect it.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+	m, err := decodeConfigFile(data, filepath.Ext(path))
+	if err != nil {
+		return Config{}, err
+	}
+	var cfg Config
+	errs := applyConfigMap(&cfg, "", m)
+	if err := applyEnvPrefix(&cfg, envPrefix); err != nil {
+		errs = append(errs, err)
+	}
+	if err := ValidateConfig(cfg); err != nil {
+		errs = append(errs, err)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// decodeConfigFile decodes data into a generic map according to ext.
+func decodeConfigFile(data []byte, ext string) (map[string]any, error) {
+	m := map[string]any{}
+	var err error
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &m)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&m)
+	case ".toml":
+		_, err = toml.Decode(string(data), &m)
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return m, nil
+}
+
+// applyConfigMap applies a decoded configuration section to c and returns
+// every error encountered.
+func applyConfigMap(c *Config, prefix string, m map[string]any) []error {
+	var errs []error
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := strings.ToLower(k)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		switch v := m[k].(type) {
+		case map[string]any:
+			if path == "params" {
+				if c.Params == nil {
+					c.Params = map[string]string{}
+				}
+				for pk, pv := range v {
+					s, err := configString(pv)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("%s.%s: %w", path, pk, err))
+						continue
+					}
+					c.Params[pk] = s
+				}
+				continue
+			}
+			errs = append(errs, applyConfigMap(c, path, v)...)
+		case []any:
+			key, ok := configKeyIndex[path]
+			sep, isList := listKeys[path]
+			if !ok || !isList {
+				errs = append(errs, fmt.Errorf("%s: lists are not supported", path))
+				continue
+			}
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s, err := configString(item)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", path, err))
+					continue
+				}
+				items = append(items, s)
+			}
+			if err := key.set(c, strings.Join(items, sep)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			}
+		default:
+			key, ok := configKeyIndex[path]
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown config key %q", path))
+				continue
+			}
+			s, err := configString(v)
+			if err == nil {
+				err = key.set(c, s)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			}
+		}
+	}
+	return errs
+}
+
+// envRef matches ${NAME} and ${NAME:-default}.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// configString converts a decoded scalar to its string form, expanding
+// environment references in strings.
+func configString(v any) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return interpolateEnv(t)
+	case bool:
+		return strconv.FormatBool(t), nil
+	case int:
+		return strconv.Itoa(t), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case uint64:
+		return strconv.FormatUint(t, 10), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case json.Number:
+		return t.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", v)
+	}
+}
+
+// interpolateEnv expands ${NAME} and ${NAME:-default} references in s.
+func interpolateEnv(s string) (string, error) {
+	var missing []string
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		sub := envRef.FindStringSubmatch(ref)
+		if v, ok := os.LookupEnv(sub[1]); ok {
+			return v
+		}
+		if sub[2] != "" {
+			return sub[3]
+		}
+		missing = append(missing, sub[1])
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined environment variable %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// ValidateConfig checks cfg for invalid or inconsistent settings and
+// returns all problems found, joined with errors.Join.
+//
+// Pool limits are checked with ValidatePoolConfig when any are set, and
+// field-based connection settings (including TLS) with DSNBuilder.Validate.
+func ValidateConfig(cfg Config) error {
+	var errs []error
+	add := func(section string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", section, err))
+		}
+	}
+
+	switch cfg.FailoverPolicy {
+	case "", FailoverOrdered, FailoverRandom, FailoverPreferWritable:
+	default:
+		add("failover_policy", fmt.Errorf("unknown failover policy %q", cfg.FailoverPolicy))
+	}
+
+	if pc := cfg.Pool; pc != (PoolConfig{}) {
+		// NewPool leaves zero fields unset, so an unset MaxOpen (no limit)
+		// is valid with any MaxIdle
+		if pc.MaxOpen == 0 {
+			pc.MaxOpen = max(pc.MaxIdle, 1)
+		}
+		add("pool", ValidatePoolConfig(pc))
+	}
+
+	if cfg.Retry.MaxAttempts < 0 {
+		add("retry", fmt.Errorf("max attempts must be non-negative, got %d", cfg.Retry.MaxAttempts))
+	}
+	if cfg.Retry.BaseBackoff < 0 || cfg.Retry.MaxBackoff < 0 || cfg.Retry.MaxElapsed < 0 {
+		add("retry", errors.New("backoff durations must be non-negative"))
+	}
+	if cfg.Retry.BaseBackoff > 0 && cfg.Retry.MaxBackoff > 0 && cfg.Retry.BaseBackoff > cfg.Retry.MaxBackoff {
+		add("retry", errors.New("base backoff cannot be greater than max backoff"))
+	}
+
+	if cfg.SlowQueryThreshold < 0 {
+		add("slow_query_threshold", fmt.Errorf("must be non-negative, got %v", cfg.SlowQueryThreshold))
+	}
+
+	if cfg.KillOnCancelRate < 0 {
+		add("kill_on_cancel_rate", fmt.Errorf("must be non-negative, got %v", cfg.KillOnCancelRate))
+	}
+
+	if cfg.Driver == "" || cfg.Driver == "mysql" {
+		add("connection", validateConnection(cfg))
+	}
+
+	if h := cfg.Health; h != nil {
+		if h.Timeout <= 0 {
+			add("health", fmt.Errorf("timeout must be positive, got %v", h.Timeout))
+		}
+		if h.QueryTimeout <= 0 {
+			add("health", fmt.Errorf("query timeout must be positive, got %v", h.QueryTimeout))
+		}
+		if h.RetryAttempts < 0 {
+			add("health", fmt.Errorf("retry attempts must be non-negative, got %d", h.RetryAttempts))
+		}
+		if h.MonitoringEnabled && h.MonitoringInterval <= 0 {
+			add("health", fmt.Errorf("monitoring interval must be positive, got %v", h.MonitoringInterval))
+		}
+		if h.MaxReplicationLag < 0 || h.MaxAbortedConnectsRate < 0 {
+			add("health", errors.New("server check thresholds must be non-negative"))
+		}
+		if h.MaxThreadsConnectedRatio < 0 || h.MaxThreadsConnectedRatio > 1 {
+			add("health", fmt.Errorf("max threads connected ratio must be between 0 and 1, got %v", h.MaxThreadsConnectedRatio))
+		}
+		if h.MinBufferPoolHitRatio < 0 || h.MinBufferPoolHitRatio > 1 {
+			add("health", fmt.Errorf("min buffer pool hit ratio must be between 0 and 1, got %v", h.MinBufferPoolHitRatio))
+		}
+	}
+
+	if cfg.Probe != nil {
+		add("probe", ValidateProbeConfig(*cfg.Probe))
+	}
+
+	if cfg.Redaction != nil {
+		_, err := NewRedactor(*cfg.Redaction)
+		add("redaction", err)
+	}
+
+	if cfg.Logging != nil {
+		add("logging", ValidateLoggingConfig(*cfg.Logging))
+	}
+
+	if s := cfg.StatementStats; s != nil {
+		if s.MaxStatements < 0 {
+			add("statement_stats.max_statements", fmt.Errorf("must be non-negative, got %d", s.MaxStatements))
+		}
+		switch s.NormalizationMode {
+		case "", NormalizeNone, NormalizeBasic, NormalizeFingerprint:
+		default:
+			add("statement_stats.normalization_mode", fmt.Errorf("unknown mode %q", s.NormalizationMode))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateConnection checks the DSN or the field-based connection settings.
+func validateConnection(cfg Config) error {
+	var errs []error
+	if cfg.TLS != nil {
+		b := NewDSNBuilder().TLSWithConfig(cfg.TLS)
+		if err := b.validateTLSConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("TLS configuration error: %w", err))
+		}
+	}
+
+	if strings.TrimSpace(cfg.DSN) != "" {
+		if _, err := mysql.ParseDSN(cfg.DSN); err != nil {
+			errs = append(errs, fmt.Errorf("invalid DSN: %w", err))
+		}
+		return errors.Join(errs...)
+	}
+
+	hosts := cfg.Hosts
+	if len(hosts) == 0 {
+		hosts = []string{cfg.Host}
+	}
+	for _, h := range hosts {
+		c := cfg
+		c.TLS = nil
+		c.Host = h
+		if host, port, err := net.SplitHostPort(h); err == nil {
+			c.Host = host
+			if c.Port, err = strconv.Atoi(port); err != nil {
+				errs = append(errs, fmt.Errorf("host %q: invalid port", h))
+				continue
+			}
+		}
+		if err := FromConfig(c).Validate(); err != nil {
+			if len(cfg.Hosts) > 0 {
+				err = fmt.Errorf("host %q: %w", h, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
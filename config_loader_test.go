@@ -0,0 +1,268 @@
+package ygggo_mysql
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkLoadedConfig(t *testing.T, cfg Config) {
+	t.Helper()
+	if cfg.Host != "db.internal" || cfg.Port != 3307 || cfg.Username != "app" {
+		t.Fatalf("connection fields: %+v", cfg)
+	}
+	if cfg.Password != "from-env" {
+		t.Fatalf("password not interpolated: %q", cfg.Password)
+	}
+	if cfg.Database != "orders" {
+		t.Fatalf("database=%q", cfg.Database)
+	}
+	if cfg.Params["charset"] != "utf8mb4" {
+		t.Fatalf("params=%v", cfg.Params)
+	}
+	if cfg.Pool.MaxOpen != 50 || cfg.Pool.MaxIdle != 10 || cfg.Pool.ConnMaxLifetime != 30*time.Minute {
+		t.Fatalf("pool=%+v", cfg.Pool)
+	}
+	if cfg.Retry.MaxAttempts != 3 || cfg.Retry.BaseBackoff != 50*time.Millisecond || !cfg.Retry.Jitter {
+		t.Fatalf("retry=%+v", cfg.Retry)
+	}
+	if cfg.SlowQueryThreshold != 200*time.Millisecond {
+		t.Fatalf("slow query threshold=%v", cfg.SlowQueryThreshold)
+	}
+	if cfg.Probe == nil || cfg.Probe.Interval != 10*time.Second || cfg.Probe.ReconnectPolicy.MaxAttempts != 7 {
+		t.Fatalf("probe=%+v", cfg.Probe)
+	}
+	// unspecified probe fields keep their defaults
+	if cfg.Probe.FailureThreshold != DefaultProbeConfig().FailureThreshold {
+		t.Fatalf("probe defaults lost: %+v", cfg.Probe)
+	}
+}
+
+func TestLoadConfig_YAML(t *testing.T) {
+	t.Setenv("TEST_DB_PASSWORD", "from-env")
+	path := writeConfigFile(t, "db.yaml", `
+host: db.internal
+port: 3307
+username: app
+password: ${TEST_DB_PASSWORD}
+database: ${TEST_DB_NAME:-orders}
+params:
+  charset: utf8mb4
+pool:
+  max_open: 50
+  max_idle: 10
+  conn_max_lifetime: 30m
+retry:
+  max_attempts: 3
+  base_backoff: 50ms
+  jitter: true
+slow_query_threshold: 200ms
+probe:
+  interval: 10s
+  reconnect:
+    max_attempts: 7
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	checkLoadedConfig(t, cfg)
+}
+
+func TestLoadConfig_JSON(t *testing.T) {
+	t.Setenv("TEST_DB_PASSWORD", "from-env")
+	path := writeConfigFile(t, "db.json", `{
+  "host": "db.internal",
+  "port": 3307,
+  "username": "app",
+  "password": "${TEST_DB_PASSWORD}",
+  "database": "orders",
+  "params": {"charset": "utf8mb4"},
+  "pool": {"max_open": 50, "max_idle": 10, "conn_max_lifetime": "30m"},
+  "retry": {"max_attempts": 3, "base_backoff": "50ms", "jitter": true},
+  "slow_query_threshold": "200ms",
+  "probe": {"interval": "10s", "reconnect": {"max_attempts": 7}}
+}`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	checkLoadedConfig(t, cfg)
+}
+
+func TestLoadConfig_TOML(t *testing.T) {
+	t.Setenv("TEST_DB_PASSWORD", "from-env")
+	path := writeConfigFile(t, "db.toml", `
+host = "db.internal"
+port = 3307
+username = "app"
+password = "${TEST_DB_PASSWORD}"
+database = "orders"
+slow_query_threshold = "200ms"
+
+[params]
+charset = "utf8mb4"
+
+[pool]
+max_open = 50
+max_idle = 10
+conn_max_lifetime = "30m"
+
+[retry]
+max_attempts = 3
+base_backoff = "50ms"
+jitter = true
+
+[probe]
+interval = "10s"
+
+[probe.reconnect]
+max_attempts = 7
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	checkLoadedConfig(t, cfg)
+}
+
+func TestLoadConfig_EnvOverlay(t *testing.T) {
+	path := writeConfigFile(t, "db.yaml", `
+host: db.internal
+database: orders
+hosts: [db-1, db-2]
+pool:
+  max_open: 10
+`)
+	t.Setenv("YGGGO_MYSQL_POOL_MAX_OPEN", "80")
+	t.Setenv("YGGGO_MYSQL_RETRY_MAX_ATTEMPTS", "5")
+	t.Setenv("YGGGO_MYSQL_HEALTH_TEST_QUERY", "SELECT 2")
+	t.Setenv("YGGGO_MYSQL_TLS_MODE", "required")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Pool.MaxOpen != 80 || cfg.Retry.MaxAttempts != 5 {
+		t.Fatalf("env overlay not applied: pool=%+v retry=%+v", cfg.Pool, cfg.Retry)
+	}
+	if cfg.Health == nil || cfg.Health.TestQuery != "SELECT 2" || cfg.Health.Timeout != DefaultHealthCheckConfig().Timeout {
+		t.Fatalf("health=%+v", cfg.Health)
+	}
+	if cfg.TLS == nil || cfg.TLS.Mode != "required" {
+		t.Fatalf("tls=%+v", cfg.TLS)
+	}
+	if len(cfg.Hosts) != 2 || cfg.Hosts[1] != "db-2" {
+		t.Fatalf("hosts=%v", cfg.Hosts)
+	}
+}
+
+func TestLoadConfig_ReportsAllErrors(t *testing.T) {
+	path := writeConfigFile(t, "db.yaml", `
+host: db.internal
+database: orders
+colour: blue
+password: ${TEST_DB_UNDEFINED_VAR}
+pool:
+  max_open: 5
+  max_idle: 9
+retry:
+  base_backoff: soon
+tls:
+  mode: sometimes
+`)
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatalf("expected errors")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`unknown config key "colour"`,
+		"TEST_DB_UNDEFINED_VAR",
+		"MaxIdle cannot be greater than MaxOpen",
+		`invalid duration "soon"`,
+		"invalid TLS mode",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("error %q does not mention %q", msg, want)
+		}
+	}
+}
+
+func TestLoadConfig_UnsupportedFormat(t *testing.T) {
+	path := writeConfigFile(t, "db.ini", "host=x")
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
+
+func TestValidateConfig_RequiresDatabaseForFieldConfig(t *testing.T) {
+	if err := ValidateConfig(Config{Host: "localhost"}); err == nil {
+		t.Fatalf("expected missing database error")
+	}
+	if err := ValidateConfig(Config{DSN: "u:p@tcp(localhost:3306)/db"}); err != nil {
+		t.Fatalf("unexpected error for valid DSN: %v", err)
+	}
+	if err := ValidateConfig(Config{Driver: "sqlite"}); err != nil {
+		t.Fatalf("non-mysql drivers skip DSN validation: %v", err)
+	}
+}
+
+func TestValidateConfig_PartialPool(t *testing.T) {
+	base := Config{DSN: "u:p@tcp(localhost:3306)/db"}
+	for _, pc := range []PoolConfig{{ConnMaxLifetime: time.Minute}, {MaxIdle: 5}, {MaxOpen: 10, MaxIdle: 5}} {
+		cfg := base
+		cfg.Pool = pc
+		if err := ValidateConfig(cfg); err != nil {
+			t.Fatalf("%+v: %v", pc, err)
+		}
+	}
+	for _, pc := range []PoolConfig{{MaxOpen: -1}, {MaxIdle: -1}, {MaxOpen: 2, MaxIdle: 5}, {ConnMaxLifetime: -time.Second}} {
+		cfg := base
+		cfg.Pool = pc
+		if err := ValidateConfig(cfg); err == nil {
+			t.Fatalf("%+v: expected an error", pc)
+		}
+	}
+}
+
+func TestDSNFromConfig_TLSMode(t *testing.T) {
+	cases := map[string]string{
+		"disabled":        "false",
+		"preferred":       "preferred",
+		"required":        "skip-verify",
+		"verify-identity": "true",
+	}
+	for mode, want := range cases {
+		cfg := Config{Host: "db", Port: 3306, Database: "app", TLS: &TLSConfig{Mode: mode}}
+		dsn, err := dsnFromConfig(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		mc, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Fatalf("%s: ParseDSN: %v", mode, err)
+		}
+		if mc.TLSConfig != want {
+			t.Fatalf("%s: tls=%q want %q", mode, mc.TLSConfig, want)
+		}
+	}
+
+	cfg := Config{Host: "db", Database: "app", TLS: &TLSConfig{Mode: "verify-ca", CAFile: "/nonexistent/ca.pem"}}
+	if _, err := dsnFromConfig(cfg); err == nil {
+		t.Fatalf("expected error for missing CA file")
+	}
+}
@@ -67,6 +67,12 @@ type Conn struct {
 
 	// cache is an optional per-connection prepared statement cache
 	cache *stmtCache
+
+	// track registers the connection with the pool's Shutdown tracking
+	track *inflightEntry
+
+	// connID is the server connection ID, recorded with Config.KillOnCancel
+	connID int64
 }
 
 // WithConn executes a function with an automatically managed database connection.
@@ -134,6 +140,8 @@ func (p *Pool) WithConn(ctx context.Context, fn func(DatabaseConn) error) error
 func (c *Conn) EnableStmtCache(capacity int) { c.cache = newStmtCache(capacity) }
 
 // ExecCached executes using a cached prepared statement when enabled.
+// Query tags from the context are added as for Exec; statements are cached
+// by their tagged text.
 func (c *Conn) ExecCached(ctx context.Context, query string, args ...any) (sql.Result, error) {
 	if c == nil || c.inner == nil {
 		return nil, sql.ErrConnDone
@@ -141,6 +149,10 @@ func (c *Conn) ExecCached(ctx context.Context, query string, args ...any) (sql.R
 	if c.cache == nil {
 		return c.Exec(ctx, query, args...)
 	}
+	if err := c.p.checkQuery(ctx, query); err != nil {
+		return nil, err
+	}
+	query = c.p.tagQuery(ctx, query)
 	st, _, err := c.cache.getOrPrepare(ctx, c.inner, query)
 	if err != nil {
 		return nil, err
@@ -149,6 +161,10 @@ func (c *Conn) ExecCached(ctx context.Context, query string, args ...any) (sql.R
 }
 
 // QueryCached runs a query using stmt cache when enabled.
+// Query tags are added as for Query and statements are cached by their
+// tagged text. A MAX_EXECUTION_TIME hint is added only for a limit set
+// with WithMaxExecutionTime, not for the context deadline, so the cached
+// statement does not change with every call.
 func (c *Conn) QueryCached(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
 	if c == nil || c.inner == nil {
 		return nil, sql.ErrConnDone
@@ -156,23 +172,44 @@ func (c *Conn) QueryCached(ctx context.Context, query string, args ...any) (*sql
 	if c.cache == nil {
 		return c.Query(ctx, query, args...)
 	}
+	if err := c.p.checkQuery(ctx, query); err != nil {
+		return nil, err
+	}
+	query = c.p.hintCachedQuery(ctx, c.p.tagQuery(ctx, query))
 	st, _, err := c.cache.getOrPrepare(ctx, c.inner, query)
 	if err != nil {
 		return nil, err
 	}
-	return st.QueryContext(ctx, args...)
+	rows, err := st.QueryContext(ctx, args...)
+	return rows, wrapQueryTimeout(err, query)
 }
 
 // Acquire gets a connection from the underlying *sql.DB honoring context.
+// It returns ErrPoolClosing once Shutdown has been called.
 func (p *Pool) Acquire(ctx context.Context) (DatabaseConn, error) {
-	if p == nil || p.db == nil {
+	db := p.getDB()
+	if db == nil {
 		return nil, errors.New("nil pool")
 	}
-	c, err := p.db.Conn(ctx)
+	track, err := p.inflight.add("conn", nil)
 	if err != nil {
 		return nil, err
 	}
-	conn := &Conn{inner: c, p: p}
+	c, err := db.Conn(ctx)
+	if err != nil && p.shouldFailover(err) {
+		if p.failoverFrom(ctx, db, err) == nil {
+			c, err = p.getDB().Conn(ctx)
+		}
+	}
+	if err != nil {
+		p.inflight.done(track)
+		return nil, err
+	}
+	p.inflight.setConn(track, c)
+	conn := &Conn{inner: c, p: p, track: track}
+	if p.cfg.KillOnCancel {
+		conn.connID = driverConnID(c)
+	}
 	conn.markAcquired()
 
 	return conn, nil
@@ -194,6 +231,7 @@ func (c *Conn) Close() error {
 	}
 
 	c.p.onReturn()
+	c.p.inflight.done(c.track)
 	if c.cache != nil {
 		c.cache.closeAll()
 	}
@@ -2,10 +2,12 @@ package ygggo_mysql
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,6 +44,7 @@ type ProbeConfig struct {
 	SuccessThreshold    int             `json:"success_threshold"`
 	EnableAutoReconnect bool            `json:"enable_auto_reconnect"`
 	ReconnectPolicy     ReconnectPolicy `json:"reconnect_policy"`
+	EventBuffer         int             `json:"event_buffer"` // Capacity of the channels returned by Events; defaults to 64
 }
 
 // ReconnectPolicy defines the reconnection strategy
@@ -52,6 +55,12 @@ type ReconnectPolicy struct {
 	BackoffMultiplier float64       `json:"backoff_multiplier"`
 	Jitter            bool          `json:"jitter"`
 	MaxElapsed        time.Duration `json:"max_elapsed"`
+
+	// RebuildPool replaces the pool's *sql.DB (see Pool.Rebuild) when a
+	// reconnect attempt cannot ping the server, so a broken connector or
+	// stale connections do not outlive the outage. Pools with several
+	// Config.Hosts fail over instead.
+	RebuildPool bool `json:"rebuild_pool"`
 }
 
 // ProbeState represents the current state of connection probing
@@ -78,6 +87,11 @@ const (
 	ProbeEventReconnectSuccess
 	ProbeEventReconnectFailed
 	ProbeEventReconnectAbandoned
+	ProbeEventFailoverStarted
+	ProbeEventFailoverSuccess
+	ProbeEventFailoverFailed
+	ProbeEventRebuildSuccess
+	ProbeEventRebuildFailed
 )
 
 func (t ProbeEventType) String() string {
@@ -94,6 +108,16 @@ func (t ProbeEventType) String() string {
 		return "ReconnectFailed"
 	case ProbeEventReconnectAbandoned:
 		return "ReconnectAbandoned"
+	case ProbeEventFailoverStarted:
+		return "FailoverStarted"
+	case ProbeEventFailoverSuccess:
+		return "FailoverSuccess"
+	case ProbeEventFailoverFailed:
+		return "FailoverFailed"
+	case ProbeEventRebuildSuccess:
+		return "RebuildSuccess"
+	case ProbeEventRebuildFailed:
+		return "RebuildFailed"
 	default:
 		return "Unknown"
 	}
@@ -106,6 +130,8 @@ type ProbeEvent struct {
 	Message   string         `json:"message"`
 	Error     error          `json:"error,omitempty"`
 	State     ProbeState     `json:"state"`
+	FromHost  string         `json:"from_host,omitempty"` // set on failover and rebuild events
+	ToHost    string         `json:"to_host,omitempty"`   // set on successful failover and rebuild
 }
 
 // ProbeEventHandler handles probe events
@@ -120,7 +146,12 @@ type ConnectionProbe struct {
 	state         ProbeState
 	reconnector   *AutoReconnector
 	eventHandlers []ProbeEventHandler
+	subscribers   []chan ProbeEvent
+	dropped       atomic.Int64
+	forwarder     *failoverForwarder
 	stopChan      chan struct{}
+	ctx           context.Context // Canceled by Stop, bounds reconnection
+	cancel        context.CancelFunc
 	running       bool
 	mutex         sync.RWMutex
 }
@@ -156,6 +187,7 @@ func DefaultProbeConfig() ProbeConfig {
 			BackoffMultiplier: 2.0,
 			Jitter:            true,
 			MaxElapsed:        5 * time.Minute,
+			RebuildPool:       true,
 		},
 	}
 }
@@ -183,6 +215,10 @@ func ValidateProbeConfig(config ProbeConfig) error {
 		return fmt.Errorf("success threshold must be positive, got %d", config.SuccessThreshold)
 	}
 	
+	if config.EventBuffer < 0 {
+		return fmt.Errorf("event buffer must be non-negative, got %d", config.EventBuffer)
+	}
+	
 	if config.EnableAutoReconnect {
 		if err := ValidateReconnectPolicy(config.ReconnectPolicy); err != nil {
 			return fmt.Errorf("invalid reconnect policy: %w", err)
@@ -227,7 +263,13 @@ func (cp *ConnectionProbe) Start() error {
 	}
 	
 	cp.stopChan = make(chan struct{})
+	cp.ctx, cp.cancel = context.WithCancel(context.Background())
 	cp.running = true
+
+	if cp.pool != nil {
+		cp.forwarder = &failoverForwarder{cp: cp}
+		cp.pool.AddFailoverHandler(cp.forwarder)
+	}
 	
 	go cp.probeLoop()
 	
@@ -244,7 +286,17 @@ func (cp *ConnectionProbe) Stop() error {
 	}
 	
 	close(cp.stopChan)
+	cp.cancel()
 	cp.running = false
+	for _, ch := range cp.subscribers {
+		close(ch)
+	}
+	cp.subscribers = nil
+
+	if cp.pool != nil && cp.forwarder != nil {
+		cp.pool.removeFailoverHandler(cp.forwarder)
+		cp.forwarder = nil
+	}
 	
 	return nil
 }
@@ -290,6 +342,23 @@ func (cp *ConnectionProbe) RemoveEventHandler(handler ProbeEventHandler) {
 	}
 }
 
+// Events returns a channel receiving every probe event, including the
+// failover and rebuild events of the pool, in order. Delivery never
+// blocks the probe: when the channel is full the event is dropped and
+// counted in ProbeMetrics.DroppedEvents. Each call returns a new channel,
+// which is closed by Stop.
+func (cp *ConnectionProbe) Events() <-chan ProbeEvent {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	size := cp.config.EventBuffer
+	if size <= 0 {
+		size = 64
+	}
+	ch := make(chan ProbeEvent, size)
+	cp.subscribers = append(cp.subscribers, ch)
+	return ch
+}
+
 // probeLoop runs the main probing loop
 func (cp *ConnectionProbe) probeLoop() {
 	ticker := time.NewTicker(cp.config.Interval)
@@ -378,14 +447,44 @@ func (cp *ConnectionProbe) emitEvent(eventType ProbeEventType, message string, e
 	event := ProbeEvent{
 		Type:      eventType,
 		Timestamp: time.Now(),
-		Message:   message,
-		Error:     err,
+		Message:   RedactString(message),
+		Error:     redactError(err),
 		State:     cp.state,
 	}
-	
+	cp.deliver(event, true)
+}
+
+// deliver sends event to the handlers, in their own goroutines when async
+// is set, and to the Events channels without blocking. The caller holds
+// the mutex.
+func (cp *ConnectionProbe) deliver(event ProbeEvent, async bool) {
 	for _, handler := range cp.eventHandlers {
-		go handler.HandleProbeEvent(event)
+		if async {
+			go handler.HandleProbeEvent(event)
+		} else {
+			handler.HandleProbeEvent(event)
+		}
 	}
+	for _, ch := range cp.subscribers {
+		select {
+		case ch <- event:
+		default:
+			cp.dropped.Add(1)
+		}
+	}
+}
+
+// failoverForwarder relays pool failover events to the probe's handlers
+type failoverForwarder struct {
+	cp *ConnectionProbe
+}
+
+// HandleProbeEvent implements ProbeEventHandler
+func (f *failoverForwarder) HandleProbeEvent(event ProbeEvent) {
+	f.cp.mutex.RLock()
+	defer f.cp.mutex.RUnlock()
+	event.State = f.cp.state
+	f.cp.deliver(event, true)
 }
 
 // startAutoReconnect starts the auto-reconnection process
@@ -401,11 +500,14 @@ func (cp *ConnectionProbe) startAutoReconnect() {
 	}
 	cp.state.IsReconnecting = true
 	cp.state.Status = ProbeStatusReconnecting
-	cp.mutex.Unlock()
-	
 	cp.emitEvent(ProbeEventReconnectStarted, "Starting auto-reconnection", nil)
+	ctx := cp.ctx
+	cp.mutex.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	
-	success := cp.reconnector.Reconnect(context.Background())
+	success := cp.reconnector.Reconnect(ctx)
 	
 	cp.mutex.Lock()
 	defer cp.mutex.Unlock()
@@ -508,6 +610,27 @@ func (ar *AutoReconnector) Reconnect(ctx context.Context) bool {
 		if err == nil {
 			return true // Reconnection successful
 		}
+
+		// With multiple hosts configured, move to the next reachable one;
+		// otherwise replace the handle when asked to
+		var rerr error
+		switch {
+		case ar.pool.canFailover():
+			rerr = ar.pool.failoverFrom(ctx, ar.pool.getDB(), err)
+		case ar.policy.RebuildPool:
+			rerr = ar.pool.rebuildFrom(ctx, ar.pool.getDB())
+		default:
+			continue
+		}
+		if rerr == nil {
+			return true
+		}
+		ar.mutex.Lock()
+		ar.state.LastError = rerr
+		ar.mutex.Unlock()
+		if errors.Is(rerr, ErrPoolClosed) {
+			return false
+		}
 	}
 
 	return false // All attempts failed
@@ -590,9 +713,8 @@ func (cp *ConnectionProbe) ForceReconnect(ctx context.Context) error {
 	}
 	cp.state.IsReconnecting = true
 	cp.state.Status = ProbeStatusReconnecting
-	cp.mutex.Unlock()
-
 	cp.emitEvent(ProbeEventReconnectStarted, "Force reconnection started", nil)
+	cp.mutex.Unlock()
 
 	success := cp.reconnector.Reconnect(ctx)
 
@@ -671,6 +793,7 @@ func (cp *ConnectionProbe) GetMetrics() ProbeMetrics {
 		LastFailureTime:      cp.state.LastFailureTime,
 		IsReconnecting:       cp.state.IsReconnecting,
 		ReconnectAttempts:    cp.state.ReconnectAttempts,
+		DroppedEvents:        cp.dropped.Load(),
 	}
 }
 
@@ -688,4 +811,5 @@ type ProbeMetrics struct {
 	LastFailureTime      time.Time     `json:"last_failure_time"`
 	IsReconnecting       bool          `json:"is_reconnecting"`
 	ReconnectAttempts    int           `json:"reconnect_attempts"`
+	DroppedEvents        int64         `json:"dropped_events"` // Events not delivered to a full Events channel
 }
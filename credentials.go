@@ -0,0 +1,268 @@
+package ygggo_mysql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+// Credentials holds the user name and password for a new connection.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialProvider supplies credentials for each new physical connection.
+//
+// When Config.Credentials is set, the pool asks the provider for credentials
+// every time database/sql opens a connection, so rotated secrets are picked
+// up without restarting the pool. Existing connections keep the credentials
+// they authenticated with; set PoolConfig.ConnMaxLifetime to recycle them.
+//
+// If the server rejects a connection with an access-denied error, the pool
+// calls Refresh once and retries the connection with the new credentials.
+//
+// Implementations must be safe for concurrent use.
+type CredentialProvider interface {
+	// Credentials returns the credentials to use, possibly from a cache.
+	Credentials(ctx context.Context) (Credentials, error)
+
+	// Refresh discards any cached value and returns fresh credentials.
+	Refresh(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials returns a provider that always returns the given credentials.
+func StaticCredentials(username, password string) CredentialProvider {
+	return staticCredentialProvider{creds: Credentials{Username: username, Password: password}}
+}
+
+type staticCredentialProvider struct {
+	creds Credentials
+}
+
+func (s staticCredentialProvider) Credentials(context.Context) (Credentials, error) {
+	return s.creds, nil
+}
+
+func (s staticCredentialProvider) Refresh(ctx context.Context) (Credentials, error) {
+	return s.Credentials(ctx)
+}
+
+// CredentialFunc adapts a callback to CredentialProvider. The callback is
+// invoked for every new connection, so it should cache expensive lookups.
+type CredentialFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls f.
+func (f CredentialFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// Refresh calls f.
+func (f CredentialFunc) Refresh(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// EnvCredentialProvider reads credentials from environment variables on every
+// call, so values changed with os.Setenv take effect for new connections.
+type EnvCredentialProvider struct {
+	UsernameKey string // defaults to YGGGO_MYSQL_USERNAME
+	PasswordKey string // defaults to YGGGO_MYSQL_PASSWORD
+}
+
+// NewEnvCredentialProvider creates an EnvCredentialProvider reading the
+// given keys. Empty keys fall back to the YGGGO_MYSQL_* defaults.
+func NewEnvCredentialProvider(usernameKey, passwordKey string) *EnvCredentialProvider {
+	return &EnvCredentialProvider{UsernameKey: usernameKey, PasswordKey: passwordKey}
+}
+
+// Credentials returns the current values of the configured variables.
+func (e *EnvCredentialProvider) Credentials(context.Context) (Credentials, error) {
+	userKey, passKey := e.UsernameKey, e.PasswordKey
+	if userKey == "" {
+		userKey = "YGGGO_MYSQL_USERNAME"
+	}
+	if passKey == "" {
+		passKey = "YGGGO_MYSQL_PASSWORD"
+	}
+	pass, ok := os.LookupEnv(passKey)
+	if !ok {
+		return Credentials{}, fmt.Errorf("credential env %s is not set", passKey)
+	}
+	return Credentials{Username: os.Getenv(userKey), Password: pass}, nil
+}
+
+// Refresh is equivalent to Credentials; environment variables are never cached.
+func (e *EnvCredentialProvider) Refresh(ctx context.Context) (Credentials, error) {
+	return e.Credentials(ctx)
+}
+
+// FileCredentialProvider reads credentials from a file, such as a mounted
+// Kubernetes or Vault secret, and reloads it when the file changes.
+//
+// The file holds either a JSON object {"username": "...", "password": "..."}
+// or just the password, in which case Username is used. Surrounding
+// whitespace is ignored.
+type FileCredentialProvider struct {
+	path          string
+	username      string
+	checkInterval time.Duration
+
+	mu        sync.Mutex
+	creds     Credentials
+	modTime   time.Time
+	size      int64
+	lastCheck time.Time
+	loaded    bool
+}
+
+// NewFileCredentialProvider creates a provider for the file at path. The
+// file's modification time is checked at most once per checkInterval
+// (default 1s) and the file is re-read when it changed.
+func NewFileCredentialProvider(path, username string, checkInterval time.Duration) *FileCredentialProvider {
+	if checkInterval <= 0 {
+		checkInterval = time.Second
+	}
+	return &FileCredentialProvider{path: path, username: username, checkInterval: checkInterval}
+}
+
+// Credentials returns the cached credentials, reloading the file if it changed.
+func (f *FileCredentialProvider) Credentials(context.Context) (Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.loaded && time.Since(f.lastCheck) < f.checkInterval {
+		return f.creds, nil
+	}
+	f.lastCheck = time.Now()
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		if f.loaded {
+			// Keep serving the last good value while the secret is being replaced
+			return f.creds, nil
+		}
+		return Credentials{}, fmt.Errorf("failed to stat credential file: %w", err)
+	}
+	if f.loaded && fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return f.creds, nil
+	}
+	return f.load()
+}
+
+// Refresh re-reads the file unconditionally.
+func (f *FileCredentialProvider) Refresh(context.Context) (Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastCheck = time.Now()
+	return f.load()
+}
+
+// load reads the file; f.mu must be held.
+func (f *FileCredentialProvider) load() (Credentials, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to stat credential file: %w", err)
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read credential file: %w", err)
+	}
+	creds, err := parseCredentialFile(data, f.username)
+	if err != nil {
+		return Credentials{}, err
+	}
+	f.creds = creds
+	f.modTime = fi.ModTime()
+	f.size = fi.Size()
+	f.loaded = true
+	return creds, nil
+}
+
+// parseCredentialFile decodes a JSON credential object or a bare password.
+func parseCredentialFile(data []byte, username string) (Credentials, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var creds Credentials
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return Credentials{}, fmt.Errorf("failed to parse credential file: %w", err)
+		}
+		if creds.Username == "" {
+			creds.Username = username
+		}
+		return creds, nil
+	}
+	return Credentials{Username: username, Password: string(data)}, nil
+}
+
+// erAccessDenied is ER_ACCESS_DENIED_ERROR, returned for bad credentials.
+const erAccessDenied = 1045
+
+// credentialConnector is a driver.Connector that fetches credentials from a
+// provider before each connection and refreshes them once on auth failure.
+type credentialConnector struct {
+	inner    driver.Connector
+	provider CredentialProvider
+}
+
+// newCredentialConnector builds a connector for dsn whose user and password
+// are supplied by provider.
+func newCredentialConnector(dsn string, provider CredentialProvider) (driver.Connector, error) {
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DSN: %w", err)
+	}
+	err = mc.Apply(mysql.BeforeConnect(func(ctx context.Context, c *mysql.Config) error {
+		creds, err := provider.Credentials(ctx)
+		if err != nil {
+			return fmt.Errorf("credential provider: %w", err)
+		}
+		applyCredentials(c, creds)
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	inner, err := mysql.NewConnector(mc)
+	if err != nil {
+		return nil, err
+	}
+	return &credentialConnector{inner: inner, provider: provider}, nil
+}
+
+// applyCredentials sets non-empty credential fields on c.
+func applyCredentials(c *mysql.Config, creds Credentials) {
+	if creds.Username != "" {
+		c.User = creds.Username
+	}
+	c.Passwd = creds.Password
+}
+
+// Connect implements driver.Connector.
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.inner.Connect(ctx)
+	if err == nil || !isAccessDenied(err) {
+		return conn, err
+	}
+	if _, rerr := c.provider.Refresh(ctx); rerr != nil {
+		return nil, errors.Join(err, fmt.Errorf("credential refresh failed: %w", rerr))
+	}
+	return c.inner.Connect(ctx)
+}
+
+// Driver implements driver.Connector.
+func (c *credentialConnector) Driver() driver.Driver {
+	return c.inner.Driver()
+}
+
+// isAccessDenied reports whether err is a MySQL authentication failure.
+func isAccessDenied(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == erAccessDenied
+}
@@ -0,0 +1,169 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+func TestStaticAndFuncCredentials(t *testing.T) {
+	ctx := context.Background()
+	creds, err := StaticCredentials("u", "p").Credentials(ctx)
+	if err != nil || creds.Username != "u" || creds.Password != "p" {
+		t.Fatalf("static creds=%+v err=%v", creds, err)
+	}
+
+	calls := 0
+	fn := CredentialFunc(func(context.Context) (Credentials, error) {
+		calls++
+		return Credentials{Username: "cb", Password: "secret"}, nil
+	})
+	if _, err := fn.Credentials(ctx); err != nil {
+		t.Fatalf("func creds: %v", err)
+	}
+	if _, err := fn.Refresh(ctx); err != nil {
+		t.Fatalf("func refresh: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected callback per call, got %d", calls)
+	}
+}
+
+func TestEnvCredentialProvider_ReadsCurrentValues(t *testing.T) {
+	t.Setenv("APP_DB_USER", "app")
+	t.Setenv("APP_DB_PASS", "one")
+	p := NewEnvCredentialProvider("APP_DB_USER", "APP_DB_PASS")
+
+	creds, err := p.Credentials(context.Background())
+	if err != nil || creds.Username != "app" || creds.Password != "one" {
+		t.Fatalf("creds=%+v err=%v", creds, err)
+	}
+	t.Setenv("APP_DB_PASS", "two")
+	creds, _ = p.Credentials(context.Background())
+	if creds.Password != "two" {
+		t.Fatalf("expected rotated password, got %q", creds.Password)
+	}
+
+	missing := NewEnvCredentialProvider("", "APP_DB_MISSING_PASS")
+	if _, err := missing.Credentials(context.Background()); err == nil {
+		t.Fatalf("expected error for unset password variable")
+	}
+}
+
+func TestFileCredentialProvider_ReloadsOnChange(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db-password")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := NewFileCredentialProvider(path, "app", time.Millisecond)
+
+	creds, err := p.Credentials(ctx)
+	if err != nil || creds.Username != "app" || creds.Password != "first" {
+		t.Fatalf("creds=%+v err=%v", creds, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"username":"rotated","password":"second-secret"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the change is observable even on coarse mtime filesystems
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, future, future)
+	time.Sleep(5 * time.Millisecond)
+
+	creds, err = p.Credentials(ctx)
+	if err != nil || creds.Username != "rotated" || creds.Password != "second-secret" {
+		t.Fatalf("after rotation creds=%+v err=%v", creds, err)
+	}
+
+	// A missing file keeps serving the last good value
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	creds, err = p.Credentials(ctx)
+	if err != nil || creds.Password != "second-secret" {
+		t.Fatalf("after removal creds=%+v err=%v", creds, err)
+	}
+	if _, err := p.Refresh(ctx); err == nil {
+		t.Fatalf("expected Refresh to fail for missing file")
+	}
+}
+
+type fakeConnector struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	f.calls++
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (f *fakeConnector) Driver() driver.Driver { return &mysql.MySQLDriver{} }
+
+type countingProvider struct {
+	refreshes int
+}
+
+func (c *countingProvider) Credentials(context.Context) (Credentials, error) {
+	return Credentials{Username: "u", Password: "p"}, nil
+}
+
+func (c *countingProvider) Refresh(ctx context.Context) (Credentials, error) {
+	c.refreshes++
+	return c.Credentials(ctx)
+}
+
+func TestCredentialConnector_RefreshesOnceOnAccessDenied(t *testing.T) {
+	denied := &mysql.MySQLError{Number: 1045, Message: "Access denied"}
+
+	inner := &fakeConnector{errs: []error{denied}}
+	prov := &countingProvider{}
+	c := &credentialConnector{inner: inner, provider: prov}
+	if _, err := c.Connect(context.Background()); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if inner.calls != 2 || prov.refreshes != 1 {
+		t.Fatalf("calls=%d refreshes=%d", inner.calls, prov.refreshes)
+	}
+
+	inner = &fakeConnector{errs: []error{denied, denied}}
+	prov = &countingProvider{}
+	c = &credentialConnector{inner: inner, provider: prov}
+	_, err := c.Connect(context.Background())
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) || me.Number != 1045 {
+		t.Fatalf("expected access denied after single refresh, got %v", err)
+	}
+	if inner.calls != 2 || prov.refreshes != 1 {
+		t.Fatalf("calls=%d refreshes=%d", inner.calls, prov.refreshes)
+	}
+
+	inner = &fakeConnector{errs: []error{driver.ErrBadConn}}
+	prov = &countingProvider{}
+	c = &credentialConnector{inner: inner, provider: prov}
+	if _, err := c.Connect(context.Background()); !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("expected ErrBadConn, got %v", err)
+	}
+	if prov.refreshes != 0 {
+		t.Fatalf("non-auth errors must not refresh credentials")
+	}
+}
+
+func TestNewCredentialConnector_InvalidDSN(t *testing.T) {
+	if _, err := newCredentialConnector("::not a dsn", StaticCredentials("u", "p")); err == nil {
+		t.Fatalf("expected DSN parse error")
+	}
+}
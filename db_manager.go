@@ -14,10 +14,11 @@ type DBManager struct {
 
 // GetDB returns a DBManager bound to the underlying *sql.DB.
 func (p *Pool) GetDB() (*DBManager, error) {
-	if p == nil || p.db == nil {
+	db := p.getDB()
+	if db == nil {
 		return nil, sql.ErrConnDone
 	}
-	return &DBManager{db: p.db}, nil
+	return &DBManager{db: db}, nil
 }
 
 // GetAllDatabase returns all database names.
@@ -0,0 +1,300 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// erLockDeadlock is returned to the transaction rolled back by InnoDB to
+// resolve a deadlock.
+const erLockDeadlock = 1213
+
+// ErrNoDeadlock is returned when SHOW ENGINE INNODB STATUS has no
+// LATEST DETECTED DEADLOCK section.
+var ErrNoDeadlock = errors.New("no deadlock detected since server start")
+
+// DeadlockReport is the LATEST DETECTED DEADLOCK section of
+// SHOW ENGINE INNODB STATUS.
+type DeadlockReport struct {
+	Time         time.Time             `json:"time"` // Server local time, parsed in time.Local
+	Transactions []DeadlockTransaction `json:"transactions"`
+	RolledBack   int                   `json:"rolled_back"` // Number of the victim transaction; 0 if not reported
+	Raw          string                `json:"raw"`
+}
+
+// Victim returns the transaction rolled back to resolve the deadlock, or
+// nil.
+func (r *DeadlockReport) Victim() *DeadlockTransaction {
+	for i := range r.Transactions {
+		if r.Transactions[i].Number == r.RolledBack {
+			return &r.Transactions[i]
+		}
+	}
+	return nil
+}
+
+// DeadlockTransaction is a transaction involved in a deadlock.
+type DeadlockTransaction struct {
+	Number     int            `json:"number"` // (1), (2), ... in the report
+	TrxID      string         `json:"trx_id"`
+	Active     time.Duration  `json:"active"`
+	ThreadID   int64          `json:"thread_id"` // Connection ID
+	Host       string         `json:"host"`
+	User       string         `json:"user"`
+	Query      string         `json:"query"` // The statement that was waiting
+	Holds      []DeadlockLock `json:"holds"`
+	WaitingFor []DeadlockLock `json:"waiting_for"`
+}
+
+// DeadlockLock is a lock held or requested by a deadlocked transaction.
+type DeadlockLock struct {
+	Type   string `json:"type"` // RECORD or TABLE
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Index  string `json:"index"`
+	Mode   string `json:"mode"` // e.g. "X locks rec but not gap"
+}
+
+var (
+	deadlockTrxHeader = regexp.MustCompile(`^\*\*\* \((\d+)\) TRANSACTION:`)
+	deadlockSection   = regexp.MustCompile(`^\*\*\* \((\d+)\) (HOLDS THE LOCK\(S\)|WAITING FOR THIS LOCK TO BE GRANTED):`)
+	deadlockRollback  = regexp.MustCompile(`^\*\*\* WE ROLL BACK TRANSACTION \((\d+)\)`)
+	deadlockTrxLine   = regexp.MustCompile(`^TRANSACTION (\S+), ACTIVE (\d+) sec`)
+	deadlockThread    = regexp.MustCompile(`^MySQL thread id (\d+), OS thread handle \S+, query id \d+ ?(.*)$`)
+	deadlockLockLine  = regexp.MustCompile("^(RECORD|TABLE) LOCKS? .*?(?:index (\\S+) of )?table (`[^`]*`\\.`[^`]*`).*? lock[_ ]mode (.*?)( waiting)?$")
+)
+
+// ParseDeadlock extracts the LATEST DETECTED DEADLOCK section from the
+// output of SHOW ENGINE INNODB STATUS. It returns ErrNoDeadlock when the
+// section is missing.
+func ParseDeadlock(status string) (*DeadlockReport, error) {
+	lines := strings.Split(strings.ReplaceAll(status, "\r\n", "\n"), "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "LATEST DETECTED DEADLOCK" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil, ErrNoDeadlock
+	}
+	if start < len(lines) && isDashes(lines[start]) {
+		start++
+	}
+	end := start
+	for end < len(lines) && !isDashes(lines[end]) {
+		end++
+	}
+	section := lines[start:end]
+
+	r := &DeadlockReport{Raw: strings.TrimSpace(strings.Join(section, "\n"))}
+	if len(section) > 0 && len(section[0]) >= 19 {
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", section[0][:19], time.Local); err == nil {
+			r.Time = t
+		}
+	}
+
+	var trx *DeadlockTransaction
+	var locks *[]DeadlockLock
+	inQuery := false
+	for _, line := range section {
+		if m := deadlockTrxHeader.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			r.Transactions = append(r.Transactions, DeadlockTransaction{Number: n})
+			trx, locks, inQuery = &r.Transactions[len(r.Transactions)-1], nil, false
+			continue
+		}
+		if m := deadlockSection.FindStringSubmatch(line); m != nil && trx != nil {
+			inQuery = false
+			if strings.HasPrefix(m[2], "HOLDS") {
+				locks = &trx.Holds
+			} else {
+				locks = &trx.WaitingFor
+			}
+			continue
+		}
+		if m := deadlockRollback.FindStringSubmatch(line); m != nil {
+			r.RolledBack, _ = strconv.Atoi(m[1])
+			trx, locks, inQuery = nil, nil, false
+			continue
+		}
+		if trx == nil {
+			continue
+		}
+		switch {
+		case inQuery:
+			if strings.TrimSpace(line) == "" {
+				inQuery = false
+				continue
+			}
+			if trx.Query != "" {
+				trx.Query += "\n"
+			}
+			trx.Query += line
+		case locks != nil:
+			if m := deadlockLockLine.FindStringSubmatch(line); m != nil {
+				l := DeadlockLock{Type: m[1], Index: m[2], Mode: m[4]}
+				l.Schema, l.Table = splitQualifiedTable(m[3])
+				*locks = append(*locks, l)
+			}
+		default:
+			if m := deadlockTrxLine.FindStringSubmatch(line); m != nil {
+				trx.TrxID = m[1]
+				secs, _ := strconv.Atoi(m[2])
+				trx.Active = time.Duration(secs) * time.Second
+			} else if m := deadlockThread.FindStringSubmatch(line); m != nil {
+				trx.ThreadID, _ = strconv.ParseInt(m[1], 10, 64)
+				// Host and user follow the query id, then the thread state
+				if fields := strings.Fields(m[2]); len(fields) >= 2 {
+					trx.Host, trx.User = fields[0], fields[1]
+				}
+				inQuery = true
+			}
+		}
+	}
+	if len(r.Transactions) == 0 {
+		return nil, fmt.Errorf("failed to parse deadlock report: no transactions found")
+	}
+	return r, nil
+}
+
+// isDashes reports whether line is a section separator of the InnoDB
+// status output.
+func isDashes(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= 4 && strings.Trim(line, "-") == ""
+}
+
+// LatestDeadlock returns the last deadlock detected by InnoDB since the
+// server started, from SHOW ENGINE INNODB STATUS, which requires the
+// PROCESS privilege. It returns ErrNoDeadlock when there was none.
+func (p *Pool) LatestDeadlock(ctx context.Context) (*DeadlockReport, error) {
+	db := p.getDB()
+	if db == nil {
+		return nil, fmt.Errorf("pool or database is nil")
+	}
+	ctx = internalContext(ctx)
+	var typ, name, status string
+	if err := db.QueryRowContext(ctx, "SHOW ENGINE INNODB STATUS").Scan(&typ, &name, &status); err != nil {
+		return nil, fmt.Errorf("failed to read InnoDB status: %w", err)
+	}
+	return ParseDeadlock(status)
+}
+
+// deadlockCaptureTimeout bounds the capture of a deadlock report.
+const deadlockCaptureTimeout = 2 * time.Second
+
+// captureDeadlock logs and stores the report of the deadlock that caused
+// err, when Config.CaptureDeadlocks is set. The report is read in the
+// background, so the retry of the transaction does not wait for it. A
+// report is captured once, however many retries see it.
+func (p *Pool) captureDeadlock(ctx context.Context, err error) {
+	var me *mysql.MySQLError
+	if !p.cfg.CaptureDeadlocks || !errors.As(err, &me) || me.Number != erLockDeadlock {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	p.deadlockWG.Add(1)
+	go func() {
+		defer p.deadlockWG.Done()
+		p.readDeadlock(ctx)
+	}()
+}
+
+// readDeadlock reads the latest deadlock report and logs and stores it,
+// unless it was captured before.
+func (p *Pool) readDeadlock(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, deadlockCaptureTimeout)
+	defer cancel()
+	report, rerr := p.LatestDeadlock(ctx)
+	if rerr != nil {
+		if _, ok := p.loggingFor(LogTransactions, slog.LevelWarn); ok {
+			p.logger.LogAttrs(ctx, slog.LevelWarn, "deadlock report unavailable",
+				slog.String("error", p.getRedactor().String(rerr.Error())))
+		}
+		return
+	}
+
+	p.deadlockMu.Lock()
+	seen := report.Raw == p.lastDeadlock
+	p.lastDeadlock = report.Raw
+	p.deadlockMu.Unlock()
+	if seen {
+		return
+	}
+	p.logDeadlock(ctx, report)
+	p.storeDeadlock(ctx, report)
+}
+
+// logDeadlock logs report under LogTransactions.
+func (p *Pool) logDeadlock(ctx context.Context, report *DeadlockReport) {
+	if _, ok := p.loggingFor(LogTransactions, slog.LevelWarn); !ok {
+		return
+	}
+	redactor := p.getRedactor()
+	attrs := []slog.Attr{slog.Time("detected_at", report.Time), slog.Int("rolled_back", report.RolledBack)}
+	for _, t := range report.Transactions {
+		attrs = append(attrs, slog.Group(fmt.Sprintf("trx%d", t.Number),
+			slog.String("trx_id", t.TrxID),
+			slog.Int64("thread_id", t.ThreadID),
+			slog.String("user", t.User),
+			slog.String("query", redactor.Query(t.Query)),
+			slog.String("waiting_for", describeLocks(t.WaitingFor)),
+		))
+	}
+	p.logger.LogAttrs(ctx, slog.LevelWarn, "deadlock detected", attrs...)
+}
+
+// storeDeadlock stores a slow query record for each transaction of report
+// in the storage of the slow query recorder.
+func (p *Pool) storeDeadlock(ctx context.Context, report *DeadlockReport) {
+	recorder := p.slowQueryRecorder
+	if recorder == nil {
+		return
+	}
+	mode := recorder.GetConfig().NormalizationMode
+	redactor := p.getRedactor()
+	for _, t := range report.Transactions {
+		msg := fmt.Sprintf("deadlock: transaction (%d) waiting for %s", t.Number, describeLocks(t.WaitingFor))
+		if t.Number == report.RolledBack {
+			msg += "; rolled back"
+		}
+		record := &SlowQueryRecord{
+			ID:              generateID(),
+			Query:           redactor.Query(t.Query),
+			NormalizedQuery: FingerprintQuery(t.Query, mode),
+			Duration:        t.Active,
+			Timestamp:       report.Time,
+			User:            t.User,
+			Host:            t.Host,
+			Error:           msg,
+		}
+		record.PatternID = QueryDigest(record.NormalizedQuery)
+		_ = recorder.store(ctx, record)
+	}
+}
+
+// describeLocks summarizes locks for logs and records.
+func describeLocks(locks []DeadlockLock) string {
+	parts := make([]string, 0, len(locks))
+	for _, l := range locks {
+		s := strings.ToLower(l.Type) + " lock " + l.Mode + " on " + l.Schema + "." + l.Table
+		if l.Index != "" {
+			s += " index " + l.Index
+		}
+		parts = append(parts, s)
+	}
+	if len(parts) == 0 {
+		return "unknown lock"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,139 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const innodbStatus = `
+=====================================
+2024-03-01 10:15:50 0x7f6c4c1f8700 INNODB MONITOR OUTPUT
+=====================================
+------------------------
+LATEST DETECTED DEADLOCK
+------------------------
+2024-03-01 10:15:42 0x7f6c4c1f8700
+*** (1) TRANSACTION:
+TRANSACTION 5432, ACTIVE 7 sec starting index read
+mysql tables in use 1, locked 1
+LOCK WAIT 3 lock struct(s), heap size 1128, 2 row lock(s)
+MySQL thread id 12, OS thread handle 140129470105344, query id 345 10.0.0.5 app updating
+UPDATE accounts
+SET balance = balance - 10 WHERE id = 2
+
+*** (1) HOLDS THE LOCK(S):
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`bank`.`accounts`" + ` trx id 5432 lock_mode X locks rec but not gap
+Record lock, heap no 2 PHYSICAL RECORD: n_fields 4; compact format; info bits 0
+ 0: len 4; hex 80000001; asc     ;;
+
+*** (1) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`bank`.`accounts`" + ` trx id 5432 lock_mode X locks rec but not gap waiting
+Record lock, heap no 3 PHYSICAL RECORD: n_fields 4; compact format; info bits 0
+
+*** (2) TRANSACTION:
+TRANSACTION 5433, ACTIVE 5 sec starting index read
+mysql tables in use 1, locked 1
+MySQL thread id 13, OS thread handle 140129470408448, query id 346 localhost batch updating
+UPDATE accounts SET balance = balance + 10 WHERE id = 1
+
+*** (2) HOLDS THE LOCK(S):
+TABLE LOCK table ` + "`bank`.`accounts`" + ` trx id 5433 lock mode IX
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`bank`.`accounts`" + ` trx id 5433 lock_mode X locks rec but not gap
+
+*** (2) WAITING FOR THIS LOCK TO BE GRANTED:
+RECORD LOCKS space id 2 page no 4 n bits 72 index PRIMARY of table ` + "`bank`.`accounts`" + ` trx id 5433 lock_mode X locks rec but not gap waiting
+
+*** WE ROLL BACK TRANSACTION (2)
+------------
+TRANSACTIONS
+------------
+Trx id counter 5440
+`
+
+func TestParseDeadlock(t *testing.T) {
+	r, err := ParseDeadlock(innodbStatus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 3, 1, 10, 15, 42, 0, time.Local); !r.Time.Equal(want) {
+		t.Fatalf("time: %v", r.Time)
+	}
+	if len(r.Transactions) != 2 || r.RolledBack != 2 {
+		t.Fatalf("report: %+v", r)
+	}
+	t1 := r.Transactions[0]
+	if t1.TrxID != "5432" || t1.Active != 7*time.Second || t1.ThreadID != 12 || t1.Host != "10.0.0.5" || t1.User != "app" ||
+		t1.Query != "UPDATE accounts\nSET balance = balance - 10 WHERE id = 2" {
+		t.Fatalf("transaction 1: %+v", t1)
+	}
+	if len(t1.Holds) != 1 || len(t1.WaitingFor) != 1 {
+		t.Fatalf("transaction 1 locks: %+v", t1)
+	}
+	l := t1.WaitingFor[0]
+	if l.Type != "RECORD" || l.Schema != "bank" || l.Table != "accounts" || l.Index != "PRIMARY" || l.Mode != "X locks rec but not gap" {
+		t.Fatalf("lock: %+v", l)
+	}
+	v := r.Victim()
+	if v == nil || v.User != "batch" || len(v.Holds) != 2 || v.Holds[0].Type != "TABLE" || v.Holds[0].Mode != "IX" || v.Holds[0].Index != "" {
+		t.Fatalf("victim: %+v", v)
+	}
+	if !strings.HasPrefix(r.Raw, "2024-03-01 10:15:42") || !strings.HasSuffix(r.Raw, "*** WE ROLL BACK TRANSACTION (2)") {
+		t.Fatalf("raw: %q", r.Raw)
+	}
+
+	if _, err := ParseDeadlock("=====\nTRANSACTIONS\n"); !errors.Is(err, ErrNoDeadlock) {
+		t.Fatalf("expected ErrNoDeadlock, got %v", err)
+	}
+}
+
+func TestWithinTx_CapturesDeadlocks(t *testing.T) {
+	s := newFakeServer()
+	s.set("SHOW ENGINE INNODB STATUS", serverResult{cols: []string{"Type", "Name", "Status"}, rows: [][]driver.Value{{"InnoDB", "", innodbStatus}}})
+	s.set("UPDATE accounts", serverResult{err: &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}})
+	p := s.pool(t)
+	p.cfg.CaptureDeadlocks = true
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	p.slowQueryRecorder = NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(10))
+	defer p.slowQueryRecorder.Close()
+
+	ctx := context.Background()
+	update := func(tx DatabaseTx) error {
+		_, err := tx.Exec(ctx, "UPDATE accounts SET balance = balance + 10 WHERE id = 1")
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.WithinTx(ctx, update); Classify(err) != ErrClassRetryable {
+			t.Fatalf("expected a deadlock, got %v", err)
+		}
+	}
+
+	p.deadlockWG.Wait()
+
+	// The same report is stored once, with a record per transaction
+	records, _ := p.slowQueryRecorder.GetRecords(ctx, SlowQueryFilter{})
+	if len(records) != 2 {
+		t.Fatalf("records: %+v", records)
+	}
+	var victim int
+	for _, r := range records {
+		if !strings.HasPrefix(r.Error, "deadlock: transaction") || r.PatternID == "" {
+			t.Fatalf("record: %+v", r)
+		}
+		if strings.HasSuffix(r.Error, "rolled back") {
+			victim++
+			if r.User != "batch" {
+				t.Fatalf("victim record: %+v", r)
+			}
+		}
+	}
+	if victim != 1 {
+		t.Fatalf("expected one victim record: %+v", records)
+	}
+}
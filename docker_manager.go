@@ -180,7 +180,13 @@ func NewMySQL(ctx context.Context) error {
 		if msg == "" {
 			msg = "unknown error"
 		}
-		return fmt.Errorf("failed to run mysql docker container: %s", msg)
+		// docker may echo the container environment; never leak the passwords
+		for _, secret := range []string{password, rootPassword} {
+			if secret != "" {
+				msg = strings.ReplaceAll(msg, secret, "***")
+			}
+		}
+		return fmt.Errorf("failed to run mysql docker container: %s", RedactString(msg))
 	}
 	// If user provided port=0 or default failed, ensure env port reflects actual mapping
 	if p := resolveMappedPort(ctx, name); p != "" {
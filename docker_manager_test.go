@@ -86,6 +86,8 @@ func TestDockerManager_StartExisting(t *testing.T) {
 	fr := &fakeRunnerExisting{}
 	dockerRunner = fr
 	t.Cleanup(func() { dockerRunner = old })
+	// NewMySQL sets the mapped port; keep it from leaking into other tests
+	t.Setenv(EnvMySQLPort, "3306")
 
 	if err := NewMySQL(ctx); err != nil {
 		t.Fatalf("NewMySQL err: %v", err)
@@ -1,7 +1,13 @@
 package ygggo_mysql
 
 import (
+	"context"
+	"database/sql/driver"
 	"errors"
+	"fmt"
+	"net"
+	"time"
+
 	mysql "github.com/go-sql-driver/mysql"
 )
 
@@ -14,8 +20,26 @@ const (
 	ErrClassConflict
 	ErrClassReadonly
 	ErrClassConstraint
+	ErrClassConnection
 )
 
+// String returns the lower-case name of the class, such as "retryable".
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrClassRetryable:
+		return "retryable"
+	case ErrClassConflict:
+		return "conflict"
+	case ErrClassReadonly:
+		return "readonly"
+	case ErrClassConstraint:
+		return "constraint"
+	case ErrClassConnection:
+		return "connection"
+	}
+	return "unknown"
+}
+
 // Classify classifies error into a high-level class.
 func Classify(err error) ErrorClass {
 	var me *mysql.MySQLError
@@ -38,11 +62,81 @@ func Classify(err error) ErrorClass {
 			1451, // ER_ROW_IS_REFERENCED_2
 			3819: // ER_CHECK_CONSTRAINT_VIOLATED
 			return ErrClassConstraint
+		// Server going away
+		case 1053: // ER_SERVER_SHUTDOWN
+			return ErrClassConnection
 		}
 	}
+	if isConnectionError(err) {
+		return ErrClassConnection
+	}
 	return ErrClassUnknown
 }
 
+// isConnectionError reports whether err indicates a broken or unreachable
+// server connection rather than a statement-level failure.
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// erQueryTimeout is ER_QUERY_TIMEOUT, returned for a SELECT interrupted
+// by its maximum execution time.
+const erQueryTimeout = 3024
+
+// QueryTimeoutError is returned for a SELECT the server interrupted
+// because it ran longer than its maximum execution time, set by a
+// MAX_EXECUTION_TIME hint (see WithMaxExecutionTime) or by the
+// max_execution_time server variable.
+type QueryTimeoutError struct {
+	Limit time.Duration // limit from the statement's hint; 0 if set by the server
+	Err   error         // the driver error
+}
+
+// Error implements error.
+func (e *QueryTimeoutError) Error() string {
+	if e.Limit > 0 {
+		return fmt.Sprintf("query exceeded maximum execution time of %v: %v", e.Limit, e.Err)
+	}
+	return fmt.Sprintf("query exceeded maximum execution time: %v", e.Err)
+}
+
+// Unwrap returns the driver error.
+func (e *QueryTimeoutError) Unwrap() error { return e.Err }
+
+// Timeout reports true, like net.Error.
+func (e *QueryTimeoutError) Timeout() bool { return true }
+
+// IsQueryTimeout reports whether err is a *QueryTimeoutError or the
+// driver error it wraps, as returned by Rows.Err and Row.Scan.
+func IsQueryTimeout(err error) bool {
+	var te *QueryTimeoutError
+	if errors.As(err, &te) {
+		return true
+	}
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == erQueryTimeout
+}
+
+// wrapQueryTimeout returns a *QueryTimeoutError for an ER_QUERY_TIMEOUT
+// error of query, and err unchanged otherwise.
+func wrapQueryTimeout(err error, query string) error {
+	var me *mysql.MySQLError
+	if err == nil || !errors.As(err, &me) || me.Number != erQueryTimeout {
+		return err
+	}
+	return &QueryTimeoutError{Limit: queryMaxExecutionTime(query), Err: err}
+}
+
 // adapt wraps driver error into local mysqlMySQLError for decoupled checks.
 func adapt(err error) error {
 	var me *mysql.MySQLError
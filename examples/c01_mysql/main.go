@@ -40,5 +40,5 @@ func main() {
 	if err := pool.Ping(ctx); err != nil {
 		log.Fatalf("Ping失败: %v", err)
 	}
-	fmt.Println("✅ 数据库连接成功! DSN:", ggm.GetDSN())
+	fmt.Println("✅ 数据库连接成功! DSN:", pool.RedactedDSN())
 }
@@ -33,5 +33,5 @@ func main() {
 	}
 
 	fmt.Println("✅ 数据库连接成功!")
-	fmt.Println("数据库连接信息：", ggm.GetDSN())
+	fmt.Println("数据库连接信息：", pool.RedactedDSN())
 }
@@ -0,0 +1,202 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExplainPlan is the parsed output of EXPLAIN FORMAT=JSON.
+type ExplainPlan struct {
+	QueryCost      float64        `json:"query_cost,omitempty"` // optimizer cost estimate
+	Tables         []ExplainTable `json:"tables"`               // accessed tables, in plan order
+	UsingFilesort  bool           `json:"using_filesort"`       // a sort pass is needed
+	UsingTemporary bool           `json:"using_temporary"`      // a temporary table is needed
+	JSON           string         `json:"json,omitempty"`       // raw EXPLAIN output
+}
+
+// ExplainTable describes how one table is accessed.
+type ExplainTable struct {
+	Table        string   `json:"table"`
+	AccessType   string   `json:"access_type"` // ALL, index, range, ref, eq_ref, const, ...
+	PossibleKeys []string `json:"possible_keys,omitempty"`
+	Key          string   `json:"key,omitempty"` // index used, empty for none
+	RowsExamined int64    `json:"rows_examined"` // estimated rows read per scan
+	RowsProduced int64    `json:"rows_produced"` // estimated rows passed on after filtering
+	Filtered     float64  `json:"filtered"`      // percentage of rows kept by the condition
+}
+
+// ExplainFunc returns the execution plan of query run with args.
+type ExplainFunc func(ctx context.Context, query string, args ...any) (*ExplainPlan, error)
+
+// FullScans returns the tables read with a full table scan (access type ALL).
+func (p *ExplainPlan) FullScans() []ExplainTable {
+	var out []ExplainTable
+	for _, t := range p.Tables {
+		if t.AccessType == "ALL" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// RowsExamined returns the estimated number of rows read by the plan.
+func (p *ExplainPlan) RowsExamined() int64 {
+	var n int64
+	for _, t := range p.Tables {
+		n += t.RowsExamined
+	}
+	return n
+}
+
+// ParseExplainJSON parses the output of EXPLAIN FORMAT=JSON.
+func ParseExplainJSON(data []byte) (*ExplainPlan, error) {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid EXPLAIN output: %w", err)
+	}
+	block, ok := root["query_block"].(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid EXPLAIN output: no query_block")
+	}
+	plan := &ExplainPlan{JSON: string(data)}
+	if cost, ok := block["cost_info"].(map[string]any); ok {
+		plan.QueryCost = explainNumber(cost["query_cost"])
+	}
+	walkExplain(block, plan)
+	return plan, nil
+}
+
+// walkExplain collects tables and flags from every nested plan node.
+func walkExplain(v any, plan *ExplainPlan) {
+	switch node := v.(type) {
+	case map[string]any:
+		if b, _ := node["using_filesort"].(bool); b {
+			plan.UsingFilesort = true
+		}
+		if b, _ := node["using_temporary_table"].(bool); b {
+			plan.UsingTemporary = true
+		}
+		if t, ok := node["table"].(map[string]any); ok {
+			plan.Tables = append(plan.Tables, explainTable(t))
+		}
+		// Visit children in a stable order so the table order is deterministic
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			if k != "table" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		if t, ok := node["table"]; ok {
+			walkChildren(t, plan)
+		}
+		for _, k := range keys {
+			walkExplain(node[k], plan)
+		}
+	case []any:
+		for _, item := range node {
+			walkExplain(item, plan)
+		}
+	}
+}
+
+// walkChildren visits subqueries attached to a table node, in key order.
+func walkChildren(v any, plan *ExplainPlan) {
+	t, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	keys := make([]string, 0, len(t))
+	for k, child := range t {
+		switch child.(type) {
+		case map[string]any, []any:
+			if k != "cost_info" {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		walkExplain(t[k], plan)
+	}
+}
+
+// explainTable converts a "table" node.
+func explainTable(t map[string]any) ExplainTable {
+	out := ExplainTable{
+		Table:        explainString(t["table_name"]),
+		AccessType:   explainString(t["access_type"]),
+		Key:          explainString(t["key"]),
+		RowsExamined: int64(explainNumber(t["rows_examined_per_scan"])),
+		RowsProduced: int64(explainNumber(t["rows_produced_per_join"])),
+		Filtered:     explainNumber(t["filtered"]),
+	}
+	if keys, ok := t["possible_keys"].([]any); ok {
+		for _, k := range keys {
+			out.PossibleKeys = append(out.PossibleKeys, explainString(k))
+		}
+	}
+	return out
+}
+
+// explainString returns v as a string; EXPLAIN uses strings and numbers.
+func explainString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	}
+	return ""
+}
+
+// explainNumber returns v as a number; MySQL 5.7 quotes most numbers.
+func explainNumber(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
+
+// explainable reports whether EXPLAIN can be run for query, which must be
+// a SELECT, UPDATE or DELETE statement.
+func explainable(query string) bool {
+	toks := sqlTokens(query)
+	if len(toks) == 0 {
+		return false
+	}
+	switch strings.ToUpper(toks[0]) {
+	case "SELECT", "UPDATE", "DELETE":
+		return true
+	}
+	return false
+}
+
+// Explain runs EXPLAIN FORMAT=JSON for query with args on a pooled
+// connection and returns the parsed plan. Only SELECT, UPDATE and DELETE
+// statements can be explained; the statement itself is not executed.
+func (p *Pool) Explain(ctx context.Context, query string, args ...any) (*ExplainPlan, error) {
+	db := p.getDB()
+	if db == nil {
+		return nil, errors.New("nil pool")
+	}
+	ctx = internalContext(ctx)
+	if !explainable(query) {
+		return nil, errors.New("only SELECT, UPDATE and DELETE statements can be explained")
+	}
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	var out string
+	if err := db.QueryRowContext(ctx, "EXPLAIN FORMAT=JSON "+query, args...).Scan(&out); err != nil {
+		return nil, fmt.Errorf("explain failed: %w", err)
+	}
+	return ParseExplainJSON([]byte(out))
+}
@@ -0,0 +1,185 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const sampleExplainJSON = `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {"query_cost": "1045.25"},
+    "ordering_operation": {
+      "using_filesort": true,
+      "grouping_operation": {
+        "using_temporary_table": true,
+        "nested_loop": [
+          {
+            "table": {
+              "table_name": "orders",
+              "access_type": "ALL",
+              "possible_keys": ["idx_customer"],
+              "rows_examined_per_scan": 10230,
+              "rows_produced_per_join": 1023,
+              "filtered": "10.00"
+            }
+          },
+          {
+            "table": {
+              "table_name": "customers",
+              "access_type": "eq_ref",
+              "possible_keys": ["PRIMARY"],
+              "key": "PRIMARY",
+              "rows_examined_per_scan": 1,
+              "rows_produced_per_join": 1023,
+              "filtered": "100.00"
+            }
+          }
+        ]
+      }
+    }
+  }
+}`
+
+func TestParseExplainJSON(t *testing.T) {
+	plan, err := ParseExplainJSON([]byte(sampleExplainJSON))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if plan.QueryCost != 1045.25 || !plan.UsingFilesort || !plan.UsingTemporary {
+		t.Fatalf("plan flags: %+v", plan)
+	}
+	if len(plan.Tables) != 2 {
+		t.Fatalf("expected 2 tables, got %+v", plan.Tables)
+	}
+	orders, customers := plan.Tables[0], plan.Tables[1]
+	if orders.Table != "orders" || orders.AccessType != "ALL" || orders.Key != "" || orders.RowsExamined != 10230 || orders.Filtered != 10 {
+		t.Fatalf("orders: %+v", orders)
+	}
+	if customers.Key != "PRIMARY" || customers.AccessType != "eq_ref" || customers.RowsExamined != 1 {
+		t.Fatalf("customers: %+v", customers)
+	}
+	if len(plan.FullScans()) != 1 || plan.RowsExamined() != 10231 {
+		t.Fatalf("full scans %v rows %d", plan.FullScans(), plan.RowsExamined())
+	}
+
+	if _, err := ParseExplainJSON([]byte(`{"foo": 1}`)); err == nil {
+		t.Fatalf("expected error without query_block")
+	}
+}
+
+func TestParseExplainJSON_SubqueryOrder(t *testing.T) {
+	const doc = `{"query_block": {"table": {
+		"table_name": "t",
+		"access_type": "ALL",
+		"attached_subqueries": [{"query_block": {"table": {"table_name": "a"}}}],
+		"materialized_from_subquery": {"query_block": {"table": {"table_name": "m"}}},
+		"optimized_away_subqueries": [{"query_block": {"table": {"table_name": "o"}}}]
+	}}}`
+	for i := 0; i < 20; i++ {
+		plan, err := ParseExplainJSON([]byte(doc))
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		var names []string
+		for _, tbl := range plan.Tables {
+			names = append(names, tbl.Table)
+		}
+		if got := strings.Join(names, ","); got != "t,a,m,o" {
+			t.Fatalf("table order %s, want t,a,m,o", got)
+		}
+	}
+}
+
+func TestExplainable(t *testing.T) {
+	for q, want := range map[string]bool{
+		"SELECT * FROM t":          true,
+		"  update t SET a = 1":     true,
+		"/* tag */ DELETE FROM t":  true,
+		"INSERT INTO t VALUES (1)": false,
+		"SET @a = 1":               false,
+		"":                         false,
+	} {
+		if got := explainable(q); got != want {
+			t.Fatalf("explainable(%q)=%v want %v", q, got, want)
+		}
+	}
+}
+
+func TestSlowQueryRecorder_CaptureExplain(t *testing.T) {
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = time.Millisecond
+	config.CaptureExplain = true
+	config.ExplainInterval = time.Hour
+	storage := NewMemorySlowQueryStorage(100)
+	recorder := NewSlowQueryRecorder(config, storage)
+
+	var calls atomic.Int32
+	recorder.SetExplainer(func(ctx context.Context, query string, args ...any) (*ExplainPlan, error) {
+		calls.Add(1)
+		return ParseExplainJSON([]byte(sampleExplainJSON))
+	})
+
+	ctx := context.Background()
+	recorder.Record(ctx, "SELECT * FROM orders WHERE customer = ?", []interface{}{1}, 10*time.Millisecond, nil)
+	recorder.Record(ctx, "SELECT * FROM orders WHERE customer = ?", []interface{}{2}, 10*time.Millisecond, nil)
+	recorder.Record(ctx, "INSERT INTO orders VALUES (?)", []interface{}{3}, 10*time.Millisecond, nil)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("EXPLAIN should run once per pattern, ran %d times", n)
+	}
+	records, _ := storage.GetRecords(ctx, SlowQueryFilter{})
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	var withPlan int
+	for _, r := range records {
+		if r.Plan != nil {
+			withPlan++
+			if r.Plan.JSON != "" {
+				t.Fatalf("raw plan should be dropped when arguments are sanitized")
+			}
+		}
+	}
+	if withPlan != 1 {
+		t.Fatalf("expected 1 record with a plan, got %d", withPlan)
+	}
+}
+
+func TestSlowQueryAnalyzer_PlanRecommendations(t *testing.T) {
+	storage := NewMemorySlowQueryStorage(100)
+	plan, err := ParseExplainJSON([]byte(sampleExplainJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	storage.Store(ctx, &SlowQueryRecord{
+		ID:              "1",
+		Query:           "SELECT * FROM orders WHERE note LIKE ?",
+		NormalizedQuery: "SELECT * FROM ORDERS WHERE NOTE LIKE ?",
+		Duration:        200 * time.Millisecond,
+		Timestamp:       time.Now(),
+		Plan:            plan,
+	})
+
+	report, err := NewSlowQueryAnalyzer(storage).GenerateReport(ctx, SlowQueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := strings.Join(report.Recommendations, "\n")
+	for _, want := range []string{"Full table scan of orders", "idx_customer", "filesort", "temporary table"} {
+		if !strings.Contains(all, want) {
+			t.Fatalf("missing %q in recommendations:\n%s", want, all)
+		}
+	}
+	if strings.Contains(all, "Avoid SELECT *") || strings.Contains(all, "LIKE patterns") {
+		t.Fatalf("keyword heuristics should not be used when a plan exists:\n%s", all)
+	}
+}
@@ -0,0 +1,373 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+// FailoverPolicy selects the order in which Config.Hosts are tried.
+type FailoverPolicy string
+
+const (
+	// FailoverOrdered tries hosts in the order they are listed, starting
+	// after the current host and wrapping around.
+	FailoverOrdered FailoverPolicy = "ordered"
+	// FailoverRandom tries hosts in random order.
+	FailoverRandom FailoverPolicy = "random"
+	// FailoverPreferWritable tries hosts in order but prefers a host whose
+	// @@read_only is off. If every reachable host is read-only, the first
+	// reachable one is used.
+	FailoverPreferWritable FailoverPolicy = "prefer_writable"
+)
+
+// ErrNoReachableHost is returned when no host in Config.Hosts accepts connections.
+var ErrNoReachableHost = errors.New("no reachable host")
+
+// failoverProbeTimeout bounds the connectivity check of a single candidate host.
+const failoverProbeTimeout = 5 * time.Second
+
+// newFailoverPool opens a pool against the first usable host of cfg.Hosts.
+func newFailoverPool(ctx context.Context, cfg Config) (*Pool, error) {
+	hosts := normalizeHosts(cfg.Hosts, cfg.Port)
+	if len(hosts) == 0 {
+		return nil, errors.New("config Hosts contains no usable host")
+	}
+	switch cfg.FailoverPolicy {
+	case "", FailoverOrdered, FailoverRandom, FailoverPreferWritable:
+	default:
+		return nil, fmt.Errorf("unknown failover policy %q", cfg.FailoverPolicy)
+	}
+	p := &Pool{cfg: cfg, hosts: hosts, hostIdx: -1, retry: cfg.Retry, stmtStats: newStatementStats(cfg)}
+	db, idx, dsn, err := p.connectHost(ctx, failoverCandidates(cfg.FailoverPolicy, len(hosts), -1), true)
+	if err != nil {
+		return nil, err
+	}
+	p.db = db
+	p.hostIdx = idx
+	p.dsn = dsn
+	if err := p.startBackground(); err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// normalizeHosts trims the entries of hosts, drops empty ones and adds
+// defaultPort (or 3306) to entries without an explicit port.
+func normalizeHosts(hosts []string, defaultPort int) []string {
+	if defaultPort <= 0 {
+		defaultPort = 3306
+	}
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			h = net.JoinHostPort(strings.Trim(h, "[]"), strconv.Itoa(defaultPort))
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// dsnForHost returns the DSN of cfg pointed at host (host:port).
+func dsnForHost(cfg Config, host string) (string, error) {
+	if strings.TrimSpace(cfg.DSN) != "" {
+		mc, err := mysql.ParseDSN(cfg.DSN)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DSN: %w", err)
+		}
+		mc.Net = "tcp"
+		mc.Addr = host
+		return mc.FormatDSN(), nil
+	}
+	c := cfg
+	c.Host = host
+	c.Port = 0
+	return dsnFromConfig(c)
+}
+
+// failoverCandidates returns the host indexes to try, in order. The current
+// host is tried last so that a recovered server can still be picked up.
+func failoverCandidates(policy FailoverPolicy, n, current int) []int {
+	order := make([]int, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (current + i) % n
+		if current < 0 {
+			idx = i - 1
+		}
+		if idx == current {
+			continue
+		}
+		order = append(order, idx)
+	}
+	if policy == FailoverRandom {
+		rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	}
+	if current >= 0 && current < n {
+		order = append(order, current)
+	}
+	return order
+}
+
+// connectHost opens the first usable host from candidates and returns the
+// handle together with the chosen host index and its DSN. With ensureDB set the target
+// database is auto-created on each candidate before connecting.
+func (p *Pool) connectHost(ctx context.Context, candidates []int, ensureDB bool) (*sql.DB, int, string, error) {
+	var (
+		fallback    *sql.DB
+		fallbackIdx = -1
+		fallbackDSN string
+		errs        []error
+	)
+	for _, idx := range candidates {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		dsn, err := dsnForHost(p.cfg, p.hosts[idx])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ensureDB {
+			if err := ensureDatabaseExists(ctx, p.cfg, dsn); err != nil {
+				errs = append(errs, fmt.Errorf("%s: database auto-creation failed: %w", p.hosts[idx], err))
+				continue
+			}
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, failoverProbeTimeout)
+		db, err := openDB(pingCtx, p.cfg, dsn, p)
+		if err != nil {
+			cancel()
+			errs = append(errs, fmt.Errorf("%s: %w", p.hosts[idx], err))
+			continue
+		}
+		if p.cfg.FailoverPolicy != FailoverPreferWritable {
+			cancel()
+			lastUsedDSN.Store(dsn)
+			return db, idx, dsn, nil
+		}
+		readOnly, err := isReadOnly(pingCtx, db)
+		cancel()
+		if err == nil && !readOnly {
+			if fallback != nil {
+				_ = fallback.Close()
+			}
+			lastUsedDSN.Store(dsn)
+			return db, idx, dsn, nil
+		}
+		if fallback == nil {
+			fallback, fallbackIdx, fallbackDSN = db, idx, dsn
+			continue
+		}
+		_ = db.Close()
+	}
+	if fallback != nil {
+		lastUsedDSN.Store(fallbackDSN)
+		return fallback, fallbackIdx, fallbackDSN, nil
+	}
+	return nil, -1, "", fmt.Errorf("%w: %w", ErrNoReachableHost, errors.Join(errs...))
+}
+
+// erUnknownSystemVariable is returned for system variables the server does
+// not have, such as super_read_only on MariaDB.
+const erUnknownSystemVariable = 1193
+
+// isReadOnly reports whether the server behind db has @@global.read_only or
+// @@global.super_read_only set. Servers without super_read_only are judged
+// by read_only alone.
+func isReadOnly(ctx context.Context, db *sql.DB) (bool, error) {
+	ctx = internalContext(ctx)
+	var v int
+	err := db.QueryRowContext(ctx, "SELECT @@global.read_only OR @@global.super_read_only").Scan(&v)
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == erUnknownSystemVariable {
+		err = db.QueryRowContext(ctx, "SELECT @@global.read_only").Scan(&v)
+	}
+	if err != nil {
+		return false, err
+	}
+	return v != 0, nil
+}
+
+// CurrentHost returns the host:port the pool is connected to, or an empty
+// string for pools that were not configured with Config.Hosts.
+func (p *Pool) CurrentHost() string {
+	if p == nil {
+		return ""
+	}
+	p.dbMu.RLock()
+	defer p.dbMu.RUnlock()
+	if p.hostIdx < 0 || p.hostIdx >= len(p.hosts) {
+		return ""
+	}
+	return p.hosts[p.hostIdx]
+}
+
+// AddFailoverHandler registers a handler that receives the
+// ProbeEventFailover* events emitted when the pool switches hosts, and the
+// ProbeEventRebuild* events emitted by Rebuild.
+func (p *Pool) AddFailoverHandler(handler ProbeEventHandler) {
+	if p == nil || handler == nil {
+		return
+	}
+	p.failoverHandlersMu.Lock()
+	p.failoverHandlers = append(p.failoverHandlers, handler)
+	p.failoverHandlersMu.Unlock()
+}
+
+// removeFailoverHandler unregisters a handler added with AddFailoverHandler.
+func (p *Pool) removeFailoverHandler(handler ProbeEventHandler) {
+	if p == nil {
+		return
+	}
+	p.failoverHandlersMu.Lock()
+	defer p.failoverHandlersMu.Unlock()
+	for i, h := range p.failoverHandlers {
+		if h == handler {
+			p.failoverHandlers = append(p.failoverHandlers[:i], p.failoverHandlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// emitFailoverEvent delivers a failover event to all registered handlers.
+// Probes are notified synchronously, which keeps their Events channels in
+// order; they do not block. Other handlers are called from a background
+// goroutine, one event after the other in the order they were emitted.
+func (p *Pool) emitFailoverEvent(eventType ProbeEventType, from, to, message string, err error) {
+	event := ProbeEvent{
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Message:   RedactString(message),
+		Error:     redactError(err),
+		FromHost:  from,
+		ToHost:    to,
+	}
+	p.failoverHandlersMu.RLock()
+	handlers := append([]ProbeEventHandler(nil), p.failoverHandlers...)
+	p.failoverHandlersMu.RUnlock()
+	external := handlers[:0]
+	for _, handler := range handlers {
+		if f, ok := handler.(*failoverForwarder); ok {
+			f.HandleProbeEvent(event)
+			continue
+		}
+		external = append(external, handler)
+	}
+	if len(external) == 0 {
+		return
+	}
+
+	p.failoverQueueMu.Lock()
+	defer p.failoverQueueMu.Unlock()
+	p.failoverQueue = append(p.failoverQueue, failoverDelivery{event: event, handlers: external})
+	if !p.failoverDelivering {
+		p.failoverDelivering = true
+		go p.deliverFailoverEvents()
+	}
+}
+
+// failoverDelivery is a failover event queued for handlers.
+type failoverDelivery struct {
+	event    ProbeEvent
+	handlers []ProbeEventHandler
+}
+
+// deliverFailoverEvents calls the handlers of queued events in order,
+// until the queue is empty.
+func (p *Pool) deliverFailoverEvents() {
+	for {
+		p.failoverQueueMu.Lock()
+		if len(p.failoverQueue) == 0 {
+			p.failoverDelivering = false
+			p.failoverQueueMu.Unlock()
+			return
+		}
+		d := p.failoverQueue[0]
+		p.failoverQueue = p.failoverQueue[1:]
+		p.failoverQueueMu.Unlock()
+		for _, handler := range d.handlers {
+			handler.HandleProbeEvent(d.event)
+		}
+	}
+}
+
+// Failover switches the pool to the next reachable host according to
+// Config.FailoverPolicy. Connections already borrowed from the previous
+// host keep working until they are closed; new work uses the new host.
+//
+// It returns an error if the pool was not configured with Config.Hosts or
+// if no host could be reached, in which case the current handle is kept.
+func (p *Pool) Failover(ctx context.Context) error {
+	return p.failoverFrom(ctx, p.getDB(), nil)
+}
+
+// canFailover reports whether the pool has more than one host to switch to.
+func (p *Pool) canFailover() bool {
+	return p != nil && len(p.hosts) > 1
+}
+
+// shouldFailover reports whether err warrants switching hosts.
+func (p *Pool) shouldFailover(err error) bool {
+	if err == nil || !p.canFailover() {
+		return false
+	}
+	switch Classify(err) {
+	case ErrClassConnection:
+		return true
+	case ErrClassReadonly:
+		return p.cfg.FailoverPolicy == FailoverPreferWritable
+	}
+	return false
+}
+
+// failoverFrom switches hosts unless the handle failed has already been
+// replaced by a concurrent failover, in which case it returns nil.
+func (p *Pool) failoverFrom(ctx context.Context, failed *sql.DB, cause error) error {
+	if p == nil || len(p.hosts) == 0 {
+		return errors.New("failover requires Config.Hosts")
+	}
+	p.failoverMu.Lock()
+	defer p.failoverMu.Unlock()
+
+	p.dbMu.RLock()
+	current, idx, closed := p.db, p.hostIdx, p.closed
+	p.dbMu.RUnlock()
+	if closed {
+		return ErrPoolClosed
+	}
+	if failed != nil && current != failed {
+		return nil
+	}
+
+	from := ""
+	if idx >= 0 && idx < len(p.hosts) {
+		from = p.hosts[idx]
+	}
+	p.emitFailoverEvent(ProbeEventFailoverStarted, from, "", "Failover started", cause)
+
+	db, next, dsn, err := p.connectHost(ctx, failoverCandidates(p.cfg.FailoverPolicy, len(p.hosts), idx), false)
+	if err != nil {
+		p.emitFailoverEvent(ProbeEventFailoverFailed, from, "", "Failover failed", err)
+		return err
+	}
+	if err := p.replaceDB(db, next, dsn); err != nil {
+		return err
+	}
+
+	p.emitFailoverEvent(ProbeEventFailoverSuccess, from, p.hosts[next], fmt.Sprintf("Failed over from %s to %s", from, p.hosts[next]), nil)
+	return nil
+}
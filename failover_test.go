@@ -0,0 +1,254 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+func TestEnv_CommaSeparatedHostEnablesFailover(t *testing.T) {
+	t.Setenv("YGGGO_MYSQL_HOST", "db-1:3306, db-2 ,db-3:3307")
+	t.Setenv("YGGGO_MYSQL_FAILOVER_POLICY", "prefer_writable")
+
+	cfg := Config{}
+	applyEnv(&cfg)
+
+	want := []string{"db-1:3306", "db-2", "db-3:3307"}
+	if len(cfg.Hosts) != len(want) {
+		t.Fatalf("hosts=%v want %v", cfg.Hosts, want)
+	}
+	for i := range want {
+		if cfg.Hosts[i] != want[i] {
+			t.Fatalf("hosts=%v want %v", cfg.Hosts, want)
+		}
+	}
+	if cfg.Host != "db-1:3306" {
+		t.Fatalf("host=%q", cfg.Host)
+	}
+	if cfg.FailoverPolicy != FailoverPreferWritable {
+		t.Fatalf("policy=%q", cfg.FailoverPolicy)
+	}
+}
+
+func TestNormalizeHosts_AddsDefaultPort(t *testing.T) {
+	got := normalizeHosts([]string{"a", " b:3307 ", "", "::1", "[::2]"}, 0)
+	want := []string{"a:3306", "b:3307", "[::1]:3306", "[::2]:3306"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+	if got := normalizeHosts([]string{"a"}, 3310); got[0] != "a:3310" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestDSNForHost_FieldsAndRawDSN(t *testing.T) {
+	cfg := Config{Host: "ignored", Port: 3306, Username: "u", Password: "p", Database: "app"}
+	dsn, err := dsnForHost(cfg, "db-2:3307")
+	if err != nil {
+		t.Fatalf("dsnForHost: %v", err)
+	}
+	mc, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("ParseDSN: %v", err)
+	}
+	if mc.Addr != "db-2:3307" || mc.User != "u" || mc.DBName != "app" {
+		t.Fatalf("unexpected config %+v", mc)
+	}
+
+	cfg = Config{DSN: "u:p@tcp(db-1:3306)/app?parseTime=true"}
+	dsn, err = dsnForHost(cfg, "db-3:3306")
+	if err != nil {
+		t.Fatalf("dsnForHost: %v", err)
+	}
+	mc, err = mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("ParseDSN: %v", err)
+	}
+	if mc.Addr != "db-3:3306" || mc.Passwd != "p" || !mc.ParseTime {
+		t.Fatalf("unexpected config %+v", mc)
+	}
+}
+
+func TestIsReadOnly_SuperReadOnly(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeServer()
+	s.set("SELECT @@global.read_only OR @@global.super_read_only", serverResult{cols: []string{"v"}, rows: [][]driver.Value{{int64(1)}}})
+	if ro, err := isReadOnly(ctx, s.pool(t).getDB()); err != nil || !ro {
+		t.Fatalf("super_read_only: %v, %v", ro, err)
+	}
+
+	// MariaDB has no super_read_only
+	s = newFakeServer()
+	s.set("SELECT @@global.read_only OR", serverResult{err: &mysql.MySQLError{Number: erUnknownSystemVariable, Message: "Unknown system variable 'super_read_only'"}})
+	s.set("SELECT @@global.read_only", serverResult{cols: []string{"v"}, rows: [][]driver.Value{{int64(0)}}})
+	if ro, err := isReadOnly(ctx, s.pool(t).getDB()); err != nil || ro {
+		t.Fatalf("read_only fallback: %v, %v", ro, err)
+	}
+}
+
+func TestFailoverCandidates_Order(t *testing.T) {
+	eq := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	if got := failoverCandidates(FailoverOrdered, 3, -1); !eq(got, []int{0, 1, 2}) {
+		t.Fatalf("initial order %v", got)
+	}
+	if got := failoverCandidates(FailoverOrdered, 3, 1); !eq(got, []int{2, 0, 1}) {
+		t.Fatalf("failover order %v", got)
+	}
+	if got := failoverCandidates(FailoverPreferWritable, 3, 2); !eq(got, []int{0, 1, 2}) {
+		t.Fatalf("prefer_writable order %v", got)
+	}
+	got := failoverCandidates(FailoverRandom, 4, 1)
+	if len(got) != 4 || got[3] != 1 {
+		t.Fatalf("random order must keep current host last: %v", got)
+	}
+}
+
+func TestClassify_ConnectionErrors(t *testing.T) {
+	cases := []error{
+		driver.ErrBadConn,
+		mysql.ErrInvalidConn,
+		&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+		&mysql.MySQLError{Number: 1053},
+	}
+	for _, err := range cases {
+		if got := Classify(err); got != ErrClassConnection {
+			t.Fatalf("classify(%v)=%v want connection", err, got)
+		}
+	}
+	if got := Classify(context.DeadlineExceeded); got == ErrClassConnection {
+		t.Fatalf("context deadline must not be a connection error")
+	}
+}
+
+type recordingProbeHandler struct {
+	mu     sync.Mutex
+	events []ProbeEvent
+}
+
+func (h *recordingProbeHandler) HandleProbeEvent(event ProbeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *recordingProbeHandler) types() []ProbeEventType {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]ProbeEventType, 0, len(h.events))
+	for _, e := range h.events {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func TestFailover_NoReachableHostEmitsEvents(t *testing.T) {
+	cfg := Config{Driver: "mysql", Username: "u", Password: "p"}
+	p := &Pool{cfg: cfg, hosts: []string{"127.0.0.1:1", "127.0.0.1:2"}, hostIdx: 0}
+	h := &recordingProbeHandler{}
+	p.AddFailoverHandler(h)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := p.Failover(ctx)
+	if !errors.Is(err, ErrNoReachableHost) {
+		t.Fatalf("expected ErrNoReachableHost, got %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(h.types()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	types := h.types()
+	if len(types) != 2 || types[0] != ProbeEventFailoverStarted || types[1] != ProbeEventFailoverFailed {
+		t.Fatalf("unexpected events %v", types)
+	}
+	if p.CurrentHost() != "127.0.0.1:1" {
+		t.Fatalf("current host changed to %q", p.CurrentHost())
+	}
+}
+
+func TestFailover_RequiresHosts(t *testing.T) {
+	p := &Pool{}
+	if err := p.Failover(context.Background()); err == nil {
+		t.Fatalf("expected error for pool without hosts")
+	}
+	if p.CurrentHost() != "" {
+		t.Fatalf("expected empty current host")
+	}
+}
+
+func TestFailover_RetriesTransactionOnce(t *testing.T) {
+	ctx := context.Background()
+	p := &Pool{cfg: Config{Driver: "enhanced_fake", Username: "u"}, hosts: []string{"db1:3306", "db2:3306"}, hostIdx: 0}
+	db, err := openDB(ctx, p.cfg, "dsn", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.db = db
+	t.Cleanup(func() { p.Close() })
+	h := &recordingProbeHandler{}
+	p.AddFailoverHandler(h)
+
+	// Run again on the new host after a connection error
+	calls := 0
+	err = p.WithinTx(ctx, func(tx DatabaseTx) error {
+		calls++
+		if calls == 1 {
+			return mysql.ErrInvalidConn
+		}
+		return nil
+	})
+	if err != nil || calls != 2 || p.CurrentHost() != "db2:3306" {
+		t.Fatalf("err %v, calls %d, host %q", err, calls, p.CurrentHost())
+	}
+
+	// Only once, and the second failure does not fail over without a retry
+	calls = 0
+	err = p.WithinTx(ctx, func(tx DatabaseTx) error {
+		calls++
+		return mysql.ErrInvalidConn
+	})
+	if !errors.Is(err, mysql.ErrInvalidConn) || calls != 2 || p.CurrentHost() != "db1:3306" {
+		t.Fatalf("err %v, calls %d, host %q", err, calls, p.CurrentHost())
+	}
+
+	// Events of the two failovers arrive in order
+	var want []ProbeEventType
+	for i := 0; i < 2; i++ {
+		want = append(want, ProbeEventFailoverStarted, ProbeEventFailoverSuccess)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(h.types()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	types := h.types()
+	if len(types) != len(want) {
+		t.Fatalf("events %v", types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events %v", types)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeServerDriver is the driver name under which fake servers are opened
+// by DSN, for code that opens connections itself, such as openDB.
+const fakeServerDriver = "fake_server"
+
+// fakeServers maps the DSNs handed out by fakeServer.dsn to their servers.
+var fakeServers sync.Map
+
+func init() { sql.Register(fakeServerDriver, fakeServerDriverImpl{}) }
+
+type fakeServerDriverImpl struct{}
+
+func (fakeServerDriverImpl) Open(dsn string) (driver.Conn, error) {
+	s, err := lookupFakeServer(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return s.Connect(context.Background())
+}
+
+func (fakeServerDriverImpl) OpenConnector(dsn string) (driver.Connector, error) {
+	return lookupFakeServer(dsn)
+}
+
+func lookupFakeServer(dsn string) (*fakeServer, error) {
+	s, ok := fakeServers.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("fake server %q is not registered", dsn)
+	}
+	return s.(*fakeServer), nil
+}
+
+// serverResult is a canned result set of a fakeServer.
+type serverResult struct {
+	cols     []string
+	rows     [][]driver.Value
+	affected int64 // rows affected by statements run with Exec
+	err      error
+	block    bool // wait for the context to be done, like a statement that runs until canceled
+}
+
+// fakeServer answers queries with canned results, matched by the longest
+// registered query prefix; the empty prefix matches every query. Unknown
+// queries fail. SELECT CONNECTION_ID() returns the ID of the connection,
+// numbered from 1 in the order connections are opened.
+type fakeServer struct {
+	mu      sync.Mutex
+	results map[string]serverResult
+	queries []string
+	conns   []*fakeServerConn
+	nextID  atomic.Int64
+	stale   atomic.Int64 // Ping fails on connections with an ID up to this
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{results: make(map[string]serverResult)}
+}
+
+// set registers the result of queries starting with prefix.
+func (s *fakeServer) set(prefix string, r serverResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[prefix] = r
+}
+
+// setGlobals answers SHOW GLOBAL kind queries with vars.
+func (s *fakeServer) setGlobals(kind string, vars map[string]string) {
+	r := serverResult{cols: []string{"Variable_name", "Value"}}
+	for k, v := range vars {
+		r.rows = append(r.rows, []driver.Value{k, v})
+	}
+	s.set("SHOW GLOBAL "+kind, r)
+}
+
+// executed returns the queries run so far that start with prefix.
+func (s *fakeServer) executed(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, q := range s.queries {
+		if strings.HasPrefix(q, prefix) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// connections returns the connections opened so far.
+func (s *fakeServer) connections() []*fakeServerConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*fakeServerConn(nil), s.conns...)
+}
+
+// connQueries returns the queries run on c.
+func (s *fakeServer) connQueries(c *fakeServerConn) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), c.queries...)
+}
+
+func (s *fakeServer) lookup(c *fakeServerConn, query string) serverResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, query)
+	c.queries = append(c.queries, query)
+	if query == "SELECT CONNECTION_ID()" {
+		return serverResult{cols: []string{"id"}, rows: [][]driver.Value{{c.id}}}
+	}
+	best, found := "", false
+	for p := range s.results {
+		if strings.HasPrefix(query, p) && (!found || len(p) > len(best)) {
+			best, found = p, true
+		}
+	}
+	if !found {
+		return serverResult{err: fmt.Errorf("fake server: unexpected query %q", query)}
+	}
+	r := s.results[best]
+	if strings.HasPrefix(best, "SHOW GLOBAL") && strings.Contains(query, " WHERE ") && r.err == nil {
+		// Honor the WHERE Variable_name IN (...) filter
+		filtered := serverResult{cols: r.cols}
+		for _, row := range r.rows {
+			if strings.Contains(query, "'"+row[0].(string)+"'") {
+				filtered.rows = append(filtered.rows, row)
+			}
+		}
+		return filtered
+	}
+	return r
+}
+
+// pool returns a pool backed by the fake server.
+func (s *fakeServer) pool(t *testing.T) *Pool {
+	t.Helper()
+	db := sql.OpenDB(s)
+	t.Cleanup(func() { db.Close() })
+	return &Pool{db: db}
+}
+
+// breakConnections makes Ping fail on the connections opened so far.
+func (s *fakeServer) breakConnections() {
+	s.stale.Store(s.nextID.Load())
+}
+
+// dsn registers s for the fakeServerDriver and returns its DSN.
+func (s *fakeServer) dsn(t *testing.T) string {
+	t.Helper()
+	dsn := fmt.Sprintf("%s/%p", t.Name(), s)
+	fakeServers.Store(dsn, s)
+	t.Cleanup(func() { fakeServers.Delete(dsn) })
+	return dsn
+}
+
+func (s *fakeServer) Connect(context.Context) (driver.Conn, error) {
+	c := &fakeServerConn{s: s, id: s.nextID.Add(1)}
+	s.mu.Lock()
+	s.conns = append(s.conns, c)
+	s.mu.Unlock()
+	return c, nil
+}
+func (s *fakeServer) Driver() driver.Driver { return fakeServerDriverImpl{} }
+
+type fakeServerConn struct {
+	s       *fakeServer
+	id      int64
+	queries []string // guarded by s.mu
+}
+
+func (c *fakeServerConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeServerStmt{c: c, query: query}, nil
+}
+func (c *fakeServerConn) Close() error              { return nil }
+func (c *fakeServerConn) Begin() (driver.Tx, error) { return fakeServerTx{}, nil }
+
+func (c *fakeServerConn) Ping(context.Context) error {
+	if c.id <= c.s.stale.Load() {
+		return errors.New("connection is stale")
+	}
+	return nil
+}
+
+func (c *fakeServerConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	r, err := c.run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeServerRows{res: r}, nil
+}
+
+func (c *fakeServerConn) ExecContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	r, err := c.run(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(r.affected), nil
+}
+
+func (c *fakeServerConn) run(ctx context.Context, query string) (serverResult, error) {
+	r := c.s.lookup(c, query)
+	if r.block {
+		<-ctx.Done()
+		return r, ctx.Err()
+	}
+	return r, r.err
+}
+
+// fakeServerStmt runs its query on the fake server each time it is
+// executed.
+type fakeServerStmt struct {
+	c     *fakeServerConn
+	query string
+}
+
+func (s *fakeServerStmt) Close() error  { return nil }
+func (s *fakeServerStmt) NumInput() int { return -1 }
+func (s *fakeServerStmt) Exec([]driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, nil)
+}
+func (s *fakeServerStmt) Query([]driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, nil)
+}
+
+type fakeServerTx struct{}
+
+func (fakeServerTx) Commit() error   { return nil }
+func (fakeServerTx) Rollback() error { return nil }
+
+type fakeServerRows struct {
+	res serverResult
+	i   int
+}
+
+func (r *fakeServerRows) Columns() []string { return r.res.cols }
+func (r *fakeServerRows) Close() error      { return nil }
+func (r *fakeServerRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.res.rows) {
+		return io.EOF
+	}
+	copy(dest, r.res.rows[r.i])
+	r.i++
+	return nil
+}
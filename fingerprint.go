@@ -0,0 +1,309 @@
+package ygggo_mysql
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Normalization modes for SlowQueryConfig.NormalizationMode.
+const (
+	// NormalizeNone keeps queries unchanged.
+	NormalizeNone = "none"
+	// NormalizeBasic replaces literals with '?', collapses IN and VALUES
+	// lists, strips comments and upper-cases the statement. Quoted
+	// identifiers keep their case.
+	NormalizeBasic = "basic"
+	// NormalizeFingerprint produces pt-fingerprint style output: like
+	// NormalizeBasic, but lower-cased and without identifier quotes.
+	NormalizeFingerprint = "fingerprint"
+)
+
+// FingerprintQuery returns the fingerprint of query in the given
+// normalization mode. Queries that differ only in literal values, the
+// length of IN (...) lists, the number of VALUES rows, comments or
+// whitespace have the same fingerprint:
+//
+//	SELECT * FROM t WHERE a IN (1, 2) AND b = "x" -- note
+//	select * from t where a in (3,4,5) and b = 'y'
+//
+// both become "SELECT * FROM T WHERE A IN (?+) AND B = ?" in basic mode.
+// Optimizer hints (/*+ */) are stripped like comments, so a statement
+// run with and without a MAX_EXECUTION_TIME hint has one fingerprint;
+// version comments (/*! */) are kept.
+// Unknown modes are treated as NormalizeBasic.
+func FingerprintQuery(query, mode string) string {
+	if mode == NormalizeNone {
+		return query
+	}
+	toks := collapseLists(fingerprintTokens(query))
+
+	var b strings.Builder
+	b.Grow(len(query))
+	for i, t := range toks {
+		if i > 0 && t.space {
+			b.WriteByte(' ')
+		}
+		switch {
+		case t.kind == fpWord && mode == NormalizeFingerprint:
+			b.WriteString(strings.ToLower(t.text))
+		case t.kind == fpWord:
+			b.WriteString(strings.ToUpper(t.text))
+		case t.kind == fpQuoted && mode == NormalizeFingerprint:
+			b.WriteString(strings.ReplaceAll(t.text[1:len(t.text)-1], "``", "`"))
+		default:
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+// QueryDigest returns the 64-bit digest ID of a fingerprint as 16 hex
+// digits. Slow query patterns are keyed by this ID.
+func QueryDigest(fingerprint string) string {
+	h := fnv.New64a()
+	h.Write([]byte(fingerprint))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// fpKind classifies fingerprint tokens.
+type fpKind int
+
+const (
+	fpWord    fpKind = iota // keyword or unquoted identifier
+	fpQuoted                // `quoted identifier`
+	fpLiteral               // literal value or placeholder, rendered as '?'
+	fpPunct                 // operator, parenthesis or other symbol
+	fpHint                  // start or end of a version comment
+)
+
+// fpToken is a token of a fingerprinted query.
+type fpToken struct {
+	text  string
+	kind  fpKind
+	space bool // preceded by whitespace or a comment
+}
+
+// unaryContext lists keywords after which '-' and '+' are signs.
+var unaryContext = map[string]bool{
+	"SELECT": true, "WHERE": true, "AND": true, "OR": true, "NOT": true,
+	"ON": true, "SET": true, "VALUES": true, "VALUE": true, "IN": true,
+	"BETWEEN": true, "LIKE": true, "IS": true, "WHEN": true, "THEN": true,
+	"ELSE": true, "CASE": true, "HAVING": true, "LIMIT": true, "OFFSET": true,
+	"INTERVAL": true, "RETURN": true, "XOR": true, "DIV": true, "MOD": true,
+}
+
+// fingerprintTokens splits query into tokens, dropping comments and
+// replacing literals.
+func fingerprintTokens(query string) []fpToken {
+	var toks []fpToken
+	space := false
+	inHint := false
+	emit := func(text string, kind fpKind) {
+		toks = append(toks, fpToken{text: text, kind: kind, space: space})
+		space = false
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+			i++
+		case c == '-' && strings.HasPrefix(query[i:], "--") && (i+2 == len(query) || strings.IndexByte(" \t\r\n", query[i+2]) >= 0), c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = true
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if i+2 < len(query) && query[i+2] == '!' {
+				j := i + 3
+				for j < len(query) && isDigit(query[j]) {
+					j++
+				}
+				emit(query[i:j], fpHint)
+				inHint = true
+				i = j
+				continue
+			}
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case c == '*' && inHint && strings.HasPrefix(query[i:], "*/"):
+			emit("*/", fpHint)
+			inHint = false
+			i += 2
+		case c == '\'' || c == '"':
+			i = skipQuoted(query, i)
+			emit("?", fpLiteral)
+		case c == '`':
+			j := skipQuoted(query, i)
+			if j-i < 2 || query[j-1] != '`' {
+				// Unterminated: keep what is there
+				emit(query[i:j], fpPunct)
+			} else {
+				emit(query[i:j], fpQuoted)
+			}
+			i = j
+		case c == '?':
+			emit("?", fpLiteral)
+			i++
+		case isDigit(c) || c == '.' && i+1 < len(query) && isDigit(query[i+1]) && !afterName(toks, space):
+			i = skipNumber(query, i)
+			emit("?", fpLiteral)
+		case (c == '-' || c == '+') && i+1 < len(query) && (isDigit(query[i+1]) || query[i+1] == '.') && !afterValue(toks):
+			i = skipNumber(query, i+1)
+			emit("?", fpLiteral)
+		case isIdentByte(c):
+			j := i
+			for j < len(query) && isIdentByte(query[j]) {
+				j++
+			}
+			word := query[i:j]
+			// Hex, bit, national and charset-introduced strings: X'..', _utf8mb4'..'
+			if j < len(query) && query[j] == '\'' && (len(word) == 1 && strings.ContainsAny(word, "xXbBnN") || word[0] == '_') {
+				i = skipQuoted(query, j)
+				emit("?", fpLiteral)
+				continue
+			}
+			emit(word, fpWord)
+			i = j
+		default:
+			emit(string(c), fpPunct)
+			i++
+		}
+	}
+	return toks
+}
+
+// afterValue reports whether the previous token ends an operand, so a
+// following '-' or '+' is a binary operator rather than a sign.
+func afterValue(toks []fpToken) bool {
+	if len(toks) == 0 {
+		return false
+	}
+	prev := toks[len(toks)-1]
+	switch prev.kind {
+	case fpLiteral, fpQuoted:
+		return true
+	case fpWord:
+		return !unaryContext[strings.ToUpper(prev.text)]
+	case fpPunct:
+		return prev.text == ")"
+	}
+	return false
+}
+
+// afterName reports whether a '.' at this point continues a qualified
+// name such as t.5col rather than starting a number like .5.
+func afterName(toks []fpToken, space bool) bool {
+	if space || len(toks) == 0 {
+		return false
+	}
+	prev := toks[len(toks)-1]
+	return prev.kind == fpWord || prev.kind == fpQuoted
+}
+
+// skipNumber returns the end of the numeric literal starting at i:
+// integers, decimals, exponents and 0x/0b literals.
+func skipNumber(query string, i int) int {
+	if strings.HasPrefix(query[i:], "0x") || strings.HasPrefix(query[i:], "0b") {
+		j := i + 2
+		for j < len(query) && isIdentByte(query[j]) {
+			j++
+		}
+		return j
+	}
+	j := i
+	for j < len(query) && (isDigit(query[j]) || query[j] == '.') {
+		j++
+	}
+	if j < len(query) && (query[j] == 'e' || query[j] == 'E') {
+		k := j + 1
+		if k < len(query) && (query[k] == '-' || query[k] == '+') {
+			k++
+		}
+		if k < len(query) && isDigit(query[k]) {
+			j = k
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+		}
+	}
+	return j
+}
+
+// collapseLists replaces IN (...) lists of literals and the rows after
+// VALUES with a single "(?+)".
+func collapseLists(toks []fpToken) []fpToken {
+	out := make([]fpToken, 0, len(toks))
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		out = append(out, t)
+		if t.kind != fpWord || i+1 >= len(toks) || toks[i+1].text != "(" {
+			continue
+		}
+		switch strings.ToUpper(t.text) {
+		case "IN":
+			end := matchingParen(toks, i+1)
+			if end < 0 || !literalList(toks[i+2:end]) {
+				continue
+			}
+			out = append(out, fpToken{text: "(?+)", kind: fpLiteral, space: toks[i+1].space})
+			i = end
+		case "VALUES", "VALUE":
+			end := matchingParen(toks, i+1)
+			if end < 0 {
+				continue
+			}
+			// Further rows: , ( ... )
+			for end+2 < len(toks) && toks[end+1].text == "," && toks[end+2].text == "(" {
+				next := matchingParen(toks, end+2)
+				if next < 0 {
+					break
+				}
+				end = next
+			}
+			out = append(out, fpToken{text: "(?+)", kind: fpLiteral, space: toks[i+1].space})
+			i = end
+		}
+	}
+	return out
+}
+
+// matchingParen returns the index of the ')' closing the '(' at open, or -1.
+func matchingParen(toks []fpToken, open int) int {
+	depth := 0
+	for i := open; i < len(toks); i++ {
+		if toks[i].kind != fpPunct {
+			continue
+		}
+		switch toks[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// literalList reports whether toks is a non-empty comma separated list of
+// literals.
+func literalList(toks []fpToken) bool {
+	if len(toks) == 0 {
+		return false
+	}
+	for i, t := range toks {
+		if i%2 == 0 && t.kind != fpLiteral || i%2 == 1 && t.text != "," {
+			return false
+		}
+	}
+	return len(toks)%2 == 1
+}
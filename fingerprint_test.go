@@ -0,0 +1,73 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFingerprintQuery_Basic(t *testing.T) {
+	for query, want := range map[string]string{
+		"SELECT * FROM users WHERE id = 1":                              "SELECT * FROM USERS WHERE ID = ?",
+		"select * from users where name = \"O'Brien\" and tag = 'a''b'": "SELECT * FROM USERS WHERE NAME = ? AND TAG = ?",
+		"SELECT * FROM t WHERE a IN (1, 2, 3)":                          "SELECT * FROM T WHERE A IN (?+)",
+		"SELECT * FROM t WHERE a IN (?)":                                "SELECT * FROM T WHERE A IN (?+)",
+		"SELECT * FROM t WHERE a IN (SELECT b FROM u WHERE c = 2)":      "SELECT * FROM T WHERE A IN (SELECT B FROM U WHERE C = ?)",
+		"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y'), (3, NOW())":    "INSERT INTO T (A, B) VALUES (?+)",
+		"SELECT * FROM t WHERE a = -1.5 AND b = 0xFF AND c = X'0A'":     "SELECT * FROM T WHERE A = ? AND B = ? AND C = ?",
+		"SELECT a - 1, b-2 FROM t WHERE c = 1e-3":                       "SELECT A - ?, B-? FROM T WHERE C = ?",
+		"SELECT -1, .5": "SELECT ?, ?",
+		"SELECT t1.a FROM `Orders` t1 -- comment\n WHERE x = 1 # more": "SELECT T1.A FROM `Orders` T1 WHERE X = ?",
+		"SELECT  /* note */ a\n\tFROM t":                               "SELECT A FROM T",
+		"SELECT /*+ MAX_EXECUTION_TIME(10) */ 1":                       "SELECT ?",
+		"SELECT /*!40001 SQL_NO_CACHE */ a FROM t":                     "SELECT /*!40001 SQL_NO_CACHE */ A FROM T",
+		"SELECT * FROM t WHERE s = _utf8mb4'abc' LIMIT 10":             "SELECT * FROM T WHERE S = ? LIMIT ?",
+	} {
+		if got := FingerprintQuery(query, NormalizeBasic); got != want {
+			t.Fatalf("FingerprintQuery(%q)\n got %q\nwant %q", query, got, want)
+		}
+	}
+}
+
+func TestFingerprintQuery_Modes(t *testing.T) {
+	query := "SELECT `Name` FROM Users WHERE id IN (1,2)"
+	if got := FingerprintQuery(query, NormalizeNone); got != query {
+		t.Fatalf("none mode changed query: %q", got)
+	}
+	if got := FingerprintQuery(query, NormalizeFingerprint); got != "select Name from users where id in (?+)" {
+		t.Fatalf("fingerprint mode: %q", got)
+	}
+}
+
+func TestQueryDigest_GroupsVariants(t *testing.T) {
+	a := QueryDigest(FingerprintQuery("SELECT * FROM t WHERE a IN (1, 2) AND b = 'x'", NormalizeBasic))
+	b := QueryDigest(FingerprintQuery("select *\nfrom t where a in (3,4,5) and b = \"y\" /* c */", NormalizeBasic))
+	c := QueryDigest(FingerprintQuery("SELECT * FROM t WHERE a = 1", NormalizeBasic))
+	if a != b || a == c || len(a) != 16 {
+		t.Fatalf("digests: %s %s %s", a, b, c)
+	}
+}
+
+func TestSlowQueryRecorder_PatternsKeyedByDigest(t *testing.T) {
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = 0
+	storage := NewMemorySlowQueryStorage(10)
+	recorder := NewSlowQueryRecorder(config, storage)
+	defer recorder.Close()
+	ctx := context.Background()
+
+	recorder.Record(ctx, "SELECT * FROM t WHERE id IN (1, 2)", nil, 1, nil)
+	recorder.Record(ctx, "SELECT * FROM t WHERE id IN (3, 4, 5)", nil, 1, nil)
+	patterns, _ := recorder.GetPatterns(ctx, 10)
+	if len(patterns) != 1 || patterns[0].Count != 2 {
+		t.Fatalf("expected one pattern with 2 queries, got %+v", patterns)
+	}
+	id := patterns[0].ID
+	if id != QueryDigest("SELECT * FROM T WHERE ID IN (?+)") {
+		t.Fatalf("pattern ID %q", id)
+	}
+	records, _ := recorder.GetRecords(ctx, SlowQueryFilter{QueryPattern: id})
+	if len(records) != 2 || records[0].PatternID != id {
+		t.Fatalf("filter by pattern ID: %+v", records)
+	}
+}
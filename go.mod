@@ -8,7 +8,11 @@ require (
 	github.com/yggai/ygggo_env v1.0.0
 )
 
-require github.com/yggai/ygggo_log v1.0.0
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/yggai/ygggo_log v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
@@ -17,5 +21,4 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rogpeppe/go-internal v1.13.1 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
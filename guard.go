@@ -0,0 +1,465 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// GuardRule identifies a check performed by the query guard.
+type GuardRule string
+
+const (
+	// GuardMissingWhere rejects UPDATE and DELETE without a WHERE clause.
+	GuardMissingWhere GuardRule = "missing_where"
+	// GuardTautology rejects UPDATE and DELETE whose WHERE clause is always
+	// true, such as "1=1" or "id = id OR 1".
+	GuardTautology GuardRule = "tautology"
+	// GuardMissingLimit rejects SELECT without LIMIT on GuardConfig.LimitTables.
+	GuardMissingLimit GuardRule = "missing_limit"
+	// GuardDDL rejects CREATE, ALTER, DROP, TRUNCATE and RENAME unless the
+	// pool is in maintenance mode (see Pool.SetMaintenance).
+	GuardDDL GuardRule = "ddl"
+	// GuardMultiStatement rejects several statements sent as one.
+	GuardMultiStatement GuardRule = "multi_statement"
+)
+
+// GuardConfig selects the rules enforced by the query guard.
+type GuardConfig struct {
+	RequireWhere         bool     `json:"require_where"`          // GuardMissingWhere
+	RejectTautologies    bool     `json:"reject_tautologies"`     // GuardTautology
+	LimitTables          []string `json:"limit_tables"`           // GuardMissingLimit, by table name
+	BlockDDL             bool     `json:"block_ddl"`              // GuardDDL
+	BlockMultiStatements bool     `json:"block_multi_statements"` // GuardMultiStatement
+}
+
+// DefaultGuardConfig returns a guard with every rule enabled and no
+// large tables configured.
+func DefaultGuardConfig() GuardConfig {
+	return GuardConfig{
+		RequireWhere:         true,
+		RejectTautologies:    true,
+		BlockDDL:             true,
+		BlockMultiStatements: true,
+	}
+}
+
+// GuardViolation is returned for statements rejected by the query guard.
+type GuardViolation struct {
+	Rule   GuardRule // rule that rejected the statement
+	Reason string    // human readable explanation
+	Table  string    // table concerned, if known
+	Query  string    // rejected statement
+}
+
+// Error implements error.
+func (v *GuardViolation) Error() string {
+	return fmt.Sprintf("query rejected by guard (%s): %s", v.Rule, v.Reason)
+}
+
+// Guard checks statements against a GuardConfig. It is safe for concurrent use.
+type Guard struct {
+	cfg    GuardConfig
+	tables map[string]bool
+}
+
+// NewGuard creates a Guard for cfg.
+func NewGuard(cfg GuardConfig) *Guard {
+	g := &Guard{cfg: cfg, tables: make(map[string]bool, len(cfg.LimitTables))}
+	for _, t := range cfg.LimitTables {
+		g.tables[strings.ToLower(t)] = true
+	}
+	return g
+}
+
+// Config returns the configuration g was created with.
+func (g *Guard) Config() GuardConfig {
+	return g.cfg
+}
+
+// guardOverrideKey is the context key for guard overrides.
+type guardOverrideKey struct{}
+
+// WithGuardOverride returns a context in which the given guard rules are
+// not enforced, for intentional operations such as purging a table.
+// Without rules, all checks are skipped.
+//
+// Example:
+//
+//	ctx := ygggo_mysql.WithGuardOverride(ctx, ygggo_mysql.GuardMissingWhere)
+//	_, err := conn.Exec(ctx, "DELETE FROM sessions")
+func WithGuardOverride(ctx context.Context, rules ...GuardRule) context.Context {
+	if len(rules) == 0 {
+		return context.WithValue(ctx, guardOverrideKey{}, map[GuardRule]bool(nil))
+	}
+	allowed := make(map[GuardRule]bool, len(rules))
+	if prev, ok := ctx.Value(guardOverrideKey{}).(map[GuardRule]bool); ok {
+		if prev == nil {
+			return ctx // already overrides everything
+		}
+		for r := range prev {
+			allowed[r] = true
+		}
+	}
+	for _, r := range rules {
+		allowed[r] = true
+	}
+	return context.WithValue(ctx, guardOverrideKey{}, allowed)
+}
+
+// guardOverridden reports whether rule is overridden in ctx.
+func guardOverridden(ctx context.Context, rule GuardRule) bool {
+	if ctx == nil {
+		return false
+	}
+	allowed, ok := ctx.Value(guardOverrideKey{}).(map[GuardRule]bool)
+	if !ok {
+		return false
+	}
+	return allowed == nil || allowed[rule]
+}
+
+// SetGuard enables the query guard with cfg, or disables it when cfg is nil.
+//
+// When enabled, Conn.Exec, Conn.Query, Tx.Exec and everything built on
+// them (QueryBuilder, TableDataManager) check each statement first and
+// return a *GuardViolation instead of running it. Conn.QueryRow returns a
+// row whose Scan reports the violation.
+//
+// Thread Safety: This method is safe for concurrent use.
+func (p *Pool) SetGuard(cfg *GuardConfig) {
+	if cfg == nil {
+		p.guard.Store(nil)
+		return
+	}
+	p.guard.Store(NewGuard(*cfg))
+}
+
+// Guard returns the pool's query guard, or nil when it is disabled.
+func (p *Pool) Guard() *Guard {
+	if p == nil {
+		return nil
+	}
+	return p.guard.Load()
+}
+
+// SetMaintenance turns maintenance mode on or off. DDL statements are
+// only allowed by the guard in maintenance mode.
+func (p *Pool) SetMaintenance(on bool) {
+	p.maintenance.Store(on)
+}
+
+// InMaintenance reports whether maintenance mode is on.
+func (p *Pool) InMaintenance() bool {
+	return p.maintenance.Load()
+}
+
+// checkQuery runs the pool's guard, if any, on query.
+func (p *Pool) checkQuery(ctx context.Context, query string) error {
+	g := p.Guard()
+	if g == nil {
+		return nil
+	}
+	v := g.check(query, p.InMaintenance())
+	if v == nil || guardOverridden(ctx, v.Rule) {
+		return nil
+	}
+	return v
+}
+
+// Check returns a *GuardViolation if query breaks one of g's rules, or
+// nil. DDL is checked as if maintenance mode were off.
+func (g *Guard) Check(query string) error {
+	if v := g.check(query, false); v != nil {
+		return v
+	}
+	return nil
+}
+
+// check returns the first violation in query, or nil.
+func (g *Guard) check(query string, maintenance bool) *GuardViolation {
+	toks := sqlTokens(query)
+	if len(toks) == 0 {
+		return nil
+	}
+	violation := func(rule GuardRule, table, format string, args ...any) *GuardViolation {
+		return &GuardViolation{Rule: rule, Table: table, Query: query, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	// Trailing semicolons are harmless; anything after one is another statement
+	end := len(toks)
+	for end > 0 && toks[end-1] == ";" {
+		end--
+	}
+	toks = toks[:end]
+	if g.cfg.BlockMultiStatements {
+		for _, t := range toks {
+			if t == ";" {
+				return violation(GuardMultiStatement, "", "multiple statements in one call")
+			}
+		}
+	}
+	if len(toks) == 0 {
+		return nil
+	}
+
+	switch keyword := strings.ToUpper(toks[0]); keyword {
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME":
+		if g.cfg.BlockDDL && !maintenance {
+			return violation(GuardDDL, "", "%s is only allowed in maintenance mode", keyword)
+		}
+	case "UPDATE", "DELETE":
+		table := dmlTable(keyword, toks)
+		where := topLevelIndex(toks, "WHERE")
+		var cond []string
+		if where >= 0 {
+			cond = toks[where+1 : clauseEnd(toks, where+1)]
+		}
+		if g.cfg.RequireWhere && len(cond) == 0 {
+			return violation(GuardMissingWhere, table, "%s without WHERE affects every row", keyword)
+		}
+		if g.cfg.RejectTautologies && len(cond) > 0 && isTautology(cond) {
+			return violation(GuardTautology, table, "%s with an always-true WHERE affects every row", keyword)
+		}
+	case "SELECT":
+		if len(g.tables) == 0 || topLevelIndex(toks, "LIMIT") >= 0 || isSingleRowAggregate(toks) {
+			return nil
+		}
+		for _, table := range selectTables(toks) {
+			if g.tables[strings.ToLower(table)] {
+				return violation(GuardMissingLimit, table, "SELECT from large table %s requires LIMIT", table)
+			}
+		}
+	}
+	return nil
+}
+
+// topLevelIndex returns the index of keyword outside parentheses, or -1.
+func topLevelIndex(toks []string, keyword string) int {
+	depth := 0
+	for i, t := range toks {
+		switch t {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			if depth == 0 && strings.EqualFold(t, keyword) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// clauseEnd returns the end of the clause starting at start: the next
+// top-level ORDER, LIMIT, GROUP, HAVING or the end of toks.
+func clauseEnd(toks []string, start int) int {
+	depth := 0
+	for i := start; i < len(toks); i++ {
+		switch t := strings.ToUpper(toks[i]); t {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case "ORDER", "LIMIT", "GROUP", "HAVING":
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(toks)
+}
+
+// splitTopLevel splits toks at top-level occurrences of keyword.
+func splitTopLevel(toks []string, keyword string) [][]string {
+	var parts [][]string
+	depth, last := 0, 0
+	for i, t := range toks {
+		switch t {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			if depth == 0 && (strings.EqualFold(t, keyword) || (keyword == "OR" && t == "||") || (keyword == "AND" && t == "&&")) {
+				parts = append(parts, toks[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, toks[last:])
+}
+
+// stripParens removes parentheses enclosing the whole of toks.
+func stripParens(toks []string) []string {
+	for len(toks) >= 2 && toks[0] == "(" && toks[len(toks)-1] == ")" {
+		depth := 0
+		enclosing := true
+		for i, t := range toks[:len(toks)-1] {
+			if t == "(" {
+				depth++
+			} else if t == ")" {
+				depth--
+			}
+			if depth == 0 && i < len(toks)-1 {
+				enclosing = false
+				break
+			}
+		}
+		if !enclosing {
+			break
+		}
+		toks = toks[1 : len(toks)-1]
+	}
+	return toks
+}
+
+// isTautology reports whether the condition toks is always true: one of
+// its OR branches is a conjunction of always-true terms.
+func isTautology(toks []string) bool {
+	toks = stripParens(toks)
+	for _, branch := range splitTopLevel(toks, "OR") {
+		terms := splitTopLevel(stripParens(branch), "AND")
+		all := len(terms) > 0
+		for _, term := range terms {
+			if !isTrueTerm(stripParens(term)) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrueTerm reports whether a single comparison is always true.
+func isTrueTerm(toks []string) bool {
+	switch len(toks) {
+	case 1:
+		return isTruthyLiteral(toks[0])
+	case 2:
+		return strings.EqualFold(toks[0], "NOT") && isFalsyLiteral(toks[1])
+	case 3:
+		switch toks[1] {
+		case "=", "<=>", ">=", "<=":
+			return toks[0] != "?" && strings.EqualFold(toks[0], toks[2])
+		case "<>", "!=":
+			return isLiteral(toks[0]) && isLiteral(toks[2]) && !strings.EqualFold(toks[0], toks[2])
+		}
+	}
+	return false
+}
+
+// isLiteral reports whether tok is a number or a quoted string.
+func isLiteral(tok string) bool {
+	return tok != "" && (isDigit(tok[0]) || tok[0] == '\'' || tok[0] == '"')
+}
+
+// isTruthyLiteral reports whether tok is TRUE or a non-zero number.
+func isTruthyLiteral(tok string) bool {
+	if strings.EqualFold(tok, "TRUE") {
+		return true
+	}
+	return isDigit(tok[0]) && strings.Trim(tok, "0.") != ""
+}
+
+// isFalsyLiteral reports whether tok is FALSE, NULL or zero.
+func isFalsyLiteral(tok string) bool {
+	if strings.EqualFold(tok, "FALSE") || strings.EqualFold(tok, "NULL") {
+		return true
+	}
+	return isDigit(tok[0]) && strings.Trim(tok, "0.") == ""
+}
+
+// dmlTable returns the target table of an UPDATE or DELETE statement.
+func dmlTable(keyword string, toks []string) string {
+	for i := 1; i < len(toks); i++ {
+		switch strings.ToUpper(toks[i]) {
+		case "LOW_PRIORITY", "QUICK", "IGNORE":
+			continue
+		case "FROM":
+			if keyword == "DELETE" {
+				continue
+			}
+		}
+		return qualifiedName(toks, i)
+	}
+	return ""
+}
+
+// selectTables returns the tables named after top-level FROM and JOIN.
+func selectTables(toks []string) []string {
+	var tables []string
+	depth := 0
+	for i := 0; i < len(toks)-1; i++ {
+		switch t := strings.ToUpper(toks[i]); t {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case "FROM", "JOIN", ",":
+			if depth > 0 || t == "," && !inFromClause(toks[:i]) {
+				continue
+			}
+			next := toks[i+1]
+			if next != "(" && (isIdentByte(next[0]) || next[0] == '`') {
+				tables = append(tables, qualifiedName(toks, i+1))
+			}
+		}
+	}
+	return tables
+}
+
+// inFromClause reports whether the last top-level clause keyword in toks is FROM.
+func inFromClause(toks []string) bool {
+	for i := len(toks) - 1; i >= 0; i-- {
+		switch strings.ToUpper(toks[i]) {
+		case "FROM":
+			return true
+		case "SELECT", "WHERE", "ON", "SET", "GROUP", "ORDER", "HAVING", "(":
+			return false
+		}
+	}
+	return false
+}
+
+// qualifiedName returns the table name starting at toks[i], skipping a
+// schema given as separate quoted tokens (`schema`.`table`).
+func qualifiedName(toks []string, i int) string {
+	for i+2 < len(toks) && toks[i+1] == "." {
+		i += 2
+	}
+	return tableName(toks[i])
+}
+
+// tableName strips backquotes and the schema prefix from tok.
+func tableName(tok string) string {
+	tok = strings.ReplaceAll(tok, "`", "")
+	if dot := strings.LastIndex(tok, "."); dot >= 0 {
+		tok = tok[dot+1:]
+	}
+	return tok
+}
+
+// isSingleRowAggregate reports whether a SELECT returns a single row
+// because its select list only holds aggregates and there is no GROUP BY.
+func isSingleRowAggregate(toks []string) bool {
+	from := topLevelIndex(toks, "FROM")
+	if from < 2 || topLevelIndex(toks, "GROUP") >= 0 {
+		return false
+	}
+	for _, item := range splitTopLevel(toks[1:from], ",") {
+		if len(item) < 3 || item[1] != "(" {
+			return false
+		}
+		switch strings.ToUpper(item[0]) {
+		case "COUNT", "SUM", "MIN", "MAX", "AVG":
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGuard_Check(t *testing.T) {
+	g := NewGuard(GuardConfig{
+		RequireWhere:         true,
+		RejectTautologies:    true,
+		LimitTables:          []string{"events"},
+		BlockDDL:             true,
+		BlockMultiStatements: true,
+	})
+	cases := map[string]GuardRule{
+		"DELETE FROM users":                                   GuardMissingWhere,
+		"DELETE FROM users WHERE ":                            GuardMissingWhere,
+		"UPDATE users SET a = 1 /* WHERE id = 1 */":           GuardMissingWhere,
+		"UPDATE users SET a = (SELECT 1 FROM t WHERE x=1)":    GuardMissingWhere,
+		"DELETE FROM users WHERE 1=1":                         GuardTautology,
+		"DELETE FROM users WHERE (1)":                         GuardTautology,
+		"UPDATE users SET a = 1 WHERE id = ? OR 'a' = 'a'":    GuardTautology,
+		"DELETE FROM users WHERE id = id AND TRUE":            GuardTautology,
+		"DELETE FROM users WHERE NOT 0":                       GuardTautology,
+		"SELECT * FROM events WHERE kind = ?":                 GuardMissingLimit,
+		"SELECT e.* FROM users u JOIN `app`.`events` e":       GuardMissingLimit,
+		"SELECT * FROM users, events":                         GuardMissingLimit,
+		"DROP TABLE users":                                    GuardDDL,
+		"truncate users":                                      GuardDDL,
+		"SELECT 1; DELETE FROM users":                         GuardMultiStatement,
+		"DELETE FROM users WHERE id = ?":                      "",
+		"DELETE FROM users WHERE id = ? OR email = ?":         "",
+		"UPDATE users SET a = 1 WHERE 1 = ?":                  "",
+		"SELECT * FROM events WHERE kind = ? LIMIT 10":        "",
+		"SELECT COUNT(*) FROM events":                         "",
+		"SELECT * FROM users":                                 "",
+		"SELECT * FROM users WHERE note = 'x; DROP TABLE'":    "",
+		"INSERT INTO users (a) VALUES (1);":                   "",
+		"SELECT * FROM t WHERE id IN (SELECT id FROM events)": "",
+	}
+	for q, want := range cases {
+		err := g.Check(q)
+		var v *GuardViolation
+		if want == "" {
+			if err != nil {
+				t.Fatalf("%q: unexpected violation %v", q, err)
+			}
+			continue
+		}
+		if !errors.As(err, &v) || v.Rule != want {
+			t.Fatalf("%q: got %v, want rule %s", q, err, want)
+		}
+	}
+}
+
+func TestPoolGuard_OverrideAndMaintenance(t *testing.T) {
+	p := newSessionTestServer().pool(t)
+	cfg := DefaultGuardConfig()
+	p.SetGuard(&cfg)
+	ctx := context.Background()
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Exec(ctx, "DELETE FROM sessions")
+	var v *GuardViolation
+	if !errors.As(err, &v) || v.Rule != GuardMissingWhere || v.Table != "sessions" {
+		t.Fatalf("expected missing_where violation, got %v", err)
+	}
+	if _, err := conn.Exec(WithGuardOverride(ctx, GuardMissingWhere), "DELETE FROM sessions"); err != nil {
+		t.Fatalf("override should allow the statement: %v", err)
+	}
+	if _, err := conn.Exec(WithGuardOverride(ctx, GuardTautology), "DELETE FROM sessions"); err == nil {
+		t.Fatalf("override of another rule must not allow the statement")
+	}
+
+	if _, err := conn.Exec(ctx, "ALTER TABLE t ADD COLUMN c INT"); !errors.As(err, &v) || v.Rule != GuardDDL {
+		t.Fatalf("expected ddl violation, got %v", err)
+	}
+	p.SetMaintenance(true)
+	if _, err := conn.Exec(ctx, "ALTER TABLE t ADD COLUMN c INT"); err != nil {
+		t.Fatalf("DDL should be allowed in maintenance mode: %v", err)
+	}
+
+	p.SetGuard(nil)
+	if _, err := conn.Exec(ctx, "DELETE FROM sessions"); err != nil {
+		t.Fatalf("disabled guard: %v", err)
+	}
+}
+
+func TestConnQueryRow_Guarded(t *testing.T) {
+	p := newSessionTestServer().pool(t)
+	cfg := DefaultGuardConfig()
+	cfg.LimitTables = []string{"events"}
+	p.SetGuard(&cfg)
+	ctx := context.Background()
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var n int
+	err = conn.QueryRow(ctx, "SELECT id FROM events WHERE kind = ?", "login").Scan(&n)
+	var v *GuardViolation
+	if !errors.As(err, &v) || v.Rule != GuardMissingLimit {
+		t.Fatalf("expected missing_limit violation, got %v", err)
+	}
+
+	// Also reported when the context is already canceled
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := conn.QueryRow(cctx, "SELECT id FROM events").Scan(&n); !errors.As(err, &v) {
+		t.Fatalf("expected guard violation, got %v", err)
+	}
+}
+
+func TestQueryBuilderExec_Guarded(t *testing.T) {
+	p := newSessionTestServer().pool(t)
+	cfg := DefaultGuardConfig()
+	p.SetGuard(&cfg)
+	ctx := context.Background()
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = NewQueryBuilder(conn).Delete("users").Exec(ctx)
+	var v *GuardViolation
+	if !errors.As(err, &v) {
+		t.Fatalf("expected guard violation, got %v", err)
+	}
+}
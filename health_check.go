@@ -10,6 +10,7 @@ import (
 // HealthStatus represents the overall health of a connection/pool
 type HealthStatus struct {
 	Healthy           bool                   `json:"healthy"`
+	State             HealthState            `json:"state,omitempty"` // Set by HealthEndpoints
 	LastChecked       time.Time              `json:"last_checked"`
 	ResponseTime      time.Duration          `json:"response_time"`
 	ConnectionsActive int                    `json:"connections_active"`
@@ -36,6 +37,20 @@ type HealthCheckConfig struct {
 	TestQuery          string        `json:"test_query"`
 	MonitoringEnabled  bool          `json:"monitoring_enabled"`
 	MonitoringInterval time.Duration `json:"monitoring_interval"`
+
+	// Server checks run by DeepHealthCheck. A zero threshold disables the
+	// corresponding check; all are disabled by default. Breaches are
+	// recoverable and leave the status healthy (degraded in
+	// HealthEndpoints), except for a read-only server with ExpectWritable.
+	ExpectWritable           bool          `json:"expect_writable"`             // report read_only/super_read_only as unhealthy
+	MaxReplicationLag        time.Duration `json:"max_replication_lag"`         // replica lag behind its source
+	MaxThreadsConnectedRatio float64       `json:"max_threads_connected_ratio"` // Threads_connected / max_connections
+	MaxAbortedConnectsRate   float64       `json:"max_aborted_connects_rate"`   // Aborted_connects per second
+	MinBufferPoolHitRatio    float64       `json:"min_buffer_pool_hit_ratio"`   // InnoDB buffer pool reads served from memory
+
+	// ServerChecks replaces the checks run by DeepHealthCheck; nil means
+	// DefaultServerHealthChecks.
+	ServerChecks []ServerHealthCheck `json:"-"`
 }
 
 // DefaultHealthCheckConfig returns default health check configuration
@@ -80,11 +95,20 @@ func (p *Pool) HealthCheck(ctx context.Context) (*HealthStatus, error) {
 	if p == nil {
 		return nil, fmt.Errorf("pool is nil")
 	}
-	return p.HealthCheckWithConfig(ctx, DefaultHealthCheckConfig())
+	return p.HealthCheckWithConfig(ctx, p.healthConfig())
+}
+
+// healthConfig returns Config.Health if set, else the default configuration
+func (p *Pool) healthConfig() HealthCheckConfig {
+	if p.cfg.Health != nil {
+		return *p.cfg.Health
+	}
+	return DefaultHealthCheckConfig()
 }
 
 // HealthCheckWithConfig performs a health check with custom configuration
 func (p *Pool) HealthCheckWithConfig(ctx context.Context, config HealthCheckConfig) (*HealthStatus, error) {
+	ctx = internalContext(ctx)
 	start := time.Now()
 	status := &HealthStatus{
 		LastChecked: start,
@@ -134,14 +158,20 @@ func (p *Pool) HealthCheckWithConfig(ctx context.Context, config HealthCheckConf
 
 // DeepHealthCheck performs an extensive health check with detailed analysis
 func (p *Pool) DeepHealthCheck(ctx context.Context) (*HealthStatus, error) {
-	config := DefaultHealthCheckConfig()
-	config.Timeout = 10 * time.Second // Longer timeout for deep check
+	ctx = internalContext(ctx)
+	config := p.healthConfig()
+	if config.Timeout < 10*time.Second {
+		config.Timeout = 10 * time.Second // Longer timeout for deep check
+	}
 	
 	status, err := p.HealthCheckWithConfig(ctx, config)
 	if err != nil {
 		return status, err
 	}
 
+	// Server-level checks
+	p.performServerChecks(ctx, config, status)
+
 	// Additional deep checks
 	if err := p.performDeepChecks(ctx, status); err != nil {
 		status.Errors = append(status.Errors, HealthError{
@@ -158,12 +188,13 @@ func (p *Pool) DeepHealthCheck(ctx context.Context) (*HealthStatus, error) {
 
 // performPingCheck executes a basic ping check
 func (p *Pool) performPingCheck(ctx context.Context, status *HealthStatus) error {
-	if p == nil || p.db == nil {
+	db := p.getDB()
+	if db == nil {
 		return fmt.Errorf("pool or database is nil")
 	}
 	
 	start := time.Now()
-	err := p.db.PingContext(ctx)
+	err := db.PingContext(ctx)
 	pingTime := time.Since(start)
 	
 	status.Details["ping_time"] = pingTime
@@ -172,7 +203,8 @@ func (p *Pool) performPingCheck(ctx context.Context, status *HealthStatus) error
 
 // performQueryCheck executes a test query to verify database responsiveness
 func (p *Pool) performQueryCheck(ctx context.Context, config HealthCheckConfig, status *HealthStatus) error {
-	if p == nil || p.db == nil {
+	db := p.getDB()
+	if db == nil {
 		return fmt.Errorf("pool or database is nil")
 	}
 
@@ -180,7 +212,7 @@ func (p *Pool) performQueryCheck(ctx context.Context, config HealthCheckConfig,
 	defer cancel()
 
 	start := time.Now()
-	rows, err := p.db.QueryContext(queryCtx, config.TestQuery)
+	rows, err := db.QueryContext(queryCtx, config.TestQuery)
 	if err != nil {
 		return fmt.Errorf("test query failed: %w", err)
 	}
@@ -205,11 +237,12 @@ func (p *Pool) performQueryCheck(ctx context.Context, config HealthCheckConfig,
 
 // collectPoolStats gathers connection pool statistics
 func (p *Pool) collectPoolStats(status *HealthStatus) {
-	if p == nil || p.db == nil {
+	db := p.getDB()
+	if db == nil {
 		return
 	}
 
-	stats := p.db.Stats()
+	stats := db.Stats()
 	status.ConnectionsActive = stats.InUse
 	status.ConnectionsIdle = stats.Idle
 	status.ConnectionsMax = stats.MaxOpenConnections
@@ -229,7 +262,7 @@ func (p *Pool) collectPoolStats(status *HealthStatus) {
 // performDeepChecks executes additional comprehensive checks
 func (p *Pool) performDeepChecks(ctx context.Context, status *HealthStatus) error {
 	// Check for connection leaks
-	stats := p.db.Stats()
+	stats := p.getDB().Stats()
 	if stats.InUse > stats.MaxOpenConnections*8/10 { // 80% threshold
 		status.Details["connection_leak_warning"] = true
 	}
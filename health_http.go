@@ -0,0 +1,252 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthState summarizes a HealthStatus for health endpoints.
+type HealthState string
+
+const (
+	HealthStateHealthy   HealthState = "healthy"
+	HealthStateDegraded  HealthState = "degraded"  // serving, but a non-critical check failed or the pool is slow or saturated
+	HealthStateUnhealthy HealthState = "unhealthy" // the database or a critical check failed
+)
+
+// HealthChecker provides the health information served by HealthHandler.
+// *Pool implements it.
+type HealthChecker interface {
+	// HealthCheck runs a health check
+	HealthCheck(ctx context.Context) (*HealthStatus, error)
+
+	// GetHealthStatus returns the status cached by the health monitor, or nil
+	GetHealthStatus() *HealthStatus
+}
+
+// HealthCheck is an additional named check run by HealthHandler, for
+// example a dependency of the service other than the database.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+
+	// Critical checks make the service unhealthy when they fail; other
+	// checks only degrade it.
+	Critical bool
+}
+
+// HealthHandlerOptions configures HealthHandler.
+type HealthHandlerOptions struct {
+	// Timeout bounds a health check run for a request. Defaults to 2s.
+	Timeout time.Duration
+
+	// MaxStatusAge is the maximum age of the HealthMonitor status used
+	// instead of running a check. Defaults to 1m.
+	MaxStatusAge time.Duration
+
+	// DegradedLatency marks the pool degraded when a check takes longer.
+	// 0 disables the check.
+	DegradedLatency time.Duration
+
+	// DegradedStatusCode is returned for degraded readiness and health.
+	// Defaults to 200, so degraded instances keep receiving traffic.
+	DegradedStatusCode int
+
+	// Checks are run with every readiness and health request.
+	Checks []HealthCheck
+}
+
+// HealthEndpoints serves Kubernetes style health endpoints for a pool:
+//
+//   - /livez reports that the process is serving. It never touches the
+//     database, so a database outage does not get the pod restarted.
+//   - /readyz reports whether the instance should receive traffic: 200
+//     when healthy or degraded, 503 when unhealthy.
+//   - /healthz reports the same state; with ?verbose the full HealthStatus,
+//     including errors and the result of every check, is returned.
+//
+// Paths are matched by suffix, so the handler can be mounted under a
+// prefix:
+//
+//	health := ygggo_mysql.HealthHandler(pool, ygggo_mysql.HealthHandlerOptions{})
+//	health.AddCheck(ygggo_mysql.HealthCheck{Name: "cache", Check: pingCache})
+//	mux.Handle("/", health)
+type HealthEndpoints struct {
+	checker HealthChecker
+	opts    HealthHandlerOptions
+
+	mu     sync.RWMutex
+	checks []HealthCheck
+}
+
+// HealthHandler returns the handler serving /livez, /readyz and /healthz
+// for checker, usually a *Pool.
+func HealthHandler(checker HealthChecker, opts HealthHandlerOptions) *HealthEndpoints {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	if opts.MaxStatusAge <= 0 {
+		opts.MaxStatusAge = time.Minute
+	}
+	if opts.DegradedStatusCode == 0 {
+		opts.DegradedStatusCode = http.StatusOK
+	}
+	h := &HealthEndpoints{checker: checker, opts: opts}
+	h.checks = append(h.checks, opts.Checks...)
+	return h
+}
+
+// AddCheck registers an additional check.
+func (h *HealthEndpoints) AddCheck(check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, check)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HealthEndpoints) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case strings.HasSuffix(path, "/livez"):
+		h.serveLive(w, r)
+	case strings.HasSuffix(path, "/readyz"):
+		h.serveHealth(w, r, false)
+	case strings.HasSuffix(path, "/healthz"):
+		_, verbose := r.URL.Query()["verbose"]
+		h.serveHealth(w, r, verbose)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveLive reports liveness without checking the database.
+func (h *HealthEndpoints) serveLive(w http.ResponseWriter, r *http.Request) {
+	status := &HealthStatus{Healthy: true, State: HealthStateHealthy, LastChecked: time.Now()}
+	writeHealthStatus(w, r, http.StatusOK, status)
+}
+
+// serveHealth evaluates the pool and the registered checks.
+func (h *HealthEndpoints) serveHealth(w http.ResponseWriter, r *http.Request, verbose bool) {
+	status := h.Evaluate(r.Context())
+	code := http.StatusOK
+	switch status.State {
+	case HealthStateDegraded:
+		code = h.opts.DegradedStatusCode
+	case HealthStateUnhealthy:
+		code = http.StatusServiceUnavailable
+	}
+	if !verbose {
+		status = &HealthStatus{
+			Healthy:      status.Healthy,
+			State:        status.State,
+			LastChecked:  status.LastChecked,
+			ResponseTime: status.ResponseTime,
+		}
+	}
+	writeHealthStatus(w, r, code, status)
+}
+
+// Evaluate returns the current status with its State set. The status
+// cached by the health monitor is used when it is recent enough;
+// otherwise a check bounded by Timeout is run. Registered checks always
+// run.
+func (h *HealthEndpoints) Evaluate(ctx context.Context) *HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+
+	status := h.poolStatus(ctx)
+	if status.Details == nil {
+		status.Details = make(map[string]interface{})
+	}
+	state := HealthStateHealthy
+	if !status.Healthy {
+		state = HealthStateUnhealthy
+	} else if len(status.Errors) > 0 {
+		// Recoverable errors, e.g. server check breaches
+		state = HealthStateDegraded
+		status.Details["degraded_reason"] = status.Errors[0].Message
+	} else if h.opts.DegradedLatency > 0 && status.ResponseTime > h.opts.DegradedLatency {
+		state = HealthStateDegraded
+		status.Details["degraded_reason"] = fmt.Sprintf("response time %v exceeds %v", status.ResponseTime, h.opts.DegradedLatency)
+	} else if status.ConnectionsMax > 0 && status.ConnectionsActive >= status.ConnectionsMax {
+		state = HealthStateDegraded
+		status.Details["degraded_reason"] = "connection pool saturated"
+	}
+
+	h.mu.RLock()
+	checks := append([]HealthCheck(nil), h.checks...)
+	h.mu.RUnlock()
+	if len(checks) > 0 {
+		results := make(map[string]string, len(checks))
+		for _, c := range checks {
+			err := c.Check(ctx)
+			if err == nil {
+				results[c.Name] = "ok"
+				continue
+			}
+			results[c.Name] = err.Error()
+			status.Errors = append(status.Errors, HealthError{
+				Type:        "check:" + c.Name,
+				Message:     err.Error(),
+				Timestamp:   time.Now(),
+				Recoverable: !c.Critical,
+			})
+			if c.Critical {
+				state = HealthStateUnhealthy
+			} else if state == HealthStateHealthy {
+				state = HealthStateDegraded
+			}
+		}
+		status.Details["checks"] = results
+	}
+
+	status.State = state
+	status.Healthy = state != HealthStateUnhealthy
+	return status
+}
+
+// poolStatus returns the fresh cached status or runs a check.
+func (h *HealthEndpoints) poolStatus(ctx context.Context) *HealthStatus {
+	if cached := h.checker.GetHealthStatus(); cached != nil && !cached.LastChecked.IsZero() &&
+		time.Since(cached.LastChecked) <= h.opts.MaxStatusAge {
+		return cached
+	}
+	status, err := h.checker.HealthCheck(ctx)
+	if err != nil || status == nil {
+		msg := "health check returned no status"
+		if err != nil {
+			msg = err.Error()
+		}
+		return &HealthStatus{
+			LastChecked: time.Now(),
+			Errors: []HealthError{{
+				Type:        "health_check_failure",
+				Message:     msg,
+				Timestamp:   time.Now(),
+				Recoverable: true,
+			}},
+		}
+	}
+	return status
+}
+
+// writeHealthStatus writes status as JSON.
+func writeHealthStatus(w http.ResponseWriter, r *http.Request, code int, status *HealthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
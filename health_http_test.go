@@ -0,0 +1,143 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeHealthChecker is a HealthChecker with a fixed status.
+type fakeHealthChecker struct {
+	status *HealthStatus
+	cached *HealthStatus
+	err    error
+	checks atomic.Int32
+}
+
+func (f *fakeHealthChecker) HealthCheck(ctx context.Context) (*HealthStatus, error) {
+	f.checks.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	s := *f.status
+	return &s, nil
+}
+
+func (f *fakeHealthChecker) GetHealthStatus() *HealthStatus {
+	if f.cached == nil {
+		return nil
+	}
+	s := *f.cached
+	return &s
+}
+
+func getHealth(t *testing.T, h http.Handler, path string) (int, HealthStatus) {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("content type %q", ct)
+	}
+	var status HealthStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, status
+}
+
+func TestHealthHandler_States(t *testing.T) {
+	healthy := &fakeHealthChecker{status: &HealthStatus{Healthy: true, LastChecked: time.Now(), ConnectionsMax: 10}}
+	code, status := getHealth(t, HealthHandler(healthy, HealthHandlerOptions{}), "/readyz")
+	if code != http.StatusOK || status.State != HealthStateHealthy {
+		t.Fatalf("healthy: %d %+v", code, status)
+	}
+
+	saturated := &fakeHealthChecker{status: &HealthStatus{Healthy: true, ConnectionsActive: 10, ConnectionsMax: 10}}
+	h := HealthHandler(saturated, HealthHandlerOptions{DegradedStatusCode: http.StatusTooManyRequests})
+	code, status = getHealth(t, h, "/readyz")
+	if code != http.StatusTooManyRequests || status.State != HealthStateDegraded {
+		t.Fatalf("degraded: %d %+v", code, status)
+	}
+
+	down := &fakeHealthChecker{err: errors.New("connection refused")}
+	code, status = getHealth(t, HealthHandler(down, HealthHandlerOptions{}), "/healthz?verbose")
+	if code != http.StatusServiceUnavailable || status.State != HealthStateUnhealthy || status.Healthy || len(status.Errors) != 1 {
+		t.Fatalf("unhealthy: %d %+v", code, status)
+	}
+
+	// Liveness does not depend on the database
+	code, status = getHealth(t, HealthHandler(down, HealthHandlerOptions{}), "/livez")
+	if code != http.StatusOK || !status.Healthy || down.checks.Load() != 1 {
+		t.Fatalf("livez: %d %+v (checks %d)", code, status, down.checks.Load())
+	}
+}
+
+func TestHealthHandler_UsesFreshCachedStatus(t *testing.T) {
+	f := &fakeHealthChecker{
+		status: &HealthStatus{Healthy: true},
+		cached: &HealthStatus{Healthy: true, LastChecked: time.Now()},
+	}
+	h := HealthHandler(f, HealthHandlerOptions{})
+	if code, _ := getHealth(t, h, "/readyz"); code != http.StatusOK || f.checks.Load() != 0 {
+		t.Fatalf("expected cached status, code %d, checks %d", code, f.checks.Load())
+	}
+
+	f.cached.LastChecked = time.Now().Add(-time.Hour)
+	if code, _ := getHealth(t, h, "/readyz"); code != http.StatusOK || f.checks.Load() != 1 {
+		t.Fatalf("expected a check for a stale status, code %d, checks %d", code, f.checks.Load())
+	}
+}
+
+func TestHealthHandler_ExtraChecks(t *testing.T) {
+	f := &fakeHealthChecker{status: &HealthStatus{Healthy: true}}
+	h := HealthHandler(f, HealthHandlerOptions{
+		Checks: []HealthCheck{{Name: "cache", Check: func(context.Context) error { return errors.New("cache down") }}},
+	})
+
+	code, status := getHealth(t, h, "/healthz?verbose")
+	if code != http.StatusOK || status.State != HealthStateDegraded {
+		t.Fatalf("non-critical failure: %d %+v", code, status)
+	}
+	checks, _ := status.Details["checks"].(map[string]any)
+	if checks["cache"] != "cache down" {
+		t.Fatalf("check results: %+v", status.Details)
+	}
+
+	h.AddCheck(HealthCheck{Name: "queue", Critical: true, Check: func(context.Context) error { return errors.New("queue down") }})
+	code, status = getHealth(t, h, "/healthz")
+	if code != http.StatusServiceUnavailable || status.State != HealthStateUnhealthy {
+		t.Fatalf("critical failure: %d %+v", code, status)
+	}
+	if status.Details != nil || status.Errors != nil {
+		t.Fatalf("non-verbose response should omit details: %+v", status)
+	}
+}
+
+func TestHealthHandler_MethodsAndPaths(t *testing.T) {
+	h := HealthHandler(&fakeHealthChecker{status: &HealthStatus{Healthy: true}}, HealthHandlerOptions{})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/readyz", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown path: %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/internal/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("prefixed path: %d", rec.Code)
+	}
+}
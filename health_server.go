@@ -0,0 +1,420 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// erParseError is returned by servers that do not know a statement, e.g.
+// SHOW REPLICA STATUS before MySQL 8.0.22.
+const erParseError = 1064
+
+// ServerHealthCheck is a check of the MySQL server run by DeepHealthCheck.
+// Implementations compare what they measure against the thresholds in
+// HealthCheckConfig and report breaches as HealthErrors.
+type ServerHealthCheck interface {
+	// Name identifies the check in status details
+	Name() string
+
+	// Check inspects the server through db
+	Check(ctx context.Context, db *sql.DB, config HealthCheckConfig) ServerCheckResult
+}
+
+// ServerCheckResult is the outcome of a ServerHealthCheck.
+type ServerCheckResult struct {
+	Details map[string]interface{} // Measurements, stored under Details["server"][name]
+	Errors  []HealthError          // Threshold breaches
+	Err     error                  // The check could not be run
+}
+
+// DefaultServerHealthChecks returns the read-only, replication lag,
+// thread saturation, aborted connects and buffer pool hit ratio checks.
+// The rate checks remember their previous sample, so reuse the returned
+// checks between health checks.
+func DefaultServerHealthChecks() []ServerHealthCheck {
+	return []ServerHealthCheck{
+		ReadOnlyCheck{},
+		ReplicationLagCheck{},
+		ThreadsConnectedCheck{},
+		&AbortedConnectsCheck{},
+		&BufferPoolHitRatioCheck{},
+	}
+}
+
+// serverHealthChecks returns the checks configured in config, or the pool's
+// default checks.
+func (p *Pool) serverHealthChecks(config HealthCheckConfig) []ServerHealthCheck {
+	if config.ServerChecks != nil {
+		return config.ServerChecks
+	}
+	p.serverChecksOnce.Do(func() { p.serverChecks = DefaultServerHealthChecks() })
+	return p.serverChecks
+}
+
+// performServerChecks runs the server checks, bounded by config.Timeout,
+// and records their results in status. Only non-recoverable errors make
+// status unhealthy.
+func (p *Pool) performServerChecks(ctx context.Context, config HealthCheckConfig, status *HealthStatus) {
+	db := p.getDB()
+	if db == nil {
+		return
+	}
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	details := make(map[string]interface{})
+	for _, check := range p.serverHealthChecks(config) {
+		res := check.Check(ctx, db, config)
+		if res.Details != nil {
+			details[check.Name()] = res.Details
+		}
+		if res.Err != nil {
+			status.Errors = append(status.Errors, HealthError{
+				Type:        "server_check",
+				Message:     fmt.Sprintf("%s check failed: %v", check.Name(), res.Err),
+				Timestamp:   time.Now(),
+				Recoverable: true,
+			})
+		}
+		for _, e := range res.Errors {
+			if !e.Recoverable {
+				status.Healthy = false
+			}
+		}
+		status.Errors = append(status.Errors, res.Errors...)
+	}
+	status.Details["server"] = details
+}
+
+// ReadOnlyCheck reports @@read_only and @@super_read_only on servers
+// expected to be writable (HealthCheckConfig.ExpectWritable).
+type ReadOnlyCheck struct{}
+
+// Name implements ServerHealthCheck.
+func (ReadOnlyCheck) Name() string { return "read_only" }
+
+// Check implements ServerHealthCheck.
+func (ReadOnlyCheck) Check(ctx context.Context, db *sql.DB, config HealthCheckConfig) ServerCheckResult {
+	vars, err := showGlobal(ctx, db, "VARIABLES", "read_only", "super_read_only")
+	if err != nil {
+		return ServerCheckResult{Err: err}
+	}
+	readOnly := serverFlag(vars["read_only"])
+	superReadOnly := serverFlag(vars["super_read_only"]) // absent on MariaDB
+	res := ServerCheckResult{Details: map[string]interface{}{
+		"read_only":       readOnly,
+		"super_read_only": superReadOnly,
+	}}
+	if config.ExpectWritable && (readOnly || superReadOnly) {
+		res.Errors = append(res.Errors, HealthError{
+			Type:        "read_only",
+			Message:     fmt.Sprintf("server is read-only (read_only=%v, super_read_only=%v) but the pool expects it to be writable", readOnly, superReadOnly),
+			Timestamp:   time.Now(),
+			Recoverable: false,
+		})
+	}
+	return res
+}
+
+// ReplicationLagCheck measures how far a replica is behind its source,
+// reading SHOW REPLICA STATUS or, on older servers, SHOW SLAVE STATUS.
+// Servers that are not replicas pass.
+type ReplicationLagCheck struct{}
+
+// Name implements ServerHealthCheck.
+func (ReplicationLagCheck) Name() string { return "replication" }
+
+// Check implements ServerHealthCheck.
+func (ReplicationLagCheck) Check(ctx context.Context, db *sql.DB, config HealthCheckConfig) ServerCheckResult {
+	channels, err := replicaStatus(ctx, db)
+	if err != nil {
+		return ServerCheckResult{Err: err}
+	}
+	res := ServerCheckResult{Details: map[string]interface{}{"replica": len(channels) > 0}}
+	if len(channels) == 0 {
+		return res
+	}
+
+	var lag time.Duration
+	stopped := false
+	for _, ch := range channels {
+		v := firstOf(ch, "Seconds_Behind_Source", "Seconds_Behind_Master")
+		if !v.Valid {
+			// NULL while the SQL or IO thread is not running
+			stopped = true
+			continue
+		}
+		secs, err := strconv.ParseInt(v.String, 10, 64)
+		if err != nil {
+			return ServerCheckResult{Err: fmt.Errorf("invalid replica lag %q: %w", v.String, err)}
+		}
+		if d := time.Duration(secs) * time.Second; d > lag {
+			lag = d
+		}
+	}
+	res.Details["lag"] = lag
+	res.Details["running"] = !stopped
+
+	if stopped {
+		res.Errors = append(res.Errors, HealthError{
+			Type:        "replication",
+			Message:     "replication is not running",
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+	}
+	if config.MaxReplicationLag > 0 && lag > config.MaxReplicationLag {
+		res.Errors = append(res.Errors, HealthError{
+			Type:        "replication_lag",
+			Message:     fmt.Sprintf("replica is %v behind its source, above %v", lag, config.MaxReplicationLag),
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+	}
+	return res
+}
+
+// ThreadsConnectedCheck compares Threads_connected against max_connections.
+type ThreadsConnectedCheck struct{}
+
+// Name implements ServerHealthCheck.
+func (ThreadsConnectedCheck) Name() string { return "threads" }
+
+// Check implements ServerHealthCheck.
+func (ThreadsConnectedCheck) Check(ctx context.Context, db *sql.DB, config HealthCheckConfig) ServerCheckResult {
+	vars, err := showGlobal(ctx, db, "VARIABLES", "max_connections")
+	if err != nil {
+		return ServerCheckResult{Err: err}
+	}
+	stat, err := showGlobal(ctx, db, "STATUS", "Threads_connected", "Threads_running")
+	if err != nil {
+		return ServerCheckResult{Err: err}
+	}
+	maxConns := serverInt(vars["max_connections"])
+	connected := serverInt(stat["Threads_connected"])
+	res := ServerCheckResult{Details: map[string]interface{}{
+		"threads_connected": connected,
+		"threads_running":   serverInt(stat["Threads_running"]),
+		"max_connections":   maxConns,
+	}}
+	if maxConns <= 0 {
+		return res
+	}
+	ratio := float64(connected) / float64(maxConns)
+	res.Details["ratio"] = ratio
+	if config.MaxThreadsConnectedRatio > 0 && ratio > config.MaxThreadsConnectedRatio {
+		res.Errors = append(res.Errors, HealthError{
+			Type:        "thread_saturation",
+			Message:     fmt.Sprintf("%d of %d connections in use (%.0f%%), above %.0f%%", connected, maxConns, ratio*100, config.MaxThreadsConnectedRatio*100),
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+	}
+	return res
+}
+
+// counterSample is a cumulative server counter read at a server uptime.
+type counterSample struct {
+	values []int64
+	uptime int64
+}
+
+// counterDelta returns the counter increase and the seconds elapsed since
+// prev, or the totals since server start when there is no usable previous
+// sample (first run or server restart).
+func counterDelta(prev, cur counterSample) (deltas []int64, seconds int64) {
+	usable := len(prev.values) == len(cur.values) && cur.uptime > prev.uptime
+	for i := range cur.values {
+		if usable && cur.values[i] < prev.values[i] {
+			usable = false
+		}
+	}
+	if !usable {
+		return cur.values, cur.uptime
+	}
+	deltas = make([]int64, len(cur.values))
+	for i := range cur.values {
+		deltas[i] = cur.values[i] - prev.values[i]
+	}
+	return deltas, cur.uptime - prev.uptime
+}
+
+// AbortedConnectsCheck measures the rate of failed connection attempts,
+// over the time since its previous run or, the first time, since the
+// server started.
+type AbortedConnectsCheck struct {
+	mu   sync.Mutex
+	prev counterSample
+}
+
+// Name implements ServerHealthCheck.
+func (*AbortedConnectsCheck) Name() string { return "aborted_connects" }
+
+// Check implements ServerHealthCheck.
+func (c *AbortedConnectsCheck) Check(ctx context.Context, db *sql.DB, config HealthCheckConfig) ServerCheckResult {
+	stat, err := showGlobal(ctx, db, "STATUS", "Aborted_connects", "Uptime")
+	if err != nil {
+		return ServerCheckResult{Err: err}
+	}
+	cur := counterSample{values: []int64{serverInt(stat["Aborted_connects"])}, uptime: serverInt(stat["Uptime"])}
+	c.mu.Lock()
+	deltas, seconds := counterDelta(c.prev, cur)
+	c.prev = cur
+	c.mu.Unlock()
+
+	res := ServerCheckResult{Details: map[string]interface{}{"aborted_connects": cur.values[0]}}
+	if seconds <= 0 {
+		return res
+	}
+	rate := float64(deltas[0]) / float64(seconds)
+	res.Details["rate"] = rate
+	if config.MaxAbortedConnectsRate > 0 && rate > config.MaxAbortedConnectsRate {
+		res.Errors = append(res.Errors, HealthError{
+			Type:        "aborted_connects",
+			Message:     fmt.Sprintf("%.2f aborted connection attempts per second, above %.2f", rate, config.MaxAbortedConnectsRate),
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+	}
+	return res
+}
+
+// BufferPoolHitRatioCheck measures the share of InnoDB buffer pool read
+// requests served from memory, over the same window as
+// AbortedConnectsCheck.
+type BufferPoolHitRatioCheck struct {
+	mu   sync.Mutex
+	prev counterSample
+}
+
+// Name implements ServerHealthCheck.
+func (*BufferPoolHitRatioCheck) Name() string { return "buffer_pool" }
+
+// Check implements ServerHealthCheck.
+func (c *BufferPoolHitRatioCheck) Check(ctx context.Context, db *sql.DB, config HealthCheckConfig) ServerCheckResult {
+	stat, err := showGlobal(ctx, db, "STATUS", "Innodb_buffer_pool_read_requests", "Innodb_buffer_pool_reads", "Uptime")
+	if err != nil {
+		return ServerCheckResult{Err: err}
+	}
+	cur := counterSample{
+		values: []int64{serverInt(stat["Innodb_buffer_pool_read_requests"]), serverInt(stat["Innodb_buffer_pool_reads"])},
+		uptime: serverInt(stat["Uptime"]),
+	}
+	c.mu.Lock()
+	deltas, _ := counterDelta(c.prev, cur)
+	c.prev = cur
+	c.mu.Unlock()
+
+	res := ServerCheckResult{Details: map[string]interface{}{}}
+	requests, reads := deltas[0], deltas[1]
+	if requests <= 0 {
+		return res
+	}
+	ratio := 1 - float64(reads)/float64(requests)
+	res.Details["hit_ratio"] = ratio
+	if config.MinBufferPoolHitRatio > 0 && ratio < config.MinBufferPoolHitRatio {
+		res.Errors = append(res.Errors, HealthError{
+			Type:        "buffer_pool_hit_ratio",
+			Message:     fmt.Sprintf("InnoDB buffer pool hit ratio %.2f%% is below %.2f%%", ratio*100, config.MinBufferPoolHitRatio*100),
+			Timestamp:   time.Now(),
+			Recoverable: true,
+		})
+	}
+	return res
+}
+
+// showGlobal reads the named global variables or status counters (kind is
+// "VARIABLES" or "STATUS"), or all of them when no names are given. Names
+// the server does not know are absent from the result.
+func showGlobal(ctx context.Context, db *sql.DB, kind string, names ...string) (map[string]string, error) {
+	query := "SHOW GLOBAL " + kind
+	if len(names) > 0 {
+		quoted := make([]string, len(names))
+		for i, n := range names {
+			quoted[i] = "'" + n + "'"
+		}
+		query += fmt.Sprintf(" WHERE Variable_name IN (%s)", strings.Join(quoted, ", "))
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read global %s: %w", strings.ToLower(kind), err)
+	}
+	defer rows.Close()
+	out := make(map[string]string, len(names))
+	for rows.Next() {
+		var name string
+		var value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		out[name] = value.String
+	}
+	return out, rows.Err()
+}
+
+// replicaStatus returns one row per replication channel, keyed by column
+// name. It is empty when the server is not a replica.
+func replicaStatus(ctx context.Context, db *sql.DB) ([]map[string]sql.NullString, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	var me *mysql.MySQLError
+	if err != nil && errors.As(err, &me) && me.Number == erParseError {
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replica status: %w", err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var channels []map[string]sql.NullString
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		ch := make(map[string]sql.NullString, len(cols))
+		for i, c := range cols {
+			ch[c] = values[i]
+		}
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+// firstOf returns the first of the named columns present in row.
+func firstOf(row map[string]sql.NullString, names ...string) sql.NullString {
+	for _, n := range names {
+		if v, ok := row[n]; ok {
+			return v
+		}
+	}
+	return sql.NullString{}
+}
+
+// serverFlag parses a boolean server variable ("ON", "1").
+func serverFlag(v string) bool {
+	return strings.EqualFold(v, "ON") || v == "1"
+}
+
+// serverInt parses an integer server variable, returning 0 when missing.
+func serverInt(v string) int64 {
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return n
+}
@@ -0,0 +1,173 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// healthyFakeServer returns a primary with no server check findings.
+func healthyFakeServer() *fakeServer {
+	s := newFakeServer()
+	s.setGlobals("VARIABLES", map[string]string{"read_only": "OFF", "super_read_only": "OFF", "max_connections": "100"})
+	s.setGlobals("STATUS", map[string]string{
+		"Threads_connected": "10", "Threads_running": "2", "Aborted_connects": "10", "Uptime": "1000",
+		"Innodb_buffer_pool_read_requests": "100000", "Innodb_buffer_pool_reads": "100",
+	})
+	s.set("SHOW REPLICA STATUS", serverResult{cols: []string{"Replica_IO_Running", "Seconds_Behind_Source"}})
+	return s
+}
+
+// serverThresholds returns a configuration with all server check
+// thresholds enabled.
+func serverThresholds() HealthCheckConfig {
+	config := DefaultHealthCheckConfig()
+	config.MaxReplicationLag = 30 * time.Second
+	config.MaxThreadsConnectedRatio = 0.9
+	config.MaxAbortedConnectsRate = 1
+	config.MinBufferPoolHitRatio = 0.95
+	return config
+}
+
+func serverErrorTypes(status *HealthStatus) []string {
+	var types []string
+	for _, e := range status.Errors {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestServerChecks_Healthy(t *testing.T) {
+	p := healthyFakeServer().pool(t)
+	config := serverThresholds()
+	config.ExpectWritable = true
+	status := &HealthStatus{Healthy: true, Details: map[string]interface{}{}}
+	p.performServerChecks(context.Background(), config, status)
+	if !status.Healthy || len(status.Errors) != 0 {
+		t.Fatalf("expected healthy, got %v", status.Errors)
+	}
+	server := status.Details["server"].(map[string]interface{})
+	if server["threads"].(map[string]interface{})["ratio"] != 0.1 || server["replication"].(map[string]interface{})["replica"] != false {
+		t.Fatalf("details: %+v", server)
+	}
+}
+
+func TestServerChecks_ReportBreaches(t *testing.T) {
+	s := healthyFakeServer()
+	s.setGlobals("VARIABLES", map[string]string{"read_only": "ON", "super_read_only": "ON", "max_connections": "100"})
+	s.setGlobals("STATUS", map[string]string{
+		"Threads_connected": "95", "Aborted_connects": "5000", "Uptime": "1000",
+		"Innodb_buffer_pool_read_requests": "1000", "Innodb_buffer_pool_reads": "500",
+	})
+	// A server without SHOW REPLICA STATUS, lagging
+	s.set("SHOW REPLICA STATUS", serverResult{err: &mysql.MySQLError{Number: 1064, Message: "syntax error"}})
+	s.set("SHOW SLAVE STATUS", serverResult{
+		cols: []string{"Slave_IO_Running", "Seconds_Behind_Master"},
+		rows: [][]driver.Value{{"Yes", "120"}},
+	})
+	p := s.pool(t)
+
+	config := serverThresholds()
+	config.ExpectWritable = true
+	status := &HealthStatus{Healthy: true, Details: map[string]interface{}{}}
+	p.performServerChecks(context.Background(), config, status)
+
+	got := strings.Join(serverErrorTypes(status), ",")
+	if got != "read_only,replication_lag,thread_saturation,aborted_connects,buffer_pool_hit_ratio" || status.Healthy {
+		t.Fatalf("errors: %v", status.Errors)
+	}
+
+	// Recoverable breaches leave the status healthy
+	config.ExpectWritable = false
+	status = &HealthStatus{Healthy: true, Details: map[string]interface{}{}}
+	p.performServerChecks(context.Background(), config, status)
+	if len(status.Errors) != 4 || !status.Healthy {
+		t.Fatalf("recoverable breaches: healthy %v, %v", status.Healthy, status.Errors)
+	}
+
+	// Thresholds of zero disable the checks, as by default
+	config = DefaultHealthCheckConfig()
+	status = &HealthStatus{Healthy: true, Details: map[string]interface{}{}}
+	p.performServerChecks(context.Background(), config, status)
+	if len(status.Errors) != 0 {
+		t.Fatalf("disabled checks reported %v", status.Errors)
+	}
+}
+
+func TestServerChecks_StoppedReplicaAndCheckFailures(t *testing.T) {
+	s := healthyFakeServer()
+	s.set("SHOW REPLICA STATUS", serverResult{
+		cols: []string{"Replica_IO_Running", "Seconds_Behind_Source"},
+		rows: [][]driver.Value{{"No", nil}},
+	})
+	s.set("SHOW GLOBAL STATUS", serverResult{err: &mysql.MySQLError{Number: 1227, Message: "Access denied"}})
+	p := s.pool(t)
+
+	status := &HealthStatus{Healthy: true, Details: map[string]interface{}{}}
+	p.performServerChecks(context.Background(), DefaultHealthCheckConfig(), status)
+	if got := strings.Join(serverErrorTypes(status), ","); got != "replication,server_check,server_check,server_check" || !status.Healthy {
+		t.Fatalf("errors: %v", status.Errors)
+	}
+}
+
+func TestAbortedConnectsCheck_UsesDeltaBetweenRuns(t *testing.T) {
+	s := healthyFakeServer()
+	p := s.pool(t)
+	config := serverThresholds()
+	check := &AbortedConnectsCheck{}
+	config.ServerChecks = []ServerHealthCheck{check}
+	ctx := context.Background()
+
+	// 10 aborts over 1000s since startup
+	res := check.Check(ctx, p.getDB(), config)
+	if res.Details["rate"] != 0.01 || len(res.Errors) != 0 {
+		t.Fatalf("first run: %+v", res)
+	}
+
+	// 50 more within 10s
+	s.setGlobals("STATUS", map[string]string{"Aborted_connects": "60", "Uptime": "1010"})
+	res = check.Check(ctx, p.getDB(), config)
+	if res.Details["rate"] != 5.0 || len(res.Errors) != 1 {
+		t.Fatalf("second run: %+v", res)
+	}
+
+	// A restart resets the counters
+	s.setGlobals("STATUS", map[string]string{"Aborted_connects": "0", "Uptime": "5"})
+	res = check.Check(ctx, p.getDB(), config)
+	if res.Details["rate"] != 0.0 {
+		t.Fatalf("after restart: %+v", res)
+	}
+}
+
+func TestDeepHealthCheck_RunsConfiguredServerChecks(t *testing.T) {
+	s := healthyFakeServer()
+	s.set("SELECT 1", serverResult{cols: []string{"1"}, rows: [][]driver.Value{{int64(1)}}})
+	// Hinted with the time left before the check's deadline
+	s.set("SELECT /*+ MAX_EXECUTION_TIME(", serverResult{cols: []string{"1"}, rows: [][]driver.Value{{int64(1)}}})
+	p := s.pool(t)
+	h := DefaultHealthCheckConfig()
+	h.ServerChecks = []ServerHealthCheck{ThreadsConnectedCheck{}}
+	h.MaxThreadsConnectedRatio = 0.05
+	p.cfg.Health = &h
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	status, err := p.DeepHealthCheck(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Healthy || strings.Join(serverErrorTypes(status), ",") != "thread_saturation" {
+		t.Fatalf("status: %+v", status)
+	}
+
+	// Served as degraded rather than unavailable
+	code, served := getHealth(t, HealthHandler(&fakeHealthChecker{status: status}, HealthHandlerOptions{}), "/readyz")
+	if code != http.StatusOK || served.State != HealthStateDegraded {
+		t.Fatalf("served: %d %+v", code, served)
+	}
+}
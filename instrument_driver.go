@@ -0,0 +1,391 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queryEvent describes a statement run on an instrumented connection.
+type queryEvent struct {
+	operation string // "exec" or "query"
+	query     string
+	args      []driver.NamedValue
+	duration  time.Duration // for queries, until the rows were closed
+	firstRow  time.Duration // time to the first row; 0 if none was read
+	rows      int64         // rows read
+	affected  int64         // rows affected by an exec
+	err       error
+
+	// Set when the statement was interrupted by the cancellation of its
+	// context and Config.KillOnCancel is on: kill sends KILL QUERY for the
+	// server connection connID.
+	connID int64
+	kill   func(ctx context.Context) error
+}
+
+// values returns the statement arguments.
+func (e *queryEvent) values() []any {
+	if len(e.args) == 0 {
+		return nil
+	}
+	out := make([]any, len(e.args))
+	for i, a := range e.args {
+		out[i] = a.Value
+	}
+	return out
+}
+
+// internalQueryKey marks the contexts of statements the library runs for
+// its own housekeeping.
+type internalQueryKey struct{}
+
+// internalContext returns ctx marked so that its statements are not
+// counted as application traffic: they are left out of statement
+// statistics, query logs and slow query records. Health checks, server
+// status and process list reads, kills and EXPLAINs use it.
+func internalContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, internalQueryKey{}, true)
+}
+
+// isInternalQuery reports whether ctx was marked by internalContext.
+func isInternalQuery(ctx context.Context) bool {
+	internal, _ := ctx.Value(internalQueryKey{}).(bool)
+	return internal
+}
+
+// queryObserver receives an event for every statement run on an
+// instrumented connection.
+type queryObserver interface {
+	observeQuery(ctx context.Context, ev *queryEvent)
+}
+
+// instrumentedConnector wraps a driver.Connector so that every statement
+// run on its connections, whichever API issued it (Exec, Query, QueryRow,
+// prepared statements, transactions), is reported to a queryObserver.
+// Queries are timed until their rows are closed, so the time spent
+// reading results and the number of rows returned are included.
+type instrumentedConnector struct {
+	inner   driver.Connector
+	obs     queryObserver
+	connIDs bool // record CONNECTION_ID() of new connections (Config.KillOnCancel)
+
+	// Management connection used to kill interrupted statements
+	mgmtMu     sync.Mutex
+	mgmt       *sql.DB
+	mgmtClosed bool
+}
+
+// newInstrumentedConnector wraps inner to report statements to obs. With
+// connIDs set, the server connection ID of every new connection is
+// recorded so interrupted statements can be killed.
+func newInstrumentedConnector(inner driver.Connector, obs queryObserver, connIDs bool) driver.Connector {
+	return &instrumentedConnector{inner: inner, obs: obs, connIDs: connIDs}
+}
+
+// Connect implements driver.Connector.
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.inner.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ic := &instrumentedConn{Conn: conn, obs: c.obs, connector: c}
+	if c.connIDs {
+		// Without an ID the connection's statements are not killed
+		ic.connID, _ = queryConnectionID(ctx, conn)
+	}
+	return ic, nil
+}
+
+// Driver implements driver.Connector.
+func (c *instrumentedConnector) Driver() driver.Driver {
+	return c.inner.Driver()
+}
+
+// instrumentedConn reports the statements run on a driver connection. It
+// forwards the optional driver interfaces of the wrapped connection.
+type instrumentedConn struct {
+	driver.Conn
+	obs       queryObserver
+	connector *instrumentedConnector
+	connID    int64       // server connection ID; 0 unless recorded
+	killed    atomic.Bool // a statement was interrupted; discard the connection
+}
+
+// observeExec reports an exec and its affected rows.
+func (c *instrumentedConn) observeExec(ctx context.Context, query string, args []driver.NamedValue, start time.Time, res driver.Result, err error) {
+	ev := &queryEvent{operation: "exec", query: query, args: args, duration: time.Since(start), err: err}
+	if err == nil && res != nil {
+		ev.affected, _ = res.RowsAffected()
+	}
+	c.markInterrupted(ctx, ev)
+	c.obs.observeQuery(ctx, ev)
+}
+
+// observeQueryError reports a query that failed before returning rows.
+func (c *instrumentedConn) observeQueryError(ctx context.Context, query string, args []driver.NamedValue, start time.Time, err error) {
+	ev := &queryEvent{operation: "query", query: query, args: args, duration: time.Since(start), err: err}
+	c.markInterrupted(ctx, ev)
+	c.obs.observeQuery(ctx, ev)
+}
+
+// wrapRows returns rows that report the query when closed.
+func (c *instrumentedConn) wrapRows(ctx context.Context, query string, args []driver.NamedValue, start time.Time, rows driver.Rows) driver.Rows {
+	return &instrumentedRows{Rows: rows, ctx: ctx, conn: c, ev: queryEvent{operation: "query", query: query, args: args}, start: start}
+}
+
+// Prepare implements driver.Conn.
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	st, err := c.Conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: st, conn: c, query: query}, nil
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var st driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		st, err = pc.PrepareContext(ctx, query)
+	} else {
+		st, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: st, conn: c, query: query}, nil
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ex, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := ex.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.observeExec(ctx, query, args, start, res, err)
+	}
+	return res, err
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := qc.QueryContext(ctx, query, args)
+	if err != nil {
+		if err != driver.ErrSkip {
+			c.observeQueryError(ctx, query, args, start, err)
+		}
+		return nil, err
+	}
+	return c.wrapRows(ctx, query, args, start, rows), nil
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bt.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without ConnBeginTx
+}
+
+// Ping implements driver.Pinger.
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (c *instrumentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// IsValid implements driver.Validator.
+func (c *instrumentedConn) IsValid() bool {
+	if c.killed.Load() {
+		return false
+	}
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// ResetSession implements driver.SessionResetter.
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if c.killed.Load() {
+		return driver.ErrBadConn
+	}
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// instrumentedStmt reports each execution of a prepared statement.
+type instrumentedStmt struct {
+	driver.Stmt
+	conn  *instrumentedConn
+	query string
+}
+
+// ExecContext implements driver.StmtExecContext.
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else {
+		res, err = s.Stmt.Exec(namedValues(args)) //nolint:staticcheck // fallback for drivers without StmtExecContext
+	}
+	s.conn.observeExec(ctx, s.query, args, start, res, err)
+	return res, err
+}
+
+// QueryContext implements driver.StmtQueryContext.
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args)) //nolint:staticcheck // fallback for drivers without StmtQueryContext
+	}
+	if err != nil {
+		s.conn.observeQueryError(ctx, s.query, args, start, err)
+		return nil, err
+	}
+	return s.conn.wrapRows(ctx, s.query, args, start, rows), nil
+}
+
+// ColumnConverter implements driver.ColumnConverter.
+func (s *instrumentedStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if cc, ok := s.Stmt.(driver.ColumnConverter); ok { //nolint:staticcheck // forwarded for drivers that use it
+		return cc.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// namedValues converts args for the deprecated Stmt.Exec and Stmt.Query.
+func namedValues(args []driver.NamedValue) []driver.Value {
+	out := make([]driver.Value, len(args))
+	for i, a := range args {
+		out[i] = a.Value
+	}
+	return out
+}
+
+// instrumentedRows counts the rows read, notes the time to the first
+// row and reports the query when closed.
+type instrumentedRows struct {
+	driver.Rows
+	ctx    context.Context
+	conn   *instrumentedConn
+	ev     queryEvent
+	start  time.Time
+	closed bool
+}
+
+// Next implements driver.Rows.
+func (r *instrumentedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch err {
+	case nil:
+		if r.ev.rows == 0 {
+			r.ev.firstRow = time.Since(r.start)
+		}
+		r.ev.rows++
+	case io.EOF:
+	default:
+		r.ev.err = err
+		r.conn.markInterrupted(r.ctx, &r.ev)
+	}
+	return err
+}
+
+// Close implements driver.Rows.
+func (r *instrumentedRows) Close() error {
+	err := r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.ev.duration = time.Since(r.start)
+		r.conn.obs.observeQuery(r.ctx, &r.ev)
+	}
+	return err
+}
+
+// HasNextResultSet implements driver.RowsNextResultSet.
+func (r *instrumentedRows) HasNextResultSet() bool {
+	if nr, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return nr.HasNextResultSet()
+	}
+	return false
+}
+
+// NextResultSet implements driver.RowsNextResultSet.
+func (r *instrumentedRows) NextResultSet() error {
+	if nr, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return nr.NextResultSet()
+	}
+	return io.EOF
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType.
+func (r *instrumentedRows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(any)).Elem()
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (r *instrumentedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+// ColumnTypeLength implements driver.RowsColumnTypeLength.
+func (r *instrumentedRows) ColumnTypeLength(index int) (int64, bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+// ColumnTypeNullable implements driver.RowsColumnTypeNullable.
+func (r *instrumentedRows) ColumnTypeNullable(index int) (bool, bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+// ColumnTypePrecisionScale implements driver.RowsColumnTypePrecisionScale.
+func (r *instrumentedRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,105 @@
+package ygggo_mysql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentation_RecordsEveryPathWithoutLogging(t *testing.T) {
+	p := newStatsTestPool(t, StatementStatsConfig{})
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = 0
+	p.slowQueryRecorder = NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(100))
+	defer p.slowQueryRecorder.Close()
+
+	ctx := context.Background()
+	err := p.WithConn(ctx, func(c DatabaseConn) error {
+		var x int
+		if err := c.QueryRow(ctx, "SELECT x FROM row_path").Scan(&x); err != nil {
+			return err
+		}
+		rs, err := c.Query(ctx, "SELECT x FROM query_path")
+		if err != nil {
+			return err
+		}
+		for rs.Next() {
+		}
+		rs.Close()
+		cc := c.(*Conn)
+		cc.EnableStmtCache(4)
+		if _, err := cc.ExecCached(ctx, "UPDATE cached_path SET a = ?", 1); err != nil {
+			return err
+		}
+		rs, err = cc.QueryCached(ctx, "SELECT x FROM cached_query_path WHERE id = ?", 1)
+		if err != nil {
+			return err
+		}
+		for rs.Next() {
+		}
+		return rs.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.getDB().ExecContext(ctx, "DELETE FROM db_path"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := p.slowQueryRecorder.GetRecords(ctx, SlowQueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]*SlowQueryRecord)
+	for _, r := range records {
+		seen[r.Query] = r
+	}
+	for _, q := range []string{"SELECT x FROM row_path", "SELECT x FROM query_path", "UPDATE cached_path SET a = ?", "SELECT x FROM cached_query_path WHERE id = ?", "DELETE FROM db_path"} {
+		if seen[q] == nil {
+			t.Fatalf("%q was not recorded; got %d records", q, len(records))
+		}
+	}
+	if r := seen["SELECT x FROM query_path"]; r.RowsSent != 3 {
+		t.Fatalf("rows sent: %+v", r)
+	}
+}
+
+func TestInstrumentation_LogsRowsAndFirstRow(t *testing.T) {
+	p := newStatsTestPool(t, StatementStatsConfig{})
+	var buf bytes.Buffer
+	p.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	p.EnableLogging(true)
+
+	ctx := context.Background()
+	err := p.WithConn(ctx, func(c DatabaseConn) error {
+		rs, err := c.Query(ctx, "SELECT x FROM t")
+		if err != nil {
+			return err
+		}
+		defer rs.Close()
+		for rs.Next() {
+		}
+		return rs.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]any
+		if json.Unmarshal([]byte(line), &e) == nil && e["query"] == "SELECT x FROM t" {
+			entry = e
+		}
+	}
+	if entry == nil {
+		t.Fatalf("query was not logged: %s", buf.String())
+	}
+	if entry["operation"] != "query" || entry["rows"] != float64(3) || entry["first_row_ms"] == nil {
+		t.Fatalf("log entry: %v", entry)
+	}
+}
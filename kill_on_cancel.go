@@ -0,0 +1,229 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// defaultKillOnCancelRate is the default Config.KillOnCancelRate.
+const defaultKillOnCancelRate = 10
+
+// killOnCancelTimeout bounds a KILL QUERY sent for a canceled statement.
+const killOnCancelTimeout = 5 * time.Second
+
+// ErrKillRateLimited is reported when a KILL QUERY for a canceled
+// statement is skipped because Config.KillOnCancelRate was exceeded.
+var ErrKillRateLimited = errors.New("kill on cancel rate limit exceeded")
+
+// tokenBucket is a token bucket rate limiter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full bucket allowing rate events per second and
+// bursts of up to rate events (at least 1).
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// allow takes a token if one is available.
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// killInterrupted sends KILL QUERY for a statement interrupted by the
+// cancellation of its context, so it stops running on the server. The
+// kill is sent in the background, rate limited by Config.KillOnCancelRate.
+// It returns ErrKillRateLimited when the kill was skipped.
+func (p *Pool) killInterrupted(ev *queryEvent) error {
+	if p.killLimiter != nil && !p.killLimiter.allow() {
+		p.logKill(ev.connID, ErrKillRateLimited)
+		return ErrKillRateLimited
+	}
+	p.killWG.Add(1)
+	go func() {
+		defer p.killWG.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), killOnCancelTimeout)
+		defer cancel()
+		p.logKill(ev.connID, ev.kill(ctx))
+	}()
+	return nil
+}
+
+// logKill logs the outcome of a KILL QUERY sent for a canceled statement.
+func (p *Pool) logKill(connID int64, err error) {
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	if _, ok := p.loggingFor(LogQueries, level); !ok {
+		return
+	}
+	attrs := []slog.Attr{slog.Int64("connection_id", connID)}
+	if err != nil {
+		attrs = append(attrs,
+			slog.String("status", "error"),
+			slog.String("error", p.getRedactor().String(err.Error())),
+		)
+	} else {
+		attrs = append(attrs, slog.String("status", "success"))
+	}
+	p.logger.LogAttrs(context.Background(), level, "kill query on cancel", attrs...)
+}
+
+// ConnectionID returns the server connection ID (CONNECTION_ID()) of the
+// connection, recorded when it was acquired. It is 0 unless
+// Config.KillOnCancel is set.
+func (c *Conn) ConnectionID() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.connID
+}
+
+// driverConnID returns the connection ID recorded for the driver
+// connection of c, or 0.
+func driverConnID(c *sql.Conn) int64 {
+	var id int64
+	_ = c.Raw(func(dc any) error {
+		if ic, ok := dc.(*instrumentedConn); ok {
+			id = ic.connID
+		}
+		return nil
+	})
+	return id
+}
+
+// queryConnectionID runs SELECT CONNECTION_ID() directly on a driver
+// connection.
+func queryConnectionID(ctx context.Context, conn driver.Conn) (int64, error) {
+	const query = "SELECT CONNECTION_ID()"
+	var rows driver.Rows
+	var err error
+	if qc, ok := conn.(driver.QueryerContext); ok {
+		rows, err = qc.QueryContext(ctx, query, nil)
+	} else {
+		err = driver.ErrSkip
+	}
+	if err == driver.ErrSkip {
+		var st driver.Stmt
+		if st, err = conn.Prepare(query); err != nil {
+			return 0, err
+		}
+		defer st.Close()
+		rows, err = st.Query(nil) //nolint:staticcheck // fallback for drivers without QueryerContext
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.Columns()))
+	if len(dest) == 0 {
+		return 0, errors.New("CONNECTION_ID() returned no columns")
+	}
+	if err := rows.Next(dest); err != nil {
+		if err == io.EOF {
+			return 0, errors.New("CONNECTION_ID() returned no rows")
+		}
+		return 0, err
+	}
+	switch v := dest[0].(type) {
+	case int64:
+		return v, nil
+	case uint64:
+		return int64(v), nil
+	case []byte:
+		var id int64
+		_, err := fmt.Sscan(string(v), &id)
+		return id, err
+	default:
+		return 0, fmt.Errorf("unexpected CONNECTION_ID() value %T", v)
+	}
+}
+
+// killQuery runs KILL QUERY id on the connector's management connection,
+// which is opened on first use from the wrapped connector, so the kill
+// reaches the server the killed connection belongs to. A connection that
+// has already ended is not an error.
+func (c *instrumentedConnector) killQuery(ctx context.Context, id int64) error {
+	c.mgmtMu.Lock()
+	if c.mgmtClosed {
+		c.mgmtMu.Unlock()
+		return errors.New("pool is closed")
+	}
+	if c.mgmt == nil {
+		c.mgmt = sql.OpenDB(c.inner)
+		c.mgmt.SetMaxOpenConns(1)
+	}
+	mgmt := c.mgmt
+	c.mgmtMu.Unlock()
+
+	_, err := mgmt.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", id))
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == erNoSuchThread {
+		return nil
+	}
+	return err
+}
+
+// Close closes the management connection. sql.DB.Close calls it.
+func (c *instrumentedConnector) Close() error {
+	c.mgmtMu.Lock()
+	defer c.mgmtMu.Unlock()
+	c.mgmtClosed = true
+	if c.mgmt == nil {
+		return nil
+	}
+	return c.mgmt.Close()
+}
+
+// interrupted reports whether err was caused by the cancellation of ctx
+// while a statement ran, when connection IDs are tracked. The connection
+// is then discarded instead of being reused, so the KILL QUERY sent for
+// it cannot interrupt a later statement.
+func (c *instrumentedConn) interrupted(ctx context.Context, err error) bool {
+	if err == nil || c.connID == 0 || ctx.Err() == nil {
+		return false
+	}
+	c.killed.Store(true)
+	return true
+}
+
+// markInterrupted sets the kill of ev when the statement was interrupted.
+func (c *instrumentedConn) markInterrupted(ctx context.Context, ev *queryEvent) {
+	if !c.interrupted(ctx, ev.err) {
+		return
+	}
+	id, connector := c.connID, c.connector
+	ev.connID = id
+	ev.kill = func(ctx context.Context) error { return connector.killQuery(ctx, id) }
+}
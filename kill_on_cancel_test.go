@@ -0,0 +1,168 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newKillTestPool returns a pool on a fake server whose SLEEP statements,
+// hinted or not, block until their context is done.
+func newKillTestPool(t *testing.T, cfg Config) (*Pool, *fakeServer) {
+	t.Helper()
+	s := newFakeServer()
+	s.set("", serverResult{cols: []string{"x"}, rows: [][]driver.Value{{int64(1)}}})
+	for _, prefix := range []string{"SELECT SLEEP", "SELECT /*+ MAX_EXECUTION_TIME", "DO SLEEP"} {
+		s.set(prefix, serverResult{block: true})
+	}
+	cfg.Driver = fakeServerDriver
+	p := &Pool{cfg: cfg}
+	db, err := openDB(context.Background(), cfg, s.dsn(t), p)
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	p.db = db
+	if err := p.startBackground(); err != nil {
+		t.Fatal(err)
+	}
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = time.Hour
+	p.slowQueryRecorder = NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(10))
+	t.Cleanup(func() { p.Close() })
+	return p, s
+}
+
+func TestKillOnCancel_KillsInterruptedStatements(t *testing.T) {
+	p, s := newKillTestPool(t, Config{KillOnCancel: true})
+	ctx := context.Background()
+
+	dc, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := dc.(*Conn)
+	id := conn.ConnectionID()
+	if id == 0 {
+		t.Fatal("connection ID was not recorded")
+	}
+
+	qctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := conn.Query(qctx, "SELECT SLEEP(10)"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	conn.Close()
+	p.killWG.Wait()
+	if kills := s.executed("KILL"); len(kills) != 1 || kills[0] != fmt.Sprintf("KILL QUERY %d", id) {
+		t.Fatalf("kills: %v", kills)
+	}
+
+	// The interrupted connection is not reused
+	dc, err = p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.(*Conn).ConnectionID() == id {
+		t.Fatal("interrupted connection was reused")
+	}
+	dc.Close()
+
+	// Reported to the slow query recorder below its threshold
+	records, _ := p.slowQueryRecorder.GetRecords(ctx, SlowQueryFilter{})
+	if len(records) != 1 || !strings.HasSuffix(records[0].Query, "SLEEP(10)") || !strings.Contains(records[0].Error, "KILL QUERY") {
+		t.Fatalf("records: %+v", records)
+	}
+}
+
+func TestKillOnCancel_ExecAndRateLimit(t *testing.T) {
+	p, s := newKillTestPool(t, Config{KillOnCancel: true, KillOnCancelRate: 0.001})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		qctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		_, err := p.getDB().ExecContext(qctx, "DO SLEEP(10)")
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline error, got %v", err)
+		}
+	}
+	p.killWG.Wait()
+	if kills := s.executed("KILL"); len(kills) != 1 {
+		t.Fatalf("expected a single kill, got %v", kills)
+	}
+	records, _ := p.slowQueryRecorder.GetRecords(ctx, SlowQueryFilter{})
+	if len(records) != 2 || !strings.Contains(records[0].Error+records[1].Error, "skipped") {
+		t.Fatalf("records: %+v", records)
+	}
+}
+
+func TestKillOnCancel_Disabled(t *testing.T) {
+	p, s := newKillTestPool(t, Config{})
+	ctx := context.Background()
+	dc, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+	if dc.(*Conn).ConnectionID() != 0 {
+		t.Fatal("connection ID recorded without KillOnCancel")
+	}
+	qctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	dc.Query(qctx, "SELECT SLEEP(10)")
+	p.killWG.Wait()
+	if kills := s.executed("KILL"); len(kills) != 0 {
+		t.Fatalf("kills: %v", kills)
+	}
+}
+
+func TestShutdown_WaitsForKills(t *testing.T) {
+	p, _ := newKillTestPool(t, Config{KillOnCancel: true})
+	var killed atomic.Bool
+	p.killWG.Add(1)
+	go func() {
+		defer p.killWG.Done()
+		time.Sleep(50 * time.Millisecond)
+		killed.Store(true)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !killed.Load() {
+		t.Fatal("Shutdown returned before the kill finished")
+	}
+
+	// The wait is bounded by the context
+	p, _ = newKillTestPool(t, Config{KillOnCancel: true})
+	release := make(chan struct{})
+	defer close(release)
+	p.killWG.Add(1)
+	go func() {
+		defer p.killWG.Done()
+		<-release
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2)
+	if !b.allow() || !b.allow() || b.allow() {
+		t.Fatal("expected a burst of 2")
+	}
+	b.last = b.last.Add(-time.Second)
+	if !b.allow() {
+		t.Fatal("expected tokens to refill")
+	}
+}
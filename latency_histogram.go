@@ -0,0 +1,330 @@
+package ygggo_mysql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// histogramSubBits sets the histogram precision: each power of two is
+// split into 2^histogramSubBits buckets, so recorded values are accurate
+// to within about 3%.
+const histogramSubBits = 5
+
+// LatencyHistogram is a mergeable log-linear latency sketch in the style
+// of an HDR histogram. It keeps counts in buckets whose width grows with
+// the value, so quantiles are accurate to a few percent at any scale and
+// memory stays small. Histograms of different patterns, time windows or
+// processes can be merged. It is safe for concurrent use.
+type LatencyHistogram struct {
+	mu      sync.Mutex
+	count   int64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+	buckets map[int]int64
+}
+
+// HistogramBucket is a range of latencies and the number of values in it.
+type HistogramBucket struct {
+	Lower time.Duration `json:"lower"` // inclusive
+	Upper time.Duration `json:"upper"` // exclusive
+	Count int64         `json:"count"`
+}
+
+// LatencyPercentiles holds the common latency percentiles.
+type LatencyPercentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+}
+
+// NewLatencyHistogram returns an empty histogram.
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{buckets: make(map[int]int64)}
+}
+
+// histogramIndex returns the bucket index of v.
+func histogramIndex(v int64) int {
+	if v < 0 {
+		v = 0
+	}
+	const linear = 1 << (histogramSubBits + 1)
+	if v < linear {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - (histogramSubBits + 1)
+	mantissa := int(v >> shift)
+	return linear + (shift-1)<<histogramSubBits + mantissa - 1<<histogramSubBits
+}
+
+// histogramBounds returns the range [lower, upper) covered by bucket i.
+func histogramBounds(i int) (int64, int64) {
+	const linear = 1 << (histogramSubBits + 1)
+	if i < linear {
+		return int64(i), int64(i) + 1
+	}
+	shift := (i-linear)>>histogramSubBits + 1
+	mantissa := int64((i-linear)&(1<<histogramSubBits-1) + 1<<histogramSubBits)
+	return mantissa << shift, (mantissa + 1) << shift
+}
+
+// Record adds a latency to the histogram.
+func (h *LatencyHistogram) Record(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.buckets == nil {
+		h.buckets = make(map[int]int64)
+	}
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+	h.buckets[histogramIndex(int64(d))]++
+}
+
+// Merge adds all values recorded in other to h.
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	if other == nil || other == h {
+		return
+	}
+	snap := other.Clone()
+	if snap.count == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.buckets == nil {
+		h.buckets = make(map[int]int64)
+	}
+	if h.count == 0 || snap.min < h.min {
+		h.min = snap.min
+	}
+	if snap.max > h.max {
+		h.max = snap.max
+	}
+	h.count += snap.count
+	h.sum += snap.sum
+	for i, n := range snap.buckets {
+		h.buckets[i] += n
+	}
+}
+
+// Clone returns a copy of h.
+func (h *LatencyHistogram) Clone() *LatencyHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := &LatencyHistogram{count: h.count, sum: h.sum, min: h.min, max: h.max, buckets: make(map[int]int64, len(h.buckets))}
+	for i, n := range h.buckets {
+		c.buckets[i] = n
+	}
+	return c
+}
+
+// Count returns the number of recorded values.
+func (h *LatencyHistogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Mean returns the average of the recorded values.
+func (h *LatencyHistogram) Mean() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Max returns the largest recorded value.
+func (h *LatencyHistogram) Max() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.max
+}
+
+// Quantile returns the latency below which the fraction q (0..1) of the
+// recorded values fall. It returns 0 for an empty histogram.
+func (h *LatencyHistogram) Quantile(q float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.quantile(q)
+}
+
+func (h *LatencyHistogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.min
+	}
+	if q >= 1 {
+		return h.max
+	}
+	rank := int64(q*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for _, i := range h.sortedIndexes() {
+		seen += h.buckets[i]
+		if seen >= rank {
+			lower, upper := histogramBounds(i)
+			v := time.Duration(lower + (upper-lower)/2)
+			// The exact extremes are known
+			if v < h.min {
+				v = h.min
+			}
+			if v > h.max {
+				v = h.max
+			}
+			return v
+		}
+	}
+	return h.max
+}
+
+// Percentiles returns p50, p90, p95 and p99.
+func (h *LatencyHistogram) Percentiles() LatencyPercentiles {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return LatencyPercentiles{
+		P50: h.quantile(0.50),
+		P90: h.quantile(0.90),
+		P95: h.quantile(0.95),
+		P99: h.quantile(0.99),
+	}
+}
+
+// sortedIndexes returns the non-empty bucket indexes in ascending order.
+func (h *LatencyHistogram) sortedIndexes() []int {
+	idx := make([]int, 0, len(h.buckets))
+	for i, n := range h.buckets {
+		if n > 0 {
+			idx = append(idx, i)
+		}
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// Buckets returns the distribution in power-of-two buckets, from the
+// smallest to the largest non-empty bucket. Empty buckets in between are
+// included so the result can be plotted directly.
+func (h *LatencyHistogram) Buckets() []HistogramBucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return nil
+	}
+	counts := make(map[int]int64)
+	first, last := -1, -1
+	for i, n := range h.buckets {
+		lower, _ := histogramBounds(i)
+		octave := bits.Len64(uint64(lower))
+		counts[octave] += n
+		if first < 0 || octave < first {
+			first = octave
+		}
+		if octave > last {
+			last = octave
+		}
+	}
+	out := make([]HistogramBucket, 0, last-first+1)
+	for o := first; o <= last; o++ {
+		var lower int64
+		if o > 0 {
+			lower = 1 << (o - 1)
+		}
+		out = append(out, HistogramBucket{
+			Lower: time.Duration(lower),
+			Upper: time.Duration(int64(1) << o),
+			Count: counts[o],
+		})
+	}
+	return out
+}
+
+// Render draws the distribution as a text bar chart, one line per
+// power-of-two bucket, with bars up to width characters wide.
+func (h *LatencyHistogram) Render(width int) string {
+	return renderBuckets(h.Buckets(), width)
+}
+
+// renderBuckets draws buckets as a text bar chart.
+func renderBuckets(buckets []HistogramBucket, width int) string {
+	if len(buckets) == 0 {
+		return ""
+	}
+	if width <= 0 {
+		width = 40
+	}
+	var peak int64
+	for _, b := range buckets {
+		if b.Count > peak {
+			peak = b.Count
+		}
+	}
+	var sb strings.Builder
+	for _, b := range buckets {
+		bar := int(b.Count * int64(width) / peak)
+		if bar == 0 && b.Count > 0 {
+			bar = 1
+		}
+		fmt.Fprintf(&sb, "%10s - %10s |%-*s %d\n",
+			roundDuration(b.Lower), roundDuration(b.Upper), width, strings.Repeat("#", bar), b.Count)
+	}
+	return sb.String()
+}
+
+// roundDuration shortens d to three significant digits for display.
+func roundDuration(d time.Duration) time.Duration {
+	for unit := time.Duration(1); unit < time.Hour; unit *= 10 {
+		if d < 1000*unit {
+			return d.Round(unit)
+		}
+	}
+	return d.Round(time.Second)
+}
+
+// histogramJSON is the serialized form of a LatencyHistogram.
+type histogramJSON struct {
+	Count   int64         `json:"count"`
+	Sum     time.Duration `json:"sum"`
+	Min     time.Duration `json:"min"`
+	Max     time.Duration `json:"max"`
+	Buckets map[int]int64 `json:"buckets"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (h *LatencyHistogram) MarshalJSON() ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return json.Marshal(histogramJSON{Count: h.count, Sum: h.sum, Min: h.min, Max: h.max, Buckets: h.buckets})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *LatencyHistogram) UnmarshalJSON(data []byte) error {
+	var v histogramJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count, h.sum, h.min, h.max, h.buckets = v.Count, v.Sum, v.Min, v.Max, v.Buckets
+	if h.buckets == nil {
+		h.buckets = make(map[int]int64)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// within reports whether got is within 5% of want.
+func within(got, want time.Duration) bool {
+	diff := got - want
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= want/20
+}
+
+func TestLatencyHistogram_Quantiles(t *testing.T) {
+	h := NewLatencyHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	p := h.Percentiles()
+	for name, c := range map[string][2]time.Duration{
+		"p50": {p.P50, 500 * time.Millisecond},
+		"p90": {p.P90, 900 * time.Millisecond},
+		"p95": {p.P95, 950 * time.Millisecond},
+		"p99": {p.P99, 990 * time.Millisecond},
+	} {
+		if !within(c[0], c[1]) {
+			t.Fatalf("%s=%v want ~%v", name, c[0], c[1])
+		}
+	}
+	if h.Count() != 1000 || h.Max() != time.Second || h.Quantile(1) != time.Second {
+		t.Fatalf("count=%d max=%v", h.Count(), h.Max())
+	}
+	if NewLatencyHistogram().Quantile(0.5) != 0 {
+		t.Fatalf("empty histogram should report 0")
+	}
+}
+
+func TestLatencyHistogram_BucketBounds(t *testing.T) {
+	for _, v := range []int64{0, 1, 63, 64, 65, 127, 128, 1000, 123456789, 1 << 40} {
+		lower, upper := histogramBounds(histogramIndex(v))
+		if v < lower || v >= upper {
+			t.Fatalf("value %d outside its bucket [%d, %d)", v, lower, upper)
+		}
+	}
+}
+
+func TestLatencyHistogram_MergeAndJSON(t *testing.T) {
+	a, b := NewLatencyHistogram(), NewLatencyHistogram()
+	for i := 0; i < 100; i++ {
+		a.Record(10 * time.Millisecond)
+		b.Record(100 * time.Millisecond)
+	}
+	a.Merge(b)
+	if a.Count() != 200 || !within(a.Quantile(0.25), 10*time.Millisecond) || !within(a.Quantile(0.75), 100*time.Millisecond) {
+		t.Fatalf("merged: count=%d p25=%v p75=%v", a.Count(), a.Quantile(0.25), a.Quantile(0.75))
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back LatencyHistogram
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Count() != 200 || back.Percentiles() != a.Percentiles() {
+		t.Fatalf("round trip changed histogram: %s", data)
+	}
+
+	out := a.Render(20)
+	if strings.Count(out, "\n") != len(a.Buckets()) || !strings.Contains(out, "####################") {
+		t.Fatalf("render:\n%s", out)
+	}
+}
+
+func TestSlowQueryAnalyzer_PatternLatencies(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemorySlowQueryStorage(1000)
+	base := time.Now().Add(-2 * time.Hour)
+	store := func(query string, d time.Duration, at time.Time) {
+		storage.Store(ctx, &SlowQueryRecord{
+			Query:           query,
+			NormalizedQuery: FingerprintQuery(query, NormalizeBasic),
+			Duration:        d,
+			Timestamp:       at,
+		})
+	}
+	// Baseline hour: both patterns fast; current hour: orders regresses
+	for i := 0; i < 50; i++ {
+		store("SELECT * FROM users WHERE id = 1", 100*time.Millisecond, base.Add(time.Duration(i)*time.Second))
+		store("SELECT * FROM orders WHERE id = 1", 100*time.Millisecond, base.Add(time.Duration(i)*time.Second))
+		store("SELECT * FROM users WHERE id = 1", 105*time.Millisecond, base.Add(time.Hour+time.Duration(i)*time.Second))
+		store("SELECT * FROM orders WHERE id = 1", 300*time.Millisecond, base.Add(time.Hour+time.Duration(i)*time.Second))
+	}
+
+	analyzer := NewSlowQueryAnalyzer(storage)
+	report, err := analyzer.GenerateReport(ctx, SlowQueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.PatternLatencies) != 2 {
+		t.Fatalf("expected 2 pattern latencies, got %d", len(report.PatternLatencies))
+	}
+	orders := report.PatternLatencies[0]
+	if orders.NormalizedQuery != "SELECT * FROM ORDERS WHERE ID = ?" || orders.Count != 100 || !within(orders.Latency.P95, 300*time.Millisecond) {
+		t.Fatalf("orders latency: %+v", orders)
+	}
+	var slotted int64
+	for _, slot := range report.TimeDistribution {
+		if slot.QueryCount > 0 && slot.Latency.P50 == 0 {
+			t.Fatalf("slot without percentiles: %+v", slot)
+		}
+		slotted += slot.QueryCount
+	}
+	if slotted != 200 {
+		t.Fatalf("slots hold %d queries", slotted)
+	}
+	if out := report.RenderHistograms(10); !strings.Contains(out, "SELECT * FROM ORDERS WHERE ID = ?") || !strings.Contains(out, "p95=") {
+		t.Fatalf("render:\n%s", out)
+	}
+
+	cmp, err := analyzer.CompareWindows(ctx,
+		TimeRange{Start: base, End: base.Add(time.Hour - time.Millisecond)},
+		TimeRange{Start: base.Add(time.Hour), End: base.Add(2 * time.Hour)},
+		0.2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmp.Patterns) != 2 || len(cmp.Regressions) != 1 {
+		t.Fatalf("comparison: %+v", cmp)
+	}
+	r := cmp.Regressions[0]
+	if r.NormalizedQuery != "SELECT * FROM ORDERS WHERE ID = ?" || r.Change < 1.5 || r.BaselineCount != 50 || r.CurrentCount != 50 {
+		t.Fatalf("regression: %+v", r)
+	}
+}
+
+func TestMemorySlowQueryStorage_PatternLatency(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemorySlowQueryStorage(10)
+	for i := 1; i <= 20; i++ {
+		storage.Store(ctx, &SlowQueryRecord{NormalizedQuery: "SELECT ?", Duration: time.Duration(i) * time.Millisecond})
+	}
+	patterns, _ := storage.GetPatterns(ctx, 1)
+	if len(patterns) != 1 || patterns[0].Latency == nil || patterns[0].Latency.Count() != 20 {
+		t.Fatalf("pattern latency not tracked: %+v", patterns)
+	}
+}
@@ -0,0 +1,97 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LockWait is an InnoDB transaction waiting for a lock held by another.
+type LockWait struct {
+	WaitingTrxID    string        `json:"waiting_trx_id"`
+	WaitingThread   int64         `json:"waiting_thread"` // Connection ID, as used by KILL
+	WaitingQuery    string        `json:"waiting_query"`
+	WaitingLockMode string        `json:"waiting_lock_mode"`
+	WaitTime        time.Duration `json:"wait_time"` // In whole seconds
+
+	BlockingTrxID    string `json:"blocking_trx_id"`
+	BlockingThread   int64  `json:"blocking_thread"`
+	BlockingQuery    string `json:"blocking_query"` // Empty when the blocking transaction is idle
+	BlockingLockMode string `json:"blocking_lock_mode"`
+
+	Schema   string `json:"schema"`
+	Table    string `json:"table"`
+	Index    string `json:"index"`
+	LockType string `json:"lock_type"` // RECORD or TABLE
+}
+
+const (
+	lockWaitsFromDataLocks = "SELECT w.REQUESTING_ENGINE_TRANSACTION_ID, rt.trx_mysql_thread_id, rt.trx_query, rl.LOCK_MODE, TIMESTAMPDIFF(SECOND, rt.trx_wait_started, NOW()), " +
+		"w.BLOCKING_ENGINE_TRANSACTION_ID, bt.trx_mysql_thread_id, bt.trx_query, bl.LOCK_MODE, " +
+		"CONCAT('`', rl.OBJECT_SCHEMA, '`.`', rl.OBJECT_NAME, '`'), rl.INDEX_NAME, rl.LOCK_TYPE " +
+		"FROM performance_schema.data_lock_waits w " +
+		"JOIN information_schema.INNODB_TRX rt ON rt.trx_id = w.REQUESTING_ENGINE_TRANSACTION_ID " +
+		"JOIN information_schema.INNODB_TRX bt ON bt.trx_id = w.BLOCKING_ENGINE_TRANSACTION_ID " +
+		"JOIN performance_schema.data_locks rl ON rl.ENGINE_LOCK_ID = w.REQUESTING_ENGINE_LOCK_ID " +
+		"JOIN performance_schema.data_locks bl ON bl.ENGINE_LOCK_ID = w.BLOCKING_ENGINE_LOCK_ID " +
+		"ORDER BY rt.trx_wait_started"
+	lockWaitsFromSys = "SELECT waiting_trx_id, waiting_pid, waiting_query, waiting_lock_mode, wait_age_secs, " +
+		"blocking_trx_id, blocking_pid, blocking_query, blocking_lock_mode, locked_table, locked_index, locked_type " +
+		"FROM sys.innodb_lock_waits"
+)
+
+// LockWaits returns the InnoDB lock waits on the server, read from
+// performance_schema.data_lock_waits (MySQL 8.0) or from
+// sys.innodb_lock_waits on older servers. Waits of other users are only
+// visible with the PROCESS privilege.
+func (p *Pool) LockWaits(ctx context.Context) ([]LockWait, error) {
+	db := p.getDB()
+	if db == nil {
+		return nil, fmt.Errorf("pool or database is nil")
+	}
+	ctx = internalContext(ctx)
+	waits, err := queryLockWaits(ctx, db, lockWaitsFromDataLocks)
+	if err == nil {
+		return waits, nil
+	}
+	waits, err = queryLockWaits(ctx, db, lockWaitsFromSys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock waits: %w", err)
+	}
+	return waits, nil
+}
+
+func queryLockWaits(ctx context.Context, db *sql.DB, query string) ([]LockWait, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var waits []LockWait
+	for rows.Next() {
+		var w LockWait
+		var waitingQuery, waitingMode, blockingQuery, blockingMode, table, index, lockType sql.NullString
+		var waitingThread, blockingThread, secs sql.NullInt64
+		if err := rows.Scan(&w.WaitingTrxID, &waitingThread, &waitingQuery, &waitingMode, &secs,
+			&w.BlockingTrxID, &blockingThread, &blockingQuery, &blockingMode, &table, &index, &lockType); err != nil {
+			return nil, err
+		}
+		w.WaitingThread, w.WaitingQuery, w.WaitingLockMode = waitingThread.Int64, waitingQuery.String, waitingMode.String
+		w.BlockingThread, w.BlockingQuery, w.BlockingLockMode = blockingThread.Int64, blockingQuery.String, blockingMode.String
+		w.WaitTime = time.Duration(secs.Int64) * time.Second
+		w.Schema, w.Table = splitQualifiedTable(table.String)
+		w.Index, w.LockType = index.String, lockType.String
+		waits = append(waits, w)
+	}
+	return waits, rows.Err()
+}
+
+// splitQualifiedTable splits a `schema`.`table` name as printed by InnoDB.
+func splitQualifiedTable(name string) (schema, table string) {
+	if i := strings.Index(name, "`.`"); i >= 0 {
+		return strings.Trim(name[:i], "`"), strings.Trim(name[i+3:], "`")
+	}
+	return "", strings.Trim(name, "`")
+}
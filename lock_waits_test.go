@@ -0,0 +1,48 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var lockWaitColumns = []string{"waiting_trx_id", "waiting_pid", "waiting_query", "waiting_lock_mode", "wait_age_secs",
+	"blocking_trx_id", "blocking_pid", "blocking_query", "blocking_lock_mode", "locked_table", "locked_index", "locked_type"}
+
+func TestLockWaits(t *testing.T) {
+	s := newFakeServer()
+	s.set("SELECT w.REQUESTING_ENGINE_TRANSACTION_ID", serverResult{cols: lockWaitColumns, rows: [][]driver.Value{
+		{"5433", int64(13), "UPDATE accounts SET balance = 0 WHERE id = 1", "X,REC_NOT_GAP", int64(4),
+			"5432", int64(12), nil, "X,REC_NOT_GAP", "`bank`.`accounts`", "PRIMARY", "RECORD"},
+	}})
+	waits, err := s.pool(t).LockWaits(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(waits) != 1 {
+		t.Fatalf("expected 1 wait, got %d", len(waits))
+	}
+	w := waits[0]
+	if w.WaitingTrxID != "5433" || w.WaitingThread != 13 || w.WaitTime != 4*time.Second || w.BlockingThread != 12 ||
+		w.BlockingQuery != "" || w.Schema != "bank" || w.Table != "accounts" || w.Index != "PRIMARY" || w.LockType != "RECORD" {
+		t.Fatalf("wait: %+v", w)
+	}
+
+	// Servers without data_lock_waits use the sys schema
+	s.set("SELECT w.REQUESTING_ENGINE_TRANSACTION_ID", serverResult{err: &mysql.MySQLError{Number: 1146, Message: "Table 'performance_schema.data_lock_waits' doesn't exist"}})
+	s.set("SELECT waiting_trx_id", serverResult{cols: lockWaitColumns, rows: [][]driver.Value{
+		{"7", int64(3), "DELETE FROM t", "X", int64(1), "6", int64(2), "UPDATE t SET a = 1", "X", "`db`.`t`", nil, "RECORD"},
+	}})
+	waits, err = s.pool(t).LockWaits(context.Background())
+	if err != nil || len(waits) != 1 || waits[0].BlockingQuery != "UPDATE t SET a = 1" || waits[0].Table != "t" {
+		t.Fatalf("fallback: %+v, %v", waits, err)
+	}
+
+	s.set("SELECT waiting_trx_id", serverResult{err: &mysql.MySQLError{Number: 1142, Message: "SELECT command denied"}})
+	if _, err := s.pool(t).LockWaits(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -2,21 +2,97 @@ package ygggo_mysql
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
 	"log/slog"
 	"os"
 	"time"
+	"unicode/utf8"
 
 	mysql "github.com/go-sql-driver/mysql"
 )
 
-// LoggingConfig holds logging configuration
+// ArgLogMode selects how query arguments appear in query logs.
+type ArgLogMode string
+
+const (
+	// ArgLogNone omits arguments.
+	ArgLogNone ArgLogMode = "none"
+	// ArgLogCount logs the number of arguments (the default).
+	ArgLogCount ArgLogMode = "count"
+	// ArgLogRedacted logs the arguments redacted by the pool's Redactor.
+	ArgLogRedacted ArgLogMode = "redacted"
+	// ArgLogFull logs the raw argument values. Use only in development.
+	ArgLogFull ArgLogMode = "full"
+)
+
+// LogOperation is a set of event categories that can be logged.
+type LogOperation uint8
+
+const (
+	LogQueries      LogOperation = 1 << iota // Query, QueryRow and cached queries
+	LogExecs                                 // Exec and cached statements
+	LogTransactions                          // commit and rollback of WithinTx
+	LogConnections                           // connection events
+	LogPoolStats                             // connection pool statistics
+
+	// LogAllOperations enables every category.
+	LogAllOperations = LogQueries | LogExecs | LogTransactions | LogConnections | LogPoolStats
+)
+
+// LoggingConfig holds logging configuration (see Pool.ConfigureLogging)
 type LoggingConfig struct {
-	Enabled            bool
+	// Enabled turns logging on or off
+	Enabled bool
+
+	// SlowQueryThreshold logs queries slower than this at Warn level as
+	// "slow query detected". Zero keeps the pool's threshold; otherwise it
+	// takes precedence over Config.SlowQueryThreshold.
 	SlowQueryThreshold time.Duration
-	Level              slog.Level
+
+	// Level is the minimum level logged; the zero value is Info. Successful
+	// queries are logged at Info, slow queries at Warn, failures at Error
+	// and connection events and pool statistics at Debug.
+	Level slog.Level
+
+	// SampleRate logs only 1 in SampleRate successful queries. Errors and
+	// slow queries are always logged. 0 or 1 logs every query.
+	SampleRate int
+
+	// MaxQueryLength truncates logged query text to this many bytes.
+	// 0 means no limit.
+	MaxQueryLength int
+
+	// Args selects how arguments are logged. Defaults to ArgLogCount.
+	Args ArgLogMode
+
+	// Operations selects the logged event categories. Zero means
+	// LogAllOperations.
+	Operations LogOperation
 }
 
+// ValidateLoggingConfig checks cfg for invalid settings.
+func ValidateLoggingConfig(cfg LoggingConfig) error {
+	if cfg.SlowQueryThreshold < 0 {
+		return fmt.Errorf("slow query threshold must be non-negative, got %v", cfg.SlowQueryThreshold)
+	}
+	if cfg.SampleRate < 0 {
+		return fmt.Errorf("sample rate must be non-negative, got %d", cfg.SampleRate)
+	}
+	if cfg.MaxQueryLength < 0 {
+		return fmt.Errorf("max query length must be non-negative, got %d", cfg.MaxQueryLength)
+	}
+	switch cfg.Args {
+	case "", ArgLogNone, ArgLogCount, ArgLogRedacted, ArgLogFull:
+	default:
+		return fmt.Errorf("unknown argument log mode %q", cfg.Args)
+	}
+	return nil
+}
+
+// legacyLogging is used until ConfigureLogging is called: every event is
+// passed to the logger, which does its own level filtering.
+var legacyLogging = LoggingConfig{Level: slog.LevelDebug, Args: ArgLogCount, Operations: LogAllOperations}
+
 var (
 	defaultLogger = func() *slog.Logger {
 		// Prefer ygggo_log if available via env; fall back to JSON slog
@@ -47,31 +123,182 @@ func (p *Pool) SetLogger(logger *slog.Logger) {
 	p.logger = logger
 }
 
+// ConfigureLogging applies cfg to the pool's structured logging. It
+// enables or disables logging like EnableLogging and sets the level,
+// sampling, query truncation, argument mode and logged operations:
+//
+//	pool.ConfigureLogging(ygggo_mysql.LoggingConfig{
+//		Enabled:        true,
+//		Level:          slog.LevelInfo,
+//		SampleRate:     100, // 1% of successful queries
+//		MaxQueryLength: 2048,
+//		Args:           ygggo_mysql.ArgLogRedacted,
+//		Operations:     ygggo_mysql.LogQueries | ygggo_mysql.LogExecs | ygggo_mysql.LogTransactions,
+//	})
+func (p *Pool) ConfigureLogging(cfg LoggingConfig) error {
+	if p == nil {
+		return nil
+	}
+	if err := ValidateLoggingConfig(cfg); err != nil {
+		return err
+	}
+	if cfg.Args == "" {
+		cfg.Args = ArgLogCount
+	}
+	if cfg.Operations == 0 {
+		cfg.Operations = LogAllOperations
+	}
+	if cfg.SlowQueryThreshold > 0 {
+		p.slowQueryThreshold = cfg.SlowQueryThreshold
+	}
+	p.logConfig.Store(&cfg)
+	p.EnableLogging(cfg.Enabled)
+	return nil
+}
+
+// loggingFor returns the logging settings when events of op at level are
+// logged.
+func (p *Pool) loggingFor(op LogOperation, level slog.Level) (*LoggingConfig, bool) {
+	if p == nil || !p.loggingEnabled || p.logger == nil {
+		return nil, false
+	}
+	cfg := p.logConfig.Load()
+	if cfg == nil {
+		cfg = &legacyLogging
+	}
+	if cfg.Operations&op == 0 || level < cfg.Level {
+		return nil, false
+	}
+	return cfg, true
+}
+
+// sampled reports whether a successful query is logged under cfg.SampleRate
+func (p *Pool) sampled(cfg *LoggingConfig) bool {
+	if cfg.SampleRate <= 1 {
+		return true
+	}
+	return (p.logSeq.Add(1)-1)%uint64(cfg.SampleRate) == 0
+}
+
+// truncateQuery shortens query to at most max bytes without splitting a
+// UTF-8 sequence.
+func truncateQuery(query string, max int) string {
+	if max <= 0 || len(query) <= max {
+		return query
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(query[cut]) {
+		cut--
+	}
+	return query[:cut] + "..."
+}
+
 // Note: SetSlowQueryThreshold is now defined in pool.go
 
+// observeQuery handles a statement reported by the instrumented driver
+// connections: it updates the statement statistics, logs the statement
+// when logging is enabled and records it when slow query recording is
+// enabled. Every execution path (Exec, Query, QueryRow, cached statements,
+// transactions and the managers) ends up here.
+func (p *Pool) observeQuery(ctx context.Context, ev *queryEvent) {
+	if isInternalQuery(ctx) {
+		// Housekeeping is not application traffic, but an interrupted
+		// statement is still stopped on the server
+		if ev.kill != nil && p.cfg.KillOnCancel {
+			_ = p.killInterrupted(ev)
+		}
+		return
+	}
+	if p.stmtStats != nil {
+		p.stmtStats.Record(ev.query, ev.duration, ev.rows, ev.affected, ev.err)
+	}
+	recordErr := ev.err
+	if ev.kill != nil && p.cfg.KillOnCancel {
+		recordErr = fmt.Errorf("%w (KILL QUERY %d sent)", ev.err, ev.connID)
+		if err := p.killInterrupted(ev); err != nil {
+			recordErr = fmt.Errorf("%w (KILL QUERY %d skipped: %v)", ev.err, ev.connID, err)
+		}
+	}
+	recorder := p.slowQueryRecorder
+	if !p.loggingEnabled && recorder == nil {
+		return
+	}
+	// Rows may be closed after the query's context was canceled
+	ctx = context.WithoutCancel(ctx)
+	args := ev.values()
+	if p.loggingEnabled {
+		extra := []slog.Attr{slog.Int64("rows", ev.rows)}
+		if ev.operation == "exec" {
+			extra = []slog.Attr{slog.Int64("rows_affected", ev.affected)}
+		} else if ev.rows > 0 {
+			extra = append(extra, slog.Float64("first_row_ms", float64(ev.firstRow.Nanoseconds())/1e6))
+		}
+		if ev.kill != nil {
+			extra = append(extra, slog.Bool("canceled", true), slog.Int64("connection_id", ev.connID))
+		}
+		p.logQueryAttrs(ctx, ev.operation, ev.query, args, ev.duration, ev.err, extra...)
+	}
+	if recorder == nil {
+		return
+	}
+	if ev.kill != nil {
+		// Canceled statements are recorded whatever their duration
+		recorder.capture(ctx, ev.query, args, ev.duration, ev.rows, recordErr)
+		return
+	}
+	recorder.record(ctx, ev.query, args, ev.duration, ev.rows, ev.err)
+}
+
 // logQuery logs database query execution with structured fields
 func (p *Pool) logQuery(ctx context.Context, operation, query string, args []any, duration time.Duration, err error) {
-	if p == nil || !p.loggingEnabled || p.logger == nil {
+	p.logQueryAttrs(ctx, operation, query, args, duration, err)
+}
+
+// logQueryAttrs is logQuery with additional attributes
+func (p *Pool) logQueryAttrs(ctx context.Context, operation, query string, args []any, duration time.Duration, err error, extra ...slog.Attr) {
+	op := LogQueries
+	if operation == "exec" {
+		op = LogExecs
+	}
+	slow := p != nil && p.slowQueryThreshold > 0 && duration > p.slowQueryThreshold
+	level := slog.LevelInfo
+	switch {
+	case slow:
+		level = slog.LevelWarn
+	case err != nil:
+		level = slog.LevelError
+	}
+	cfg, ok := p.loggingFor(op, level)
+	if !ok || (err == nil && !slow && !p.sampled(cfg)) {
 		return
 	}
 
+	redactor := p.getRedactor()
+
 	// Prepare log attributes
 	attrs := []slog.Attr{
 		slog.String("operation", operation),
-		slog.String("query", query),
+		slog.String("query", truncateQuery(redactor.Query(query), cfg.MaxQueryLength)),
 		slog.Float64("duration_ms", float64(duration.Nanoseconds())/1e6),
 	}
 
-	// Add arguments if present (be careful with sensitive data)
+	// Add arguments according to the argument mode
 	if len(args) > 0 {
-		attrs = append(attrs, slog.Int("arg_count", len(args)))
+		switch cfg.Args {
+		case ArgLogCount:
+			attrs = append(attrs, slog.Int("arg_count", len(args)))
+		case ArgLogRedacted:
+			attrs = append(attrs, slog.Any("args", redactor.Args(query, args)))
+		case ArgLogFull:
+			attrs = append(attrs, slog.Any("args", args))
+		}
 	}
 
 	// Add error information
 	if err != nil {
 		attrs = append(attrs,
 			slog.String("status", "error"),
-			slog.String("error", err.Error()),
+			slog.String("error", redactor.String(err.Error())),
 		)
 
 		// Add MySQL-specific error code if available
@@ -81,55 +308,25 @@ func (p *Pool) logQuery(ctx context.Context, operation, query string, args []any
 	} else {
 		attrs = append(attrs, slog.String("status", "success"))
 	}
+	if cfg.SampleRate > 1 && err == nil && !slow {
+		attrs = append(attrs, slog.Int("sample_rate", cfg.SampleRate))
+	}
+	attrs = append(attrs, extra...)
 
-	// Check for slow queries
-	if p.slowQueryThreshold > 0 && duration > p.slowQueryThreshold {
-		p.logger.LogAttrs(ctx, slog.LevelWarn, "slow query detected", attrs...)
+	if slow {
+		p.logger.LogAttrs(ctx, level, "slow query detected", attrs...)
 	} else {
-		level := slog.LevelInfo
-		if err != nil {
-			level = slog.LevelError
-		}
 		p.logger.LogAttrs(ctx, level, "database query executed", attrs...)
 	}
-
-	// Record to slow query recorder if enabled
-	if p.slowQueryRecorder != nil {
-		p.slowQueryRecorder.Record(ctx, query, args, duration, err)
-	}
-}
-
-// instrumentedExecWithLogging wraps exec execution with logging
-func (p *Pool) instrumentedExecWithLogging(ctx context.Context, conn *sql.Conn, query string, args ...any) (sql.Result, error) {
-	start := time.Now()
-	result, err := conn.ExecContext(ctx, query, args...)
-	duration := time.Since(start)
-
-	// Log the execution
-	if p.loggingEnabled {
-		p.logQuery(ctx, "exec", query, args, duration, err)
-	}
-
-	return result, err
-}
-
-// instrumentedQueryWithLogging wraps query execution with logging
-func (p *Pool) instrumentedQueryWithLogging(ctx context.Context, conn *sql.Conn, query string, args ...any) (*sql.Rows, error) {
-	start := time.Now()
-	rs, err := conn.QueryContext(ctx, query, args...)
-	duration := time.Since(start)
-
-	// Log the query
-	if p.loggingEnabled {
-		p.logQuery(ctx, "query", query, args, duration, err)
-	}
-
-	return rs, err
 }
 
 // logConnection logs database connection events
 func (p *Pool) logConnection(ctx context.Context, event string, duration time.Duration, err error) {
-	if p == nil || !p.loggingEnabled || p.logger == nil {
+	level := slog.LevelDebug
+	if err != nil {
+		level = slog.LevelError
+	}
+	if _, ok := p.loggingFor(LogConnections, level); !ok {
 		return
 	}
 
@@ -141,18 +338,21 @@ func (p *Pool) logConnection(ctx context.Context, event string, duration time.Du
 	if err != nil {
 		attrs = append(attrs,
 			slog.String("status", "error"),
-			slog.String("error", err.Error()),
+			slog.String("error", p.getRedactor().String(err.Error())),
 		)
-		p.logger.LogAttrs(ctx, slog.LevelError, "database connection event", attrs...)
 	} else {
 		attrs = append(attrs, slog.String("status", "success"))
-		p.logger.LogAttrs(ctx, slog.LevelDebug, "database connection event", attrs...)
 	}
+	p.logger.LogAttrs(ctx, level, "database connection event", attrs...)
 }
 
 // logTransaction logs database transaction events
 func (p *Pool) logTransaction(ctx context.Context, event string, duration time.Duration, err error) {
-	if p == nil || !p.loggingEnabled || p.logger == nil {
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelError
+	}
+	if _, ok := p.loggingFor(LogTransactions, level); !ok {
 		return
 	}
 
@@ -164,18 +364,17 @@ func (p *Pool) logTransaction(ctx context.Context, event string, duration time.D
 	if err != nil {
 		attrs = append(attrs,
 			slog.String("status", "error"),
-			slog.String("error", err.Error()),
+			slog.String("error", p.getRedactor().String(err.Error())),
 		)
-		p.logger.LogAttrs(ctx, slog.LevelError, "database transaction event", attrs...)
 	} else {
 		attrs = append(attrs, slog.String("status", "success"))
-		p.logger.LogAttrs(ctx, slog.LevelInfo, "database transaction event", attrs...)
 	}
+	p.logger.LogAttrs(ctx, level, "database transaction event", attrs...)
 }
 
 // logConnectionPool logs connection pool statistics
 func (p *Pool) logConnectionPool(ctx context.Context, stats PoolStats) {
-	if p == nil || !p.loggingEnabled || p.logger == nil {
+	if _, ok := p.loggingFor(LogPoolStats, slog.LevelDebug); !ok {
 		return
 	}
 
@@ -201,11 +400,12 @@ type PoolStats struct {
 
 // GetPoolStats returns current pool statistics
 func (p *Pool) GetPoolStats() PoolStats {
-	if p == nil || p.db == nil {
+	db := p.getDB()
+	if db == nil {
 		return PoolStats{}
 	}
 
-	stats := p.db.Stats()
+	stats := db.Stats()
 	return PoolStats{
 		ActiveConnections: stats.InUse,
 		IdleConnections:   stats.Idle,
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"strings"
 	"testing"
@@ -234,3 +235,165 @@ func TestLogging_DisabledLogging(t *testing.T) {
 		t.Fatalf("expected no logging when disabled, got: %s", buf.String())
 	}
 }
+
+func newConfiguredLogPool(t *testing.T, cfg LoggingConfig) (*Pool, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	pool := &Pool{logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	cfg.Enabled = true
+	if err := pool.ConfigureLogging(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return pool, &buf
+}
+
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e map[string]any
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestConfigureLogging_SamplingKeepsErrorsAndSlowQueries(t *testing.T) {
+	pool, buf := newConfiguredLogPool(t, LoggingConfig{SampleRate: 3, SlowQueryThreshold: time.Second})
+	ctx := context.Background()
+	for i := 0; i < 9; i++ {
+		pool.logQuery(ctx, "query", "SELECT 1", nil, time.Millisecond, nil)
+	}
+	pool.logQuery(ctx, "query", "SELECT 2", nil, time.Millisecond, errors.New("boom"))
+	pool.logQuery(ctx, "query", "SELECT 3", nil, 2*time.Second, nil)
+
+	var sampled, failed, slow int
+	for _, e := range logEntries(t, buf) {
+		switch e["query"] {
+		case "SELECT 1":
+			sampled++
+			if e["sample_rate"] != float64(3) {
+				t.Fatalf("sampled entry without sample_rate: %v", e)
+			}
+		case "SELECT 2":
+			failed++
+		case "SELECT 3":
+			slow++
+		}
+	}
+	if sampled != 3 || failed != 1 || slow != 1 {
+		t.Fatalf("sampled=%d failed=%d slow=%d", sampled, failed, slow)
+	}
+}
+
+func TestConfigureLogging_LevelAndOperations(t *testing.T) {
+	pool, buf := newConfiguredLogPool(t, LoggingConfig{Level: slog.LevelWarn, Operations: LogQueries | LogTransactions})
+	ctx := context.Background()
+	pool.logQuery(ctx, "query", "SELECT 1", nil, time.Millisecond, nil)
+	pool.logQuery(ctx, "query", "SELECT 2", nil, time.Millisecond, errors.New("boom"))
+	pool.logQuery(ctx, "exec", "DELETE FROM t", nil, time.Millisecond, errors.New("boom"))
+	pool.logTransaction(ctx, "rollback", time.Millisecond, errors.New("boom"))
+	pool.logConnection(ctx, "acquired", time.Millisecond, errors.New("boom"))
+
+	entries := logEntries(t, buf)
+	if len(entries) != 2 || entries[0]["query"] != "SELECT 2" || entries[1]["event"] != "rollback" {
+		t.Fatalf("entries: %v", entries)
+	}
+}
+
+func TestConfigureLogging_TruncationAndArgs(t *testing.T) {
+	query := "SELECT * FROM users WHERE password = ? AND name = ?"
+	args := []any{"hunter2", "bob"}
+	ctx := context.Background()
+
+	cases := []struct {
+		mode  ArgLogMode
+		check func(e map[string]any) bool
+	}{
+		{ArgLogNone, func(e map[string]any) bool { return e["args"] == nil && e["arg_count"] == nil }},
+		{ArgLogCount, func(e map[string]any) bool { return e["arg_count"] == float64(2) }},
+		{ArgLogRedacted, func(e map[string]any) bool {
+			a, _ := e["args"].([]any)
+			return len(a) == 2 && a[0] == "[REDACTED]" && a[1] == "[string]"
+		}},
+		{ArgLogFull, func(e map[string]any) bool {
+			a, _ := e["args"].([]any)
+			return len(a) == 2 && a[0] == "hunter2" && a[1] == "bob"
+		}},
+	}
+	for _, tc := range cases {
+		pool, buf := newConfiguredLogPool(t, LoggingConfig{Args: tc.mode, MaxQueryLength: 13})
+		pool.logQuery(ctx, "query", query, args, time.Millisecond, nil)
+		entries := logEntries(t, buf)
+		if len(entries) != 1 || !tc.check(entries[0]) {
+			t.Fatalf("%s: %v", tc.mode, entries)
+		}
+		if entries[0]["query"] != "SELECT * FROM..." {
+			t.Fatalf("query not truncated: %v", entries[0]["query"])
+		}
+	}
+
+	if err := (&Pool{}).ConfigureLogging(LoggingConfig{Args: "everything"}); err == nil {
+		t.Fatal("expected error for unknown argument mode")
+	}
+}
+
+func TestStartBackground_LoggingSlowQueryThresholdWins(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		top, logging  time.Duration
+		wantThreshold time.Duration
+	}{
+		{"logging wins", time.Second, 2 * time.Second, 2 * time.Second},
+		{"logging unset", time.Second, 0, time.Second},
+		{"top unset", 0, 2 * time.Second, 2 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Pool{cfg: Config{
+				SlowQueryThreshold: tc.top,
+				Logging:            &LoggingConfig{Enabled: true, SlowQueryThreshold: tc.logging},
+			}}
+			if err := p.startBackground(); err != nil {
+				t.Fatal(err)
+			}
+			if got := p.GetSlowQueryThreshold(); got != tc.wantThreshold {
+				t.Fatalf("threshold=%v want %v", got, tc.wantThreshold)
+			}
+		})
+	}
+}
+
+func TestTruncateQuery_KeepsUTF8(t *testing.T) {
+	if got := truncateQuery("SELECT 'héllo'", 10); got != "SELECT 'h..." {
+		t.Fatalf("got %q", got)
+	}
+	if got := truncateQuery("SELECT 1", 0); got != "SELECT 1" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestApplyEnv_Logging(t *testing.T) {
+	t.Setenv("YGGGO_MYSQL_LOGCFG_LOGGING_ENABLED", "true")
+	t.Setenv("YGGGO_MYSQL_LOGCFG_LOGGING_LEVEL", "warn")
+	t.Setenv("YGGGO_MYSQL_LOGCFG_LOGGING_SAMPLE_RATE", "10")
+	t.Setenv("YGGGO_MYSQL_LOGCFG_LOGGING_ARGS", "redacted")
+	t.Setenv("YGGGO_MYSQL_LOGCFG_LOGGING_OPERATIONS", "query,exec")
+	var cfg Config
+	if err := applyEnvPrefix(&cfg, envPrefixFor("logcfg")); err != nil {
+		t.Fatal(err)
+	}
+	l := cfg.Logging
+	if l == nil || !l.Enabled || l.Level != slog.LevelWarn || l.SampleRate != 10 || l.Args != ArgLogRedacted || l.Operations != LogQueries|LogExecs {
+		t.Fatalf("logging config: %+v", l)
+	}
+
+	t.Setenv("YGGGO_MYSQL_LOGCFG_LOGGING_OPERATIONS", "query,bogus")
+	if err := applyEnvPrefix(&cfg, envPrefixFor("logcfg")); err == nil {
+		t.Fatal("expected error for unknown operation")
+	}
+}
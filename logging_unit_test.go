@@ -1,6 +1,7 @@
 package ygggo_mysql
 
 import (
+	"context"
 	"log/slog"
 	"testing"
 )
@@ -39,3 +40,34 @@ func TestSetLoggerOverrides_Default(t *testing.T) {
 type bufSink struct{}
 
 func (*bufSink) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestYgggoBridge_LevelsGroupsAndAttrs(t *testing.T) {
+	type entry struct {
+		level slog.Level
+		msg   string
+		kvs   []any
+	}
+	var got []entry
+	h := &ygggoHandler{level: slog.LevelInfo, emit: func(level slog.Level, msg string, kvs ...any) {
+		got = append(got, entry{level, msg, kvs})
+	}}
+	lg := slog.New(h).With("pool", "main").WithGroup("db").With("host", "db-1")
+
+	lg.Debug("dropped")
+	lg.Info("query", "query", "SELECT 1", slog.Group("timing", "ms", 1.5), slog.Group("empty"))
+	if len(got) != 1 {
+		t.Fatalf("expected debug record to be dropped, got %d records", len(got))
+	}
+	want := []any{"pool=main", "db.host=db-1", `db.query="SELECT 1"`, "db.timing.ms=1.5"}
+	if len(got[0].kvs) != len(want) {
+		t.Fatalf("kvs = %v, want %v", got[0].kvs, want)
+	}
+	for i := range want {
+		if got[0].kvs[i] != want[i] {
+			t.Fatalf("kvs = %v, want %v", got[0].kvs, want)
+		}
+	}
+	if lg.Enabled(context.Background(), slog.LevelDebug) || !lg.Enabled(context.Background(), slog.LevelWarn) {
+		t.Fatal("Enabled does not honor the level")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"strings"
 	"testing"
 
 	mysql "github.com/go-sql-driver/mysql"
@@ -122,3 +123,18 @@ func TestNewPoolEnv_BuildsDSNFromEnvFields(t *testing.T) {
 		t.Fatalf("loc expected Local, got %#v", mc.Loc)
 	}
 }
+
+func TestNewPoolEnv_RejectsInvalidValues(t *testing.T) {
+	t.Setenv("YGGGO_MYSQL_DRIVER", "fake")
+	t.Setenv("YGGGO_MYSQL_DSN", "u:p@tcp(127.0.0.1:3306)/db")
+	t.Setenv("YGGGO_MYSQL_POOL_CONN_MAX_LIFETIME", "forever")
+
+	pool, err := NewPoolEnv(context.Background())
+	if err == nil {
+		pool.Close()
+		t.Fatal("expected an error for an invalid duration")
+	}
+	if !strings.Contains(err.Error(), "YGGGO_MYSQL_POOL_CONN_MAX_LIFETIME") {
+		t.Fatalf("error does not name the variable: %v", err)
+	}
+}
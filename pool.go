@@ -3,10 +3,12 @@ package ygggo_mysql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -46,29 +48,78 @@ import (
 // Pool is safe for concurrent use by multiple goroutines. All methods
 // can be called concurrently without external synchronization.
 type Pool struct {
-	// db is the underlying database connection pool
-	db *sql.DB
+	// db is the underlying database connection pool.
+	// It may be replaced during failover; read it through getDB.
+	db   *sql.DB
+	dbMu sync.RWMutex
+
+	// cfg is the effective configuration the pool was opened with
+	cfg Config
+
+	// dsn is the DSN of the current server, guarded by dbMu
+	dsn string
+
+	// closed is set by Close and Shutdown so that the handle is no longer
+	// replaced, guarded by dbMu
+	closed bool
+
+	// Multi-host failover state
+	hosts              []string            // candidate hosts (host:port), empty for single-host pools
+	hostIdx            int                 // index into hosts of the current server, guarded by dbMu
+	failoverMu         sync.Mutex          // serializes failover attempts
+	failoverHandlers   []ProbeEventHandler // receivers of failover events
+	failoverHandlersMu sync.RWMutex
+	failoverQueue      []failoverDelivery // events waiting for the handlers other than probes
+	failoverDelivering bool               // a goroutine is delivering failoverQueue
+	failoverQueueMu    sync.Mutex
 
 	// Connection leak detection
 	borrowWarnNS int64        // threshold in nanoseconds; 0 means disabled
 	borrowed     int64        // current borrowed connection count
 	leakHandler  atomic.Value // func(BorrowLeak) - callback for leak detection
 
+	// Borrowed connections and open transactions, drained by Shutdown
+	inflight inflightTracker
+
 	// Retry policy for handling transient failures
 	retry RetryPolicy
 
 	// Observability features (simplified)
 
 	// Logging configuration
-	loggingEnabled     bool          // Structured logging enabled
-	logger             *slog.Logger  // Logger instance
-	slowQueryThreshold time.Duration // Threshold for slow query logging
+	loggingEnabled     bool                          // Structured logging enabled
+	logger             *slog.Logger                  // Logger instance
+	slowQueryThreshold time.Duration                 // Threshold for slow query logging
+	logConfig          atomic.Pointer[LoggingConfig] // Set by ConfigureLogging
+	logSeq             atomic.Uint64                 // Successful queries seen, for sampling
 
 	// Slow query analysis
 	slowQueryRecorder *SlowQueryRecorder // Records and analyzes slow queries
 
 	// Health monitoring
-	healthMonitor *HealthMonitor // Monitors pool and connection health
+	healthMonitor    *HealthMonitor      // Monitors pool and connection health
+	probe            *ConnectionProbe    // Started from Config.Probe, if set
+	serverChecks     []ServerHealthCheck // Default server checks, kept for their rate samples
+	serverChecksOnce sync.Once
+
+	// Redaction applied to logs, slow query records and events; nil means default
+	redactor atomic.Pointer[Redactor]
+
+	// Query guard (nil when disabled) and maintenance mode for DDL
+	guard       atomic.Pointer[Guard]
+	maintenance atomic.Bool
+
+	// Statement statistics from Config.StatementStats (nil when disabled)
+	stmtStats *StatementStatsCollector
+
+	// Rate limit and in-flight kills of Config.KillOnCancel
+	killLimiter *tokenBucket
+	killWG      sync.WaitGroup
+
+	// Deadlock capture (see Config.CaptureDeadlocks)
+	deadlockMu   sync.Mutex
+	lastDeadlock string         // raw text of the last captured report
+	deadlockWG   sync.WaitGroup // in-flight captures
 }
 
 // SetBorrowWarnThreshold sets the warning threshold for connection hold time.
@@ -183,7 +234,7 @@ func (p *Pool) onReturn() {
 //   - YGGGO_MYSQL_DATABASE=mydb
 //
 // The function performs the following steps:
-//  1. Apply environment variable overrides to config
+//  1. Apply environment variable overrides to config (invalid values are an error)
 //  2. Set default driver to "mysql" if not specified
 //  3. Build DSN from config (either raw DSN or constructed from fields)
 //  4. Open database connection with specified driver
@@ -192,10 +243,31 @@ func (p *Pool) onReturn() {
 //  7. Return configured pool or cleanup and return error
 func NewPool(ctx context.Context, cfg Config) (*Pool, error) {
 	// Apply env overrides first (convention over configuration)
-	applyEnv(&cfg)
+	if err := applyEnv(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid environment configuration: %w", err)
+	}
+	return newPool(ctx, cfg)
+}
+
+// newPool opens a pool for cfg without applying environment overrides.
+// Credentials are redacted from the returned error.
+func newPool(ctx context.Context, cfg Config) (*Pool, error) {
+	p, err := openPool(ctx, cfg)
+	if err != nil {
+		return nil, redactError(err)
+	}
+	return p, nil
+}
+
+// openPool connects to the server(s) described by cfg and starts the
+// background work it requests.
+func openPool(ctx context.Context, cfg Config) (*Pool, error) {
 	if cfg.Driver == "" {
 		cfg.Driver = "mysql"
 	}
+	if len(cfg.Hosts) > 0 {
+		return newFailoverPool(ctx, cfg)
+	}
 	// Build DSN from config (supports raw DSN or field-based build)
 	dsn, err := dsnFromConfig(cfg)
 	if err != nil {
@@ -209,52 +281,199 @@ func NewPool(ctx context.Context, cfg Config) (*Pool, error) {
 
 	// Record last used DSN for diagnostics
 	lastUsedDSN.Store(dsn)
-	// Open DB
-	db, err := sql.Open(cfg.Driver, dsn)
+	p := &Pool{cfg: cfg, dsn: dsn, stmtStats: newStatementStats(cfg)}
+	db, err := openDB(ctx, cfg, dsn, p)
 	if err != nil {
 		return nil, err
 	}
-	p := &Pool{db: db}
+	p.db = db
 	// Apply retry policy from config
 	p.retry = cfg.Retry
-	// Apply pool settings (placeholders)
-	if cfg.Pool.MaxOpen > 0 {
-		db.SetMaxOpenConns(cfg.Pool.MaxOpen)
+	if err := p.startBackground(); err != nil {
+		_ = p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// startBackground applies the redaction settings and starts the slow query
+// recording, health monitoring and connection probing requested by the
+// pool's Config.
+func (p *Pool) startBackground() error {
+	if p.cfg.Redaction != nil {
+		if err := p.SetRedaction(*p.cfg.Redaction); err != nil {
+			return err
+		}
+	}
+	p.SetGuard(p.cfg.Guard)
+	if p.cfg.KillOnCancel {
+		rate := p.cfg.KillOnCancelRate
+		if rate <= 0 {
+			rate = defaultKillOnCancelRate
+		}
+		p.killLimiter = newTokenBucket(rate)
+	}
+	if p.cfg.SlowQueryThreshold > 0 {
+		p.slowQueryThreshold = p.cfg.SlowQueryThreshold
 	}
-	if cfg.Pool.MaxIdle > 0 {
-		db.SetMaxIdleConns(cfg.Pool.MaxIdle)
+	// Logging.SlowQueryThreshold, when set, takes precedence.
+	if p.cfg.Logging != nil {
+		if err := p.ConfigureLogging(*p.cfg.Logging); err != nil {
+			return fmt.Errorf("invalid logging configuration: %w", err)
+		}
 	}
-	if cfg.Pool.ConnMaxLifetime > 0 {
-		db.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	if p.cfg.Health != nil && p.cfg.Health.MonitoringEnabled {
+		if err := p.StartHealthMonitoringWithConfig(*p.cfg.Health); err != nil {
+			return fmt.Errorf("failed to start health monitoring: %w", err)
+		}
 	}
-	if cfg.Pool.ConnMaxIdleTime > 0 {
-		db.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+	if p.cfg.Probe != nil {
+		if err := ValidateProbeConfig(*p.cfg.Probe); err != nil {
+			return fmt.Errorf("invalid probe configuration: %w", err)
+		}
+		p.probe = NewConnectionProbe(p, *p.cfg.Probe)
+		if err := p.probe.Start(); err != nil {
+			return fmt.Errorf("failed to start connection probe: %w", err)
+		}
 	}
-	// Try ping to validate connectivity
-	if err := db.Ping(); err != nil {
+	return nil
+}
+
+// Probe returns the ConnectionProbe started from Config.Probe, or nil.
+func (p *Pool) Probe() *ConnectionProbe {
+	if p == nil {
+		return nil
+	}
+	return p.probe
+}
+
+// newStatementStats returns the collector requested by cfg.StatementStats,
+// or nil.
+func newStatementStats(cfg Config) *StatementStatsCollector {
+	if cfg.StatementStats == nil {
+		return nil
+	}
+	return NewStatementStatsCollector(*cfg.StatementStats)
+}
+
+// openDB opens a *sql.DB for dsn, applies the pool settings from cfg and
+// validates connectivity. Every statement run on the handle is reported to
+// obs when it is not nil. The handle is closed again if the ping fails.
+func openDB(ctx context.Context, cfg Config, dsn string, obs queryObserver) (*sql.DB, error) {
+	var connector driver.Connector
+	if cfg.Credentials != nil {
+		if cfg.Driver != "mysql" {
+			return nil, fmt.Errorf("credential providers require the mysql driver, got %q", cfg.Driver)
+		}
+		var err error
+		connector, err = newCredentialConnector(dsn, cfg.Credentials)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.SessionInit) > 0 || cfg.ResetSession {
+		if connector == nil {
+			var err error
+			connector, err = driverConnector(cfg.Driver, dsn)
+			if err != nil {
+				return nil, err
+			}
+		}
+		connector = newSessionConnector(connector, cfg.SessionInit, cfg.ResetSession)
+	}
+	if obs != nil {
+		if connector == nil {
+			var err error
+			connector, err = driverConnector(cfg.Driver, dsn)
+			if err != nil {
+				return nil, err
+			}
+		}
+		connector = newInstrumentedConnector(connector, obs, cfg.KillOnCancel)
+	}
+
+	var db *sql.DB
+	if connector != nil {
+		db = sql.OpenDB(connector)
+	} else {
+		var err error
+		db, err = sql.Open(cfg.Driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	applyPoolConfig(db, cfg.Pool)
+	if err := db.PingContext(ctx); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
-	return p, nil
+	return db, nil
+}
+
+// applyPoolConfig applies the non-zero pool limits to db.
+func applyPoolConfig(db *sql.DB, pc PoolConfig) {
+	if pc.MaxOpen > 0 {
+		db.SetMaxOpenConns(pc.MaxOpen)
+	}
+	if pc.MaxIdle > 0 {
+		db.SetMaxIdleConns(pc.MaxIdle)
+	}
+	if pc.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(pc.ConnMaxLifetime)
+	}
+	if pc.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(pc.ConnMaxIdleTime)
+	}
+}
+
+// getDB returns the current underlying *sql.DB.
+func (p *Pool) getDB() *sql.DB {
+	if p == nil {
+		return nil
+	}
+	p.dbMu.RLock()
+	defer p.dbMu.RUnlock()
+	return p.db
+}
+
+// markClosed marks the pool as closed and returns its current handle.
+func (p *Pool) markClosed() *sql.DB {
+	if p == nil {
+		return nil
+	}
+	p.dbMu.Lock()
+	defer p.dbMu.Unlock()
+	p.closed = true
+	return p.db
 }
 
 // Close closes the pool and all its connections.
 func (p *Pool) Close() error {
-	if p == nil || p.db == nil {
+	db := p.markClosed()
+	if db == nil {
 		return nil
 	}
+	if p.probe != nil && p.probe.IsRunning() {
+		_ = p.probe.Stop()
+	}
+	if p.IsHealthMonitoringRunning() {
+		_ = p.StopHealthMonitoring()
+	}
+	p.killWG.Wait()
+	p.deadlockWG.Wait()
 	if p.slowQueryRecorder != nil {
 		p.slowQueryRecorder.Close()
 	}
-	return p.db.Close()
+	return db.Close()
 }
 
 // Ping checks connectivity (placeholder).
 func (p *Pool) Ping(ctx context.Context) error {
-	if p == nil || p.db == nil {
+	db := p.getDB()
+	if db == nil {
 		return errors.New("nil pool")
 	}
-	return p.db.PingContext(ctx)
+	return db.PingContext(ctx)
 }
 
 // SelfCheck performs a basic health check (placeholder).
@@ -268,6 +487,8 @@ func (p *Pool) setRetryPolicy(r RetryPolicy) { p.retry = r }
 // EnableSlowQueryRecording enables slow query recording with the given configuration
 func (p *Pool) EnableSlowQueryRecording(config SlowQueryConfig, storage SlowQueryStorage) {
 	p.slowQueryRecorder = NewSlowQueryRecorder(config, storage)
+	p.slowQueryRecorder.SetRedactor(p.getRedactor())
+	p.slowQueryRecorder.SetExplainer(p.Explain)
 }
 
 // DisableSlowQueryRecording disables slow query recording
@@ -307,6 +528,9 @@ var lastUsedDSN atomic.Value // stores string
 
 // GetDSN returns the last Data Source Name used to initialize a pool in this process.
 // Returns empty string if none has been set yet.
+//
+// Deprecated: with several pools the value belongs to whichever pool was
+// opened last. Use Pool.DSN instead.
 func GetDSN() string {
 	if v, ok := lastUsedDSN.Load().(string); ok {
 		return v
@@ -314,6 +538,17 @@ func GetDSN() string {
 	return ""
 }
 
+// DSN returns the Data Source Name this pool is currently connected with.
+// For multi-host pools it reflects the host selected by the last failover.
+func (p *Pool) DSN() string {
+	if p == nil {
+		return ""
+	}
+	p.dbMu.RLock()
+	defer p.dbMu.RUnlock()
+	return p.dsn
+}
+
 // ensureDatabaseExists checks if the target database exists and creates it if it doesn't.
 // It returns the original DSN if successful, or an error if the operation fails.
 func ensureDatabaseExists(ctx context.Context, cfg Config, targetDSN string) error {
@@ -327,6 +562,13 @@ func ensureDatabaseExists(ctx context.Context, cfg Config, targetDSN string) err
 	if err != nil {
 		return fmt.Errorf("failed to parse DSN: %w", err)
 	}
+	if cfg.Credentials != nil {
+		creds, err := cfg.Credentials.Credentials(ctx)
+		if err != nil {
+			return fmt.Errorf("credential provider: %w", err)
+		}
+		applyCredentials(parsedDSN, creds)
+	}
 
 	// Create a DSN without database name to connect to MySQL server
 	serverDSN := fmt.Sprintf("%s:%s@tcp(%s)/", parsedDSN.User, parsedDSN.Passwd, parsedDSN.Addr)
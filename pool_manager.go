@@ -185,11 +185,11 @@ func (pm *PoolManager) UpdateConfig(config PoolConfig) error {
 	defer pm.mutex.Unlock()
 	
 	// Apply configuration to the underlying database
-	if pm.pool != nil && pm.pool.db != nil {
-		pm.pool.db.SetMaxOpenConns(config.MaxOpen)
-		pm.pool.db.SetMaxIdleConns(config.MaxIdle)
-		pm.pool.db.SetConnMaxLifetime(config.ConnMaxLifetime)
-		pm.pool.db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	if pm.pool != nil && pm.pool.getDB() != nil {
+		pm.pool.getDB().SetMaxOpenConns(config.MaxOpen)
+		pm.pool.getDB().SetMaxIdleConns(config.MaxIdle)
+		pm.pool.getDB().SetConnMaxLifetime(config.ConnMaxLifetime)
+		pm.pool.getDB().SetConnMaxIdleTime(config.ConnMaxIdleTime)
 	}
 	
 	// Update internal configuration
@@ -203,11 +203,11 @@ func (pm *PoolManager) Stats() DetailedPoolStats {
 	pm.mutex.RLock()
 	defer pm.mutex.RUnlock()
 	
-	if pm.pool == nil || pm.pool.db == nil {
+	if pm.pool == nil || pm.pool.getDB() == nil {
 		return DetailedPoolStats{}
 	}
 
-	dbStats := pm.pool.db.Stats()
+	dbStats := pm.pool.getDB().Stats()
 
 	stats := DetailedPoolStats{
 		OpenConnections:   dbStats.OpenConnections,
@@ -323,8 +323,8 @@ func (pm *PoolManager) ScaleUp(additionalConnections int) error {
 
 	newMaxOpen := pm.config.MaxOpen + additionalConnections
 
-	if pm.pool != nil && pm.pool.db != nil {
-		pm.pool.db.SetMaxOpenConns(newMaxOpen)
+	if pm.pool != nil && pm.pool.getDB() != nil {
+		pm.pool.getDB().SetMaxOpenConns(newMaxOpen)
 		pm.config.MaxOpen = newMaxOpen
 	}
 
@@ -351,9 +351,9 @@ func (pm *PoolManager) ScaleDown(connectionsToRemove int) error {
 		newMaxIdle = newMaxOpen
 	}
 
-	if pm.pool != nil && pm.pool.db != nil {
-		pm.pool.db.SetMaxOpenConns(newMaxOpen)
-		pm.pool.db.SetMaxIdleConns(newMaxIdle)
+	if pm.pool != nil && pm.pool.getDB() != nil {
+		pm.pool.getDB().SetMaxOpenConns(newMaxOpen)
+		pm.pool.getDB().SetMaxIdleConns(newMaxIdle)
 		pm.config.MaxOpen = newMaxOpen
 		pm.config.MaxIdle = newMaxIdle
 	}
@@ -376,9 +376,9 @@ func (pm *PoolManager) Resize(newMaxOpen, newMaxIdle int) error {
 	pm.mutex.Lock()
 	defer pm.mutex.Unlock()
 
-	if pm.pool != nil && pm.pool.db != nil {
-		pm.pool.db.SetMaxOpenConns(newMaxOpen)
-		pm.pool.db.SetMaxIdleConns(newMaxIdle)
+	if pm.pool != nil && pm.pool.getDB() != nil {
+		pm.pool.getDB().SetMaxOpenConns(newMaxOpen)
+		pm.pool.getDB().SetMaxIdleConns(newMaxIdle)
 		pm.config.MaxOpen = newMaxOpen
 		pm.config.MaxIdle = newMaxIdle
 	}
@@ -388,7 +388,7 @@ func (pm *PoolManager) Resize(newMaxOpen, newMaxIdle int) error {
 
 // DrainConnections gracefully closes idle connections
 func (pm *PoolManager) DrainConnections(ctx context.Context) error {
-	if pm.pool == nil || pm.pool.db == nil {
+	if pm.pool == nil || pm.pool.getDB() == nil {
 		return fmt.Errorf("pool is not initialized")
 	}
 
@@ -397,20 +397,20 @@ func (pm *PoolManager) DrainConnections(ctx context.Context) error {
 	pm.mutex.Unlock()
 
 	// Set MaxIdle to 0 to prevent new idle connections
-	pm.pool.db.SetMaxIdleConns(0)
+	pm.pool.getDB().SetMaxIdleConns(0)
 
 	// Wait a bit for connections to be released
 	select {
 	case <-ctx.Done():
 		// Restore original MaxIdle if context was cancelled
-		pm.pool.db.SetMaxIdleConns(originalMaxIdle)
+		pm.pool.getDB().SetMaxIdleConns(originalMaxIdle)
 		return ctx.Err()
 	case <-time.After(100 * time.Millisecond):
 		// Continue with drain
 	}
 
 	// Restore original MaxIdle
-	pm.pool.db.SetMaxIdleConns(originalMaxIdle)
+	pm.pool.getDB().SetMaxIdleConns(originalMaxIdle)
 
 	return nil
 }
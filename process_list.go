@@ -0,0 +1,367 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// erNoSuchThread is returned by KILL for a thread that has exited.
+const erNoSuchThread = 1094
+
+// ProcessInfo is a row of the server process list.
+type ProcessInfo struct {
+	ID      int64         `json:"id"`      // Connection ID, as used by KILL
+	User    string        `json:"user"`    // Account running the thread
+	Host    string        `json:"host"`    // Client host and port
+	DB      string        `json:"db"`      // Default database, if any
+	Command string        `json:"command"` // Query, Execute, Sleep, ...
+	Time    time.Duration `json:"time"`    // Time in the current state, in whole seconds
+	State   string        `json:"state"`   // What the thread is doing
+	Info    string        `json:"info"`    // The statement being run, if any
+}
+
+const (
+	processListFromThreads = "SELECT PROCESSLIST_ID, PROCESSLIST_USER, PROCESSLIST_HOST, PROCESSLIST_DB, PROCESSLIST_COMMAND, PROCESSLIST_TIME, PROCESSLIST_STATE, PROCESSLIST_INFO FROM performance_schema.threads WHERE PROCESSLIST_ID IS NOT NULL"
+	processListFromSchema  = "SELECT ID, USER, HOST, DB, COMMAND, TIME, STATE, INFO FROM information_schema.PROCESSLIST"
+)
+
+// ProcessList returns the threads of the server, read from
+// performance_schema.threads, which does not block the server, or from
+// information_schema.PROCESSLIST when the performance schema is disabled.
+// Threads of other users are only visible with the PROCESS privilege.
+func (p *Pool) ProcessList(ctx context.Context) ([]ProcessInfo, error) {
+	db := p.getDB()
+	if db == nil {
+		return nil, fmt.Errorf("pool or database is nil")
+	}
+	ctx = internalContext(ctx)
+	// The list contains at least our own connection, so an empty result
+	// means the performance schema is not collecting threads
+	if list, err := queryProcessList(ctx, db, processListFromThreads); err == nil && len(list) > 0 {
+		return list, nil
+	}
+	list, err := queryProcessList(ctx, db, processListFromSchema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read process list: %w", err)
+	}
+	return list, nil
+}
+
+func queryProcessList(ctx context.Context, db *sql.DB, query string) ([]ProcessInfo, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []ProcessInfo
+	for rows.Next() {
+		var pi ProcessInfo
+		var user, host, dbName, command, state, info sql.NullString
+		var secs sql.NullInt64
+		if err := rows.Scan(&pi.ID, &user, &host, &dbName, &command, &secs, &state, &info); err != nil {
+			return nil, err
+		}
+		pi.User, pi.Host, pi.DB, pi.Command = user.String, host.String, dbName.String, command.String
+		pi.State, pi.Info = state.String, info.String
+		pi.Time = time.Duration(secs.Int64) * time.Second
+		list = append(list, pi)
+	}
+	return list, rows.Err()
+}
+
+// KillRule limits the run time of matching statements. Empty fields match
+// everything.
+type KillRule struct {
+	Name    string         `json:"name"`
+	User    string         `json:"user"`     // Account the statement runs as
+	Pattern *regexp.Regexp `json:"-"`        // Matched against the statement text
+	MaxTime time.Duration  `json:"max_time"` // Statements running longer are killed
+}
+
+// matches reports whether the rule applies to pi.
+func (r KillRule) matches(pi ProcessInfo) bool {
+	if r.User != "" && r.User != pi.User {
+		return false
+	}
+	return r.Pattern == nil || r.Pattern.MatchString(pi.Info)
+}
+
+// QueryKillerConfig configures a QueryKiller.
+type QueryKillerConfig struct {
+	Interval time.Duration `json:"interval"` // Time between process list scans; defaults to 10s
+
+	// Rules are evaluated in order; the first matching rule sets the limit.
+	// Statements matching no rule are limited by DefaultMaxTime, if set.
+	Rules          []KillRule    `json:"rules"`
+	DefaultMaxTime time.Duration `json:"default_max_time"`
+
+	// IgnoreUsers are never killed. Defaults to the server's own
+	// "system user" and "event_scheduler" threads.
+	IgnoreUsers []string `json:"ignore_users"`
+
+	// DryRun reports the statements that would be killed without killing
+	// them. Each statement is reported once, however many scans see it.
+	DryRun bool `json:"dry_run"`
+
+	// Storage receives a slow query record for every kill. Defaults to the
+	// storage of the pool's slow query recorder.
+	Storage SlowQueryStorage `json:"-"`
+}
+
+// QueryKillEvent reports a statement killed, or selected for killing in
+// dry-run mode, by a QueryKiller.
+type QueryKillEvent struct {
+	Process   ProcessInfo   `json:"process"`
+	Rule      string        `json:"rule"`  // Name of the matching rule; empty for DefaultMaxTime
+	Limit     time.Duration `json:"limit"` // The limit the statement exceeded
+	DryRun    bool          `json:"dry_run"`
+	Killed    bool          `json:"killed"`
+	Error     error         `json:"-"` // Set when KILL QUERY failed
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// QueryKillHandler receives QueryKiller events.
+type QueryKillHandler interface {
+	HandleQueryKill(event QueryKillEvent)
+}
+
+// QueryKillHandlerFunc adapts a callback to a QueryKillHandler.
+type QueryKillHandlerFunc func(event QueryKillEvent)
+
+// HandleQueryKill calls f(event).
+func (f QueryKillHandlerFunc) HandleQueryKill(event QueryKillEvent) { f(event) }
+
+// QueryKiller periodically scans the process list and runs KILL QUERY on
+// statements running longer than their limit. The connections stay open;
+// their clients receive "Query execution was interrupted".
+type QueryKiller struct {
+	pool   *Pool
+	config QueryKillerConfig
+	ignore map[string]bool
+
+	handlers []QueryKillHandler
+	mutex    sync.RWMutex
+
+	// Statements reported in dry-run mode by the last scan
+	reported   map[reportedStatement]bool
+	reportedMu sync.Mutex
+
+	stopChan     chan struct{}
+	done         chan struct{}
+	running      bool
+	runningMutex sync.Mutex
+}
+
+// NewQueryKiller creates a killer for pool. Call Start to begin scanning,
+// or Check to scan once.
+func NewQueryKiller(pool *Pool, config QueryKillerConfig) *QueryKiller {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.IgnoreUsers == nil {
+		config.IgnoreUsers = []string{"system user", "event_scheduler"}
+	}
+	k := &QueryKiller{pool: pool, config: config, ignore: make(map[string]bool)}
+	for _, u := range config.IgnoreUsers {
+		k.ignore[u] = true
+	}
+	return k
+}
+
+// AddEventHandler adds a handler notified of every kill.
+func (k *QueryKiller) AddEventHandler(handler QueryKillHandler) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.handlers = append(k.handlers, handler)
+}
+
+// Start begins scanning every Interval.
+func (k *QueryKiller) Start() error {
+	k.runningMutex.Lock()
+	defer k.runningMutex.Unlock()
+	if k.running {
+		return fmt.Errorf("query killer is already running")
+	}
+	k.stopChan = make(chan struct{})
+	k.done = make(chan struct{})
+	k.running = true
+	go k.loop(k.stopChan, k.done)
+	return nil
+}
+
+// Stop stops scanning and waits for an in-flight scan to finish.
+func (k *QueryKiller) Stop() error {
+	k.runningMutex.Lock()
+	defer k.runningMutex.Unlock()
+	if !k.running {
+		return fmt.Errorf("query killer is not running")
+	}
+	close(k.stopChan)
+	<-k.done
+	k.running = false
+	return nil
+}
+
+// IsRunning returns whether the killer is scanning.
+func (k *QueryKiller) IsRunning() bool {
+	k.runningMutex.Lock()
+	defer k.runningMutex.Unlock()
+	return k.running
+}
+
+func (k *QueryKiller) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(k.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), k.config.Interval)
+			_, _ = k.Check(ctx)
+			cancel()
+		}
+	}
+}
+
+// limitFor returns the limit applying to pi and the name of its rule, or
+// false when pi is not limited.
+func (k *QueryKiller) limitFor(pi ProcessInfo) (time.Duration, string, bool) {
+	if pi.Info == "" || (pi.Command != "Query" && pi.Command != "Execute") || k.ignore[pi.User] {
+		return 0, "", false
+	}
+	for _, r := range k.config.Rules {
+		if r.matches(pi) {
+			return r.MaxTime, r.Name, r.MaxTime > 0
+		}
+	}
+	return k.config.DefaultMaxTime, "", k.config.DefaultMaxTime > 0
+}
+
+// reportedStatement identifies a statement reported in dry-run mode.
+type reportedStatement struct {
+	id   int64
+	info string
+}
+
+// Check scans the process list once, kills the statements over their
+// limit and returns the resulting events. In dry-run mode, statements
+// already reported by the previous scan are skipped.
+func (k *QueryKiller) Check(ctx context.Context) ([]QueryKillEvent, error) {
+	list, err := k.pool.ProcessList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	k.reportedMu.Lock()
+	defer k.reportedMu.Unlock()
+	// Only statements still over their limit are remembered, so the map
+	// stays as small as the process list
+	reported := make(map[reportedStatement]bool)
+	var events []QueryKillEvent
+	for _, pi := range list {
+		limit, rule, ok := k.limitFor(pi)
+		if !ok || pi.Time <= limit {
+			continue
+		}
+		if k.config.DryRun {
+			key := reportedStatement{id: pi.ID, info: pi.Info}
+			reported[key] = true
+			if k.reported[key] {
+				continue
+			}
+		}
+		event := QueryKillEvent{Process: pi, Rule: rule, Limit: limit, DryRun: k.config.DryRun, Timestamp: time.Now()}
+		if !k.config.DryRun {
+			event.Error = k.kill(ctx, pi.ID)
+			event.Killed = event.Error == nil
+			if errors.Is(event.Error, errThreadGone) {
+				// The statement finished in the meantime
+				continue
+			}
+		}
+		k.report(ctx, event)
+		events = append(events, event)
+	}
+	k.reported = reported
+	return events, nil
+}
+
+// errThreadGone is returned by kill when the thread has exited.
+var errThreadGone = errors.New("thread no longer exists")
+
+// kill runs KILL QUERY for the connection id.
+func (k *QueryKiller) kill(ctx context.Context, id int64) error {
+	db := k.pool.getDB()
+	if db == nil {
+		return fmt.Errorf("pool or database is nil")
+	}
+	ctx = internalContext(ctx)
+	_, err := db.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", id))
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == erNoSuchThread {
+		return errThreadGone
+	}
+	return err
+}
+
+// report notifies the handlers and stores a slow query record for event.
+func (k *QueryKiller) report(ctx context.Context, event QueryKillEvent) {
+	k.mutex.RLock()
+	handlers := append([]QueryKillHandler(nil), k.handlers...)
+	k.mutex.RUnlock()
+	for _, h := range handlers {
+		h.HandleQueryKill(event)
+	}
+
+	recorder := k.pool.slowQueryRecorder
+	if k.config.Storage == nil && recorder == nil {
+		return
+	}
+	mode := NormalizeBasic
+	if recorder != nil {
+		mode = recorder.GetConfig().NormalizationMode
+	}
+	redactor := k.pool.getRedactor()
+	pi := event.Process
+	record := &SlowQueryRecord{
+		ID:              generateID(),
+		Query:           redactor.Query(pi.Info),
+		NormalizedQuery: FingerprintQuery(pi.Info, mode),
+		Duration:        pi.Time,
+		Timestamp:       event.Timestamp,
+		Database:        pi.DB,
+		User:            pi.User,
+		Host:            pi.Host,
+		Error:           killMessage(event),
+	}
+	record.PatternID = QueryDigest(record.NormalizedQuery)
+	if k.config.Storage != nil {
+		_ = k.config.Storage.Store(ctx, record)
+		return
+	}
+	_ = recorder.store(ctx, record)
+}
+
+// killMessage describes event for slow query records.
+func killMessage(event QueryKillEvent) string {
+	rule := "default limit"
+	if event.Rule != "" {
+		rule = "rule " + event.Rule
+	}
+	switch {
+	case event.DryRun:
+		return fmt.Sprintf("would be killed by query killer (%s, limit %v, dry run)", rule, event.Limit)
+	case event.Error != nil:
+		return fmt.Sprintf("query killer failed to kill (%s, limit %v): %v", rule, event.Limit, event.Error)
+	default:
+		return fmt.Sprintf("killed by query killer (%s, limit %v)", rule, event.Limit)
+	}
+}
@@ -0,0 +1,197 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// setProcessList answers process list queries with rows of id, user,
+// command, seconds and statement.
+func setProcessList(s *fakeServer, prefix string, rows ...[]driver.Value) {
+	res := serverResult{cols: []string{"ID", "USER", "HOST", "DB", "COMMAND", "TIME", "STATE", "INFO"}}
+	for _, r := range rows {
+		res.rows = append(res.rows, []driver.Value{r[0], r[1], "10.0.0.5:5123", "shop", r[2], r[3], "executing", r[4]})
+	}
+	s.set(prefix, res)
+}
+
+func processListServer() *fakeServer {
+	s := newFakeServer()
+	setProcessList(s, "SELECT PROCESSLIST_ID",
+		[]driver.Value{int64(1), "app", "Query", int64(120), "SELECT * FROM orders"},
+		[]driver.Value{int64(2), "report", "Query", int64(120), "SELECT * FROM sales"},
+		[]driver.Value{int64(3), "app", "Sleep", int64(900), nil},
+		[]driver.Value{int64(4), "app", "Query", int64(5), "UPDATE stock SET qty = 0"},
+		[]driver.Value{int64(5), "system user", "Query", int64(9000), "ALTER TABLE t"},
+		[]driver.Value{int64(6), "app", "Query", int64(40), "SELECT SLEEP(40)"},
+	)
+	s.set("KILL QUERY", serverResult{})
+	return s
+}
+
+func killedIDs(s *fakeServer) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, q := range s.queries {
+		if id, ok := strings.CutPrefix(q, "KILL QUERY "); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestProcessList(t *testing.T) {
+	s := processListServer()
+	list, err := s.pool(t).ProcessList(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 6 {
+		t.Fatalf("expected 6 threads, got %d", len(list))
+	}
+	pi := list[0]
+	if pi.ID != 1 || pi.User != "app" || pi.DB != "shop" || pi.Command != "Query" || pi.Time != 2*time.Minute || pi.Info != "SELECT * FROM orders" {
+		t.Fatalf("process: %+v", pi)
+	}
+	if list[2].Info != "" {
+		t.Fatalf("idle thread: %+v", list[2])
+	}
+
+	// Without the performance schema the information schema is read
+	s.set("SELECT PROCESSLIST_ID", serverResult{err: &mysql.MySQLError{Number: 1142, Message: "SELECT command denied"}})
+	setProcessList(s, "SELECT ID", []driver.Value{int64(9), "app", "Query", int64(1), "SELECT 1"})
+	list, err = s.pool(t).ProcessList(context.Background())
+	if err != nil || len(list) != 1 || list[0].ID != 9 {
+		t.Fatalf("fallback: %+v, %v", list, err)
+	}
+}
+
+func TestQueryKiller_KillsByRule(t *testing.T) {
+	s := processListServer()
+	p := s.pool(t)
+	storage := NewMemorySlowQueryStorage(10)
+	killer := NewQueryKiller(p, QueryKillerConfig{
+		Rules: []KillRule{
+			{Name: "reports", User: "report", MaxTime: time.Hour},
+			{Name: "sleep", Pattern: regexp.MustCompile(`(?i)sleep\(`), MaxTime: 30 * time.Second},
+		},
+		DefaultMaxTime: time.Minute,
+		Storage:        storage,
+	})
+	var mu sync.Mutex
+	var handled []QueryKillEvent
+	killer.AddEventHandler(QueryKillHandlerFunc(func(e QueryKillEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e)
+	}))
+
+	events, err := killer.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(killedIDs(s), ","); got != "1,6" {
+		t.Fatalf("killed %s", got)
+	}
+	if len(events) != 2 || len(handled) != 2 || !events[0].Killed || events[0].Rule != "" || events[1].Rule != "sleep" || events[1].Limit != 30*time.Second {
+		t.Fatalf("events: %+v", events)
+	}
+
+	records, err := storage.GetRecords(context.Background(), SlowQueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("records: %+v", records)
+	}
+	for _, r := range records {
+		if r.User != "app" || r.Database != "shop" || !strings.HasPrefix(r.Error, "killed by query killer") || r.PatternID == "" {
+			t.Fatalf("record: %+v", r)
+		}
+	}
+}
+
+func TestQueryKiller_DryRun(t *testing.T) {
+	s := processListServer()
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	p := s.pool(t)
+	p.slowQueryRecorder = NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(10))
+	defer p.slowQueryRecorder.Close()
+
+	killer := NewQueryKiller(p, QueryKillerConfig{DefaultMaxTime: time.Minute, DryRun: true})
+	events, err := killer.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Killed || !events[0].DryRun || len(killedIDs(s)) != 0 {
+		t.Fatalf("dry run events: %+v, kills %v", events, killedIDs(s))
+	}
+	records, _ := p.slowQueryRecorder.GetRecords(context.Background(), SlowQueryFilter{})
+	if len(records) != 2 || !strings.Contains(records[0].Error, "dry run") {
+		t.Fatalf("records: %+v", records)
+	}
+
+	// Statements are reported once; a new statement on the thread is
+	// reported again
+	if events, _ := killer.Check(context.Background()); len(events) != 0 {
+		t.Fatalf("reported again: %+v", events)
+	}
+	setProcessList(s, "SELECT PROCESSLIST_ID",
+		[]driver.Value{int64(1), "app", "Query", int64(90), "SELECT * FROM customers"},
+		[]driver.Value{int64(2), "report", "Query", int64(180), "SELECT * FROM sales"},
+	)
+	events, _ = killer.Check(context.Background())
+	if len(events) != 1 || events[0].Process.Info != "SELECT * FROM customers" {
+		t.Fatalf("events: %+v", events)
+	}
+}
+
+func TestQueryKiller_KillFailures(t *testing.T) {
+	s := processListServer()
+	s.set("KILL QUERY 1", serverResult{err: &mysql.MySQLError{Number: 1094, Message: "Unknown thread id: 1"}})
+	s.set("KILL QUERY 6", serverResult{err: errors.New("access denied")})
+	killer := NewQueryKiller(s.pool(t), QueryKillerConfig{DefaultMaxTime: 30 * time.Second})
+
+	events, err := killer.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Thread 1 finished before the kill and is not reported
+	if len(events) != 2 || events[0].Process.ID != 2 || !events[0].Killed {
+		t.Fatalf("events: %+v", events)
+	}
+	if events[1].Process.ID != 6 || events[1].Killed || events[1].Error == nil {
+		t.Fatalf("failed kill: %+v", events[1])
+	}
+}
+
+func TestQueryKiller_StartStop(t *testing.T) {
+	s := processListServer()
+	killer := NewQueryKiller(s.pool(t), QueryKillerConfig{Interval: 5 * time.Millisecond, DefaultMaxTime: time.Minute})
+	if err := killer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(killedIDs(s)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := killer.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(killedIDs(s)) == 0 || killer.IsRunning() {
+		t.Fatal("killer did not run")
+	}
+	if err := killer.Stop(); err == nil {
+		t.Fatal("expected an error stopping twice")
+	}
+}
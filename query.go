@@ -13,9 +13,10 @@ func (c *Conn) Exec(ctx context.Context, query string, args ...any) (sql.Result,
 	if c == nil || c.inner == nil {
 		return nil, sql.ErrConnDone
 	}
-	if c.p != nil && c.p.loggingEnabled {
-		return c.p.instrumentedExecWithLogging(ctx, c.inner, query, args...)
+	if err := c.p.checkQuery(ctx, query); err != nil {
+		return nil, err
 	}
+	query = c.p.tagQuery(ctx, query)
 	return c.inner.ExecContext(ctx, query, args...)
 }
 
@@ -24,18 +25,24 @@ func (c *Conn) Query(ctx context.Context, query string, args ...any) (*sql.Rows,
 	if c == nil || c.inner == nil {
 		return nil, sql.ErrConnDone
 	}
-	if c.p != nil && c.p.loggingEnabled {
-		return c.p.instrumentedQueryWithLogging(ctx, c.inner, query, args...)
+	if err := c.p.checkQuery(ctx, query); err != nil {
+		return nil, err
 	}
-	return c.inner.QueryContext(ctx, query, args...)
+	query = c.p.hintQuery(ctx, c.p.tagQuery(ctx, query))
+	rows, err := c.inner.QueryContext(ctx, query, args...)
+	return rows, wrapQueryTimeout(err, query)
 }
 
-// QueryRow runs a query and returns a single row.
+// QueryRow runs a query and returns a single row. A statement rejected by
+// the query guard is not run; Scan returns the *GuardViolation.
 func (c *Conn) QueryRow(ctx context.Context, query string, args ...any) *sql.Row {
 	if c == nil || c.inner == nil {
 		return &sql.Row{}
 	}
-	return c.inner.QueryRowContext(ctx, query, args...)
+	if err := c.p.checkQuery(ctx, query); err != nil {
+		return errRow(ctx, err)
+	}
+	return c.inner.QueryRowContext(ctx, c.p.hintQuery(ctx, c.p.tagQuery(ctx, query)), args...)
 }
 
 // QueryStream streams rows via callback; cb receives []any per row.
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // QueryBuilder provides a fluent interface for building SQL queries
@@ -15,9 +16,13 @@ type QueryBuilder struct {
 	// Query type and basic structure
 	queryType string
 
+	// Optimizer hints, written as /*+ ... */ after the statement keyword
+	optimizerHints []string
+
 	// SELECT fields
 	selectFields     []string
 	fromTable        string
+	indexHints       []string
 	joins            []string
 	whereConditions  []whereCondition
 	groupByFields    []string
@@ -71,6 +76,41 @@ func (qb *QueryBuilder) From(table string) *QueryBuilder {
 	return qb
 }
 
+// UseIndex adds a USE INDEX hint for the FROM table of a SELECT query
+func (qb *QueryBuilder) UseIndex(indexes ...string) *QueryBuilder {
+	return qb.indexHint("USE", indexes)
+}
+
+// ForceIndex adds a FORCE INDEX hint for the FROM table of a SELECT query
+func (qb *QueryBuilder) ForceIndex(indexes ...string) *QueryBuilder {
+	return qb.indexHint("FORCE", indexes)
+}
+
+// IgnoreIndex adds an IGNORE INDEX hint for the FROM table of a SELECT query
+func (qb *QueryBuilder) IgnoreIndex(indexes ...string) *QueryBuilder {
+	return qb.indexHint("IGNORE", indexes)
+}
+
+func (qb *QueryBuilder) indexHint(kind string, indexes []string) *QueryBuilder {
+	qb.indexHints = append(qb.indexHints, kind+" INDEX ("+strings.Join(indexes, ", ")+")")
+	return qb
+}
+
+// Hint adds optimizer hints, such as "BKA(t1)" or
+// "SET_VAR(sort_buffer_size = 16M)", written as /*+ ... */ after the
+// SELECT, INSERT, UPDATE or DELETE keyword
+func (qb *QueryBuilder) Hint(hints ...string) *QueryBuilder {
+	qb.optimizerHints = append(qb.optimizerHints, hints...)
+	return qb
+}
+
+// MaxExecutionTime adds a MAX_EXECUTION_TIME hint to a SELECT query. It
+// takes precedence over the limit from the context (see WithMaxExecutionTime)
+func (qb *QueryBuilder) MaxExecutionTime(d time.Duration) *QueryBuilder {
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	return qb.Hint("MAX_EXECUTION_TIME(" + strconv.FormatInt(int64(ms), 10) + ")")
+}
+
 // Join adds a JOIN clause to the query
 func (qb *QueryBuilder) Join(joinClause string) *QueryBuilder {
 	qb.joins = append(qb.joins, joinClause)
@@ -202,6 +242,7 @@ func (qb *QueryBuilder) buildSelectQuery() (string, []any) {
 
 	// SELECT clause
 	query.WriteString("SELECT ")
+	qb.writeHints(&query)
 	if len(qb.selectFields) == 0 {
 		query.WriteString("*")
 	} else {
@@ -212,6 +253,10 @@ func (qb *QueryBuilder) buildSelectQuery() (string, []any) {
 	if qb.fromTable != "" {
 		query.WriteString(" FROM ")
 		query.WriteString(qb.fromTable)
+		for _, hint := range qb.indexHints {
+			query.WriteString(" ")
+			query.WriteString(hint)
+		}
 	}
 
 	// JOIN clauses
@@ -280,7 +325,9 @@ func (qb *QueryBuilder) buildInsertQuery() (string, []any) {
 	var query strings.Builder
 	var args []any
 
-	query.WriteString("INSERT INTO ")
+	query.WriteString("INSERT ")
+	qb.writeHints(&query)
+	query.WriteString("INTO ")
 	query.WriteString(qb.insertTable)
 
 	if len(qb.insertColumns) > 0 && len(qb.insertValues) > 0 {
@@ -314,6 +361,7 @@ func (qb *QueryBuilder) buildUpdateQuery() (string, []any) {
 	var args []any
 
 	query.WriteString("UPDATE ")
+	qb.writeHints(&query)
 	query.WriteString(qb.updateTable)
 
 	if len(qb.setFields) > 0 {
@@ -348,7 +396,9 @@ func (qb *QueryBuilder) buildDeleteQuery() (string, []any) {
 	var query strings.Builder
 	var args []any
 
-	query.WriteString("DELETE FROM ")
+	query.WriteString("DELETE ")
+	qb.writeHints(&query)
+	query.WriteString("FROM ")
 	query.WriteString(qb.deleteTable)
 
 	// WHERE clause
@@ -367,3 +417,13 @@ func (qb *QueryBuilder) buildDeleteQuery() (string, []any) {
 
 	return query.String(), args
 }
+
+// writeHints writes the optimizer hint comment followed by a space
+func (qb *QueryBuilder) writeHints(query *strings.Builder) {
+	if len(qb.optimizerHints) == 0 {
+		return
+	}
+	query.WriteString("/*+ ")
+	query.WriteString(strings.Join(qb.optimizerHints, " "))
+	query.WriteString(" */ ")
+}
@@ -0,0 +1,162 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxExecutionTimeKey is the context key for the maximum execution time.
+type maxExecutionTimeKey struct{}
+
+// WithMaxExecutionTime returns a context whose SELECT statements carry a
+// /*+ MAX_EXECUTION_TIME(ms) */ hint, so the server aborts them after d
+// even if the client has stopped waiting. It applies to statements run by
+// Conn.Query, Conn.QueryRow, Conn.QueryCached and everything built on them
+// (QueryBuilder, TableDataManager). A d of zero or less disables the hint
+// for ctx, including the one derived from the deadline.
+//
+// Without it, the time left before the deadline of ctx is used, except
+// for Conn.QueryCached, whose prepared statements would otherwise change
+// with every call. This is on by default; set
+// Config.DisableMaxExecutionTimeFromDeadline to turn it off.
+//
+// Statements interrupted by the limit fail with a *QueryTimeoutError.
+//
+// Example:
+//
+//	ctx := ygggo_mysql.WithMaxExecutionTime(ctx, 2*time.Second)
+//	rows, err := conn.Query(ctx, "SELECT * FROM orders WHERE state = ?", "open")
+func WithMaxExecutionTime(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, maxExecutionTimeKey{}, d)
+}
+
+// maxExecutionTime returns the limit for SELECT statements run with ctx:
+// the value set by WithMaxExecutionTime or, unless
+// Config.DisableMaxExecutionTimeFromDeadline is set, the time left before
+// the deadline of ctx, whichever is smaller.
+func (p *Pool) maxExecutionTime(ctx context.Context) (time.Duration, bool) {
+	return p.executionLimit(ctx, true)
+}
+
+// executionLimit returns the limit set by WithMaxExecutionTime and, with
+// fromDeadline, the one derived from the deadline of ctx.
+func (p *Pool) executionLimit(ctx context.Context, fromDeadline bool) (time.Duration, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	d, ok := ctx.Value(maxExecutionTimeKey{}).(time.Duration)
+	if ok && d <= 0 {
+		return 0, false
+	}
+	if fromDeadline && (p == nil || !p.cfg.DisableMaxExecutionTimeFromDeadline) {
+		if deadline, has := ctx.Deadline(); has {
+			if left := time.Until(deadline); left > 0 && (!ok || left < d) {
+				d, ok = left, true
+			}
+		}
+	}
+	return d, ok
+}
+
+// hintQuery adds a MAX_EXECUTION_TIME hint for the limit in ctx to a
+// SELECT statement. Statements that already set MAX_EXECUTION_TIME are
+// left unchanged.
+func (p *Pool) hintQuery(ctx context.Context, query string) string {
+	d, ok := p.maxExecutionTime(ctx)
+	return addExecutionLimit(query, d, ok)
+}
+
+// hintCachedQuery is hintQuery for prepared statements that are cached:
+// only the limit set by WithMaxExecutionTime is added, since the one
+// derived from the deadline changes with every call.
+func (p *Pool) hintCachedQuery(ctx context.Context, query string) string {
+	d, ok := p.executionLimit(ctx, false)
+	return addExecutionLimit(query, d, ok)
+}
+
+// addExecutionLimit adds a MAX_EXECUTION_TIME hint for d to a SELECT
+// statement when ok is set.
+func addExecutionLimit(query string, d time.Duration, ok bool) string {
+	if !ok {
+		return query
+	}
+	// Round up so that sub-millisecond limits do not become 0, which
+	// disables the limit
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	return addOptimizerHint(query, "SELECT", "MAX_EXECUTION_TIME("+strconv.FormatInt(int64(ms), 10)+")")
+}
+
+// addOptimizerHint adds hint to the optimizer hint comment following the
+// leading keyword of query, creating the comment if needed. The query is
+// returned unchanged when it does not start with keyword or already has
+// a hint of the same name.
+func addOptimizerHint(query, keyword, hint string) string {
+	start := statementStart(query)
+	end := start + len(keyword)
+	if len(query) < end || !strings.EqualFold(query[start:end], keyword) {
+		return query
+	}
+	if end < len(query) && !isHintBoundary(query[end]) {
+		return query // e.g. SELECTED
+	}
+
+	rest := strings.TrimLeft(query[end:], " \t\r\n")
+	if !strings.HasPrefix(rest, "/*+") {
+		return query[:end] + " /*+ " + hint + " */" + query[end:]
+	}
+	closing := strings.Index(rest, "*/")
+	if closing < 0 {
+		return query
+	}
+	name := hint
+	if i := strings.IndexByte(hint, '('); i >= 0 {
+		name = hint[:i]
+	}
+	if strings.Contains(strings.ToUpper(rest[:closing]), strings.ToUpper(name)+"(") {
+		return query
+	}
+	at := len(query) - len(rest) + closing
+	body := strings.TrimRight(query[:at], " \t\r\n")
+	return body + " " + hint + " " + query[at:]
+}
+
+// statementStart returns the offset of the first keyword of query,
+// skipping whitespace and comments other than optimizer hints.
+func statementStart(query string) int {
+	i := 0
+	for {
+		for i < len(query) && strings.ContainsRune(" \t\r\n", rune(query[i])) {
+			i++
+		}
+		if !strings.HasPrefix(query[i:], "/*") || strings.HasPrefix(query[i:], "/*+") {
+			return i
+		}
+		end := strings.Index(query[i+2:], "*/")
+		if end < 0 {
+			return i
+		}
+		i += end + 4
+	}
+}
+
+// isHintBoundary reports whether c may follow a keyword.
+func isHintBoundary(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '/' || c == '*' || c == '('
+}
+
+// maxExecutionTimeHint matches a MAX_EXECUTION_TIME hint.
+var maxExecutionTimeHint = regexp.MustCompile(`(?i)/\*\+[^*]*MAX_EXECUTION_TIME\s*\(\s*(\d+)\s*\)`)
+
+// queryMaxExecutionTime returns the limit set by a MAX_EXECUTION_TIME
+// hint in query, or 0.
+func queryMaxExecutionTime(query string) time.Duration {
+	m := maxExecutionTimeHint.FindStringSubmatch(query)
+	if m == nil {
+		return 0
+	}
+	ms, _ := strconv.ParseInt(m[1], 10, 64)
+	return time.Duration(ms) * time.Millisecond
+}
@@ -0,0 +1,124 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestAddOptimizerHint(t *testing.T) {
+	cases := []struct{ query, want string }{
+		{"SELECT * FROM t", "SELECT /*+ MAX_EXECUTION_TIME(5) */ * FROM t"},
+		{"  select\n1", "  select /*+ MAX_EXECUTION_TIME(5) */\n1"},
+		{"/* note */ SELECT 1", "/* note */ SELECT /*+ MAX_EXECUTION_TIME(5) */ 1"},
+		{"SELECT /*+ BKA(t) */ * FROM t", "SELECT /*+ BKA(t) MAX_EXECUTION_TIME(5) */ * FROM t"},
+		{"SELECT /*+ max_execution_time(9) */ 1", "SELECT /*+ max_execution_time(9) */ 1"},
+		{"UPDATE t SET a = 1", "UPDATE t SET a = 1"},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", "WITH x AS (SELECT 1) SELECT * FROM x"},
+		{"SELECTED", "SELECTED"},
+	}
+	for _, c := range cases {
+		if got := addOptimizerHint(c.query, "SELECT", "MAX_EXECUTION_TIME(5)"); got != c.want {
+			t.Errorf("%q: got %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+func TestMaxExecutionTime_FromContext(t *testing.T) {
+	p := &Pool{}
+	ctx := context.Background()
+	if got := p.hintQuery(ctx, "SELECT 1"); got != "SELECT 1" {
+		t.Fatalf("no limit: %q", got)
+	}
+	if got := p.hintQuery(WithMaxExecutionTime(ctx, 1500*time.Microsecond), "SELECT 1"); got != "SELECT /*+ MAX_EXECUTION_TIME(2) */ 1" {
+		t.Fatalf("explicit limit: %q", got)
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	if d, ok := p.maxExecutionTime(dctx); !ok || d <= 59*time.Minute || d > time.Hour {
+		t.Fatalf("deadline limit: %v", d)
+	}
+	// The smaller limit wins; zero disables the hint
+	if d, _ := p.maxExecutionTime(WithMaxExecutionTime(dctx, time.Second)); d != time.Second {
+		t.Fatalf("explicit limit: %v", d)
+	}
+	if got := p.hintQuery(WithMaxExecutionTime(dctx, 0), "SELECT 1"); got != "SELECT 1" {
+		t.Fatalf("disabled limit: %q", got)
+	}
+
+	p.cfg.DisableMaxExecutionTimeFromDeadline = true
+	if got := p.hintQuery(dctx, "SELECT 1"); got != "SELECT 1" {
+		t.Fatalf("deadline used with DisableMaxExecutionTimeFromDeadline: %q", got)
+	}
+	if d, _ := p.maxExecutionTime(WithMaxExecutionTime(dctx, time.Minute)); d != time.Minute {
+		t.Fatalf("explicit limit: %v", d)
+	}
+}
+
+func TestConnQuery_MaxExecutionTime(t *testing.T) {
+	s := newFakeServer()
+	s.set("SELECT /*+ MAX_EXECUTION_TIME", serverResult{err: &mysql.MySQLError{Number: 3024, Message: "Query execution was interrupted, maximum statement execution time exceeded"}})
+	p := s.pool(t)
+	ctx := WithMaxExecutionTime(context.Background(), 250*time.Millisecond)
+	ctx = WithQueryTags(ctx, map[string]string{"route": "/orders"})
+
+	dc, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dc.Close()
+	_, err = dc.Query(ctx, "SELECT * FROM orders")
+	var te *QueryTimeoutError
+	if !errors.As(err, &te) || te.Limit != 250*time.Millisecond || !IsQueryTimeout(err) {
+		t.Fatalf("expected a query timeout, got %v", err)
+	}
+	s.mu.Lock()
+	last := s.queries[len(s.queries)-1]
+	s.mu.Unlock()
+	if last != "SELECT /*+ MAX_EXECUTION_TIME(250) */ * FROM orders /*route='%2Forders'*/" {
+		t.Fatalf("query: %s", last)
+	}
+
+	// Errors reported by Row.Scan are recognized too
+	var n int
+	if err := dc.QueryRow(ctx, "SELECT COUNT(*) FROM orders").Scan(&n); !IsQueryTimeout(err) {
+		t.Fatalf("expected a query timeout from Scan, got %v", err)
+	}
+	if IsQueryTimeout(errors.New("other")) {
+		t.Fatal("unexpected query timeout")
+	}
+}
+
+func TestQueryBuilder_Hints(t *testing.T) {
+	qb := NewQueryBuilder(nil).Select("id").From("orders o").
+		ForceIndex("idx_state").IgnoreIndex("idx_a", "idx_b").
+		Hint("NO_ICP(o)").MaxExecutionTime(time.Second).
+		Where("state = ?", "open")
+	query, args := qb.buildSelectQuery()
+	want := "SELECT /*+ NO_ICP(o) MAX_EXECUTION_TIME(1000) */ id FROM orders o FORCE INDEX (idx_state) IGNORE INDEX (idx_a, idx_b) WHERE (state = ?)"
+	if query != want || len(args) != 1 {
+		t.Fatalf("got  %s\nwant %s", query, want)
+	}
+	// The builder's limit is kept over the one from the context
+	if got := (&Pool{}).hintQuery(WithMaxExecutionTime(context.Background(), time.Minute), query); got != query {
+		t.Fatalf("hint replaced: %s", got)
+	}
+
+	query, _ = NewQueryBuilder(nil).Update("orders").Set("state", "closed").Hint("SET_VAR(foreign_key_checks=OFF)").buildUpdateQuery()
+	if !strings.HasPrefix(query, "UPDATE /*+ SET_VAR(foreign_key_checks=OFF) */ orders SET") {
+		t.Fatalf("update: %s", query)
+	}
+	query, _ = NewQueryBuilder(nil).Delete("orders").buildDeleteQuery()
+	if query != "DELETE FROM orders" {
+		t.Fatalf("delete: %s", query)
+	}
+	query, _ = NewQueryBuilder(nil).Select().From("t").UseIndex("a").buildSelectQuery()
+	if query != "SELECT * FROM t USE INDEX (a)" {
+		t.Fatalf("use index: %s", query)
+	}
+}
@@ -0,0 +1,135 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// queryTagsKey is the context key for query tags.
+type queryTagsKey struct{}
+
+// WithQueryTags returns a context carrying tags that are appended to every
+// statement run with it, as a sqlcommenter comment:
+//
+//	SELECT * FROM orders WHERE id = ? /*route='%2Forders',trace_id='4bf92f35'*/
+//
+// The comment shows up in performance_schema, the process list and the
+// slow log, so queries can be traced back to the code that issued them.
+// Tags already in ctx are kept; tags with the same key are replaced.
+// Empty values remove a tag.
+//
+// Example:
+//
+//	ctx = ygggo_mysql.WithQueryTags(ctx, map[string]string{
+//		"route":    "/orders",
+//		"trace_id": traceID,
+//	})
+//	err := pool.WithConn(ctx, func(c ygggo_mysql.DatabaseConn) error {
+//		_, err := c.Exec(ctx, "UPDATE orders SET state = ? WHERE id = ?", "paid", id)
+//		return err
+//	})
+func WithQueryTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for k, v := range QueryTagsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		if v == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+	return context.WithValue(ctx, queryTagsKey{}, merged)
+}
+
+// QueryTagsFromContext returns the query tags carried by ctx, or nil.
+// The returned map must not be modified.
+func QueryTagsFromContext(ctx context.Context) map[string]string {
+	if ctx == nil {
+		return nil
+	}
+	tags, _ := ctx.Value(queryTagsKey{}).(map[string]string)
+	return tags
+}
+
+// tagQuery appends the tags in ctx to query. Only keys listed in
+// Config.QueryTagKeys are used when that list is set.
+func (p *Pool) tagQuery(ctx context.Context, query string) string {
+	tags := QueryTagsFromContext(ctx)
+	if len(tags) == 0 {
+		return query
+	}
+	var allowed []string
+	if p != nil {
+		allowed = p.cfg.QueryTagKeys
+	}
+	return appendQueryTags(query, tags, allowed)
+}
+
+// appendQueryTags adds a sqlcommenter comment built from tags to query.
+// Keys and values are URL-encoded, values are quoted, and keys are sorted.
+// Statements that already contain a comment other than optimizer hints
+// are left unchanged, as the sqlcommenter specification requires. A trailing ';' stays last.
+func appendQueryTags(query string, tags map[string]string, allowed []string) string {
+	if len(tags) == 0 || sqlComment.MatchString(query) {
+		return query
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if len(allowed) > 0 && !containsString(allowed, k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return query
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	body := strings.TrimRight(query, " \t\r\n")
+	semicolon := strings.HasSuffix(body, ";")
+	if semicolon {
+		body = strings.TrimRight(strings.TrimSuffix(body, ";"), " \t\r\n")
+	}
+	b.WriteString(body)
+	b.WriteString(" /*")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(sqlCommenterEscape(k))
+		b.WriteString("='")
+		b.WriteString(sqlCommenterEscape(tags[k]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	if semicolon {
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// sqlCommenterEscape URL-encodes s like encodeURIComponent. The result
+// never contains quotes or '*', so it cannot close the comment.
+func sqlCommenterEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlComment matches block comments other than optimizer hints (/*+ */)
+// and version comments (/*! */).
+var sqlComment = regexp.MustCompile(`/\*(?:[^+!*][\s\S]*?)?\*/`)
@@ -0,0 +1,118 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAppendQueryTags(t *testing.T) {
+	tags := map[string]string{"trace_id": "4bf92f35", "route": "/orders/{id}", "who": "it's */ me"}
+	got := appendQueryTags("SELECT * FROM orders WHERE id = ?;", tags, nil)
+	want := "SELECT * FROM orders WHERE id = ? /*route='%2Forders%2F%7Bid%7D',trace_id='4bf92f35',who='it%27s%20%2A%2F%20me'*/;"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	got = appendQueryTags("SELECT 1", tags, []string{"route"})
+	if got != "SELECT 1 /*route='%2Forders%2F%7Bid%7D'*/" {
+		t.Fatalf("allowlist not applied: %s", got)
+	}
+	if got := appendQueryTags("SELECT 1", tags, []string{"tenant"}); got != "SELECT 1" {
+		t.Fatalf("no allowed tags should leave query unchanged: %s", got)
+	}
+	if got := appendQueryTags("SELECT /* hint */ 1", tags, nil); got != "SELECT /* hint */ 1" {
+		t.Fatalf("commented statements must not be modified: %s", got)
+	}
+}
+
+func TestWithQueryTags_Merges(t *testing.T) {
+	ctx := WithQueryTags(context.Background(), map[string]string{"route": "/a", "user": "1"})
+	ctx = WithQueryTags(ctx, map[string]string{"route": "/b", "user": ""})
+	tags := QueryTagsFromContext(ctx)
+	if len(tags) != 1 || tags["route"] != "/b" {
+		t.Fatalf("tags=%v", tags)
+	}
+	if QueryTagsFromContext(context.Background()) != nil {
+		t.Fatalf("expected no tags")
+	}
+}
+
+func TestConnExec_AppendsQueryTags(t *testing.T) {
+	s := newSessionTestServer()
+	p := s.pool(t)
+	p.cfg.QueryTagKeys = []string{"route"}
+	ctx := WithQueryTags(context.Background(), map[string]string{"route": "/orders", "secret": "x"})
+	if err := p.WithConn(ctx, func(c DatabaseConn) error {
+		_, err := c.Exec(ctx, "DELETE FROM orders")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	execs := s.executed("")
+	if len(execs) != 1 || execs[0] != "DELETE FROM orders /*route='%2Forders'*/" {
+		t.Fatalf("execs=%q", execs)
+	}
+}
+
+func TestConnCached_AppendsQueryTags(t *testing.T) {
+	p := newStatsTestPool(t, StatementStatsConfig{})
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = 0
+	p.slowQueryRecorder = NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(10))
+	defer p.slowQueryRecorder.Close()
+
+	ctx := WithQueryTags(context.Background(), map[string]string{"route": "/orders"})
+	err := p.WithConn(ctx, func(c DatabaseConn) error {
+		cc := c.(*Conn)
+		cc.EnableStmtCache(4)
+		if _, err := cc.ExecCached(ctx, "UPDATE orders SET a = ?", 1); err != nil {
+			return err
+		}
+		// The deadline adds no hint, so the statement is reused
+		dctx, cancel := context.WithTimeout(ctx, time.Hour)
+		defer cancel()
+		for _, qctx := range []context.Context{ctx, dctx, WithMaxExecutionTime(ctx, time.Second)} {
+			rs, err := cc.QueryCached(qctx, "SELECT x FROM orders WHERE id = ?", 1)
+			if err != nil {
+				return err
+			}
+			rs.Close()
+		}
+		if n := cc.cache.ll.Len(); n != 3 {
+			t.Errorf("cached %d statements", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, _ := p.slowQueryRecorder.GetRecords(context.Background(), SlowQueryFilter{})
+	seen := make(map[string]bool)
+	for _, r := range records {
+		seen[r.Query] = true
+	}
+	for _, q := range []string{
+		"UPDATE orders SET a = ? /*route='%2Forders'*/",
+		"SELECT x FROM orders WHERE id = ? /*route='%2Forders'*/",
+		"SELECT /*+ MAX_EXECUTION_TIME(1000) */ x FROM orders WHERE id = ? /*route='%2Forders'*/",
+	} {
+		if !seen[q] {
+			t.Fatalf("%q was not run; got %v", q, seen)
+		}
+	}
+}
+
+func TestSlowQueryNormalization_IgnoresQueryTags(t *testing.T) {
+	rec := NewSlowQueryRecorder(SlowQueryConfig{Enabled: true, Threshold: time.Millisecond, NormalizationMode: "basic"}, NewMemorySlowQueryStorage(10))
+	a := rec.normalizeQuery("SELECT * FROM t WHERE id = 1 /*route='%2Fa',trace_id='1'*/", "basic")
+	b := rec.normalizeQuery("SELECT * FROM t WHERE id = 2", "basic")
+	if a != b {
+		t.Fatalf("tagged and untagged queries should group together: %q vs %q", a, b)
+	}
+	if got := rec.normalizeQuery("SELECT /*+ MAX_EXECUTION_TIME(10) */ 1", "basic"); got != "SELECT ?" {
+		t.Fatalf("hinted and unhinted queries should group together: %q", got)
+	}
+}
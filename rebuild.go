@@ -0,0 +1,122 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// poolDrainTimeout bounds the wait for connections borrowed from a
+// replaced handle before it is closed.
+const poolDrainTimeout = time.Minute
+
+// poolDrainPoll is the interval at which a replaced handle is checked for
+// borrowed connections.
+const poolDrainPoll = 100 * time.Millisecond
+
+// Rebuild replaces the pool's *sql.DB with a new one opened from the
+// pool's Config, with a fresh connector and new connections, and swaps it
+// in atomically: the *Pool stays valid and new work uses the new handle.
+// Connections and transactions already borrowed keep working; the old
+// handle is closed once they are returned (or after a minute).
+//
+// With Config.Hosts the current host is tried first, then the others in
+// Config.FailoverPolicy order. The current handle is kept when no server
+// can be reached.
+//
+// Rebuild emits ProbeEventRebuildSuccess or ProbeEventRebuildFailed to the
+// handlers added with AddFailoverHandler. It returns ErrPoolClosed once the
+// pool is closed.
+func (p *Pool) Rebuild(ctx context.Context) error {
+	return p.rebuildFrom(ctx, p.getDB())
+}
+
+// rebuildFrom rebuilds the pool unless the handle failed has already been
+// replaced by a concurrent rebuild or failover, in which case it returns
+// nil, or the pool is closed.
+func (p *Pool) rebuildFrom(ctx context.Context, failed *sql.DB) error {
+	if p == nil {
+		return errors.New("nil pool")
+	}
+	p.failoverMu.Lock()
+	defer p.failoverMu.Unlock()
+
+	p.dbMu.RLock()
+	current, idx, dsn, closed := p.db, p.hostIdx, p.dsn, p.closed
+	p.dbMu.RUnlock()
+	if closed {
+		return ErrPoolClosed
+	}
+	if failed != nil && current != failed {
+		return nil
+	}
+	if dsn == "" && len(p.hosts) == 0 {
+		return errors.New("pool was not opened from a Config and cannot be rebuilt")
+	}
+
+	var db *sql.DB
+	var err error
+	next := idx
+	if len(p.hosts) > 0 {
+		candidates := failoverCandidates(p.cfg.FailoverPolicy, len(p.hosts), idx)
+		if idx >= 0 {
+			// Prefer the current host; failoverCandidates lists it last
+			candidates = append([]int{idx}, candidates[:len(candidates)-1]...)
+		}
+		db, next, dsn, err = p.connectHost(ctx, candidates, false)
+	} else {
+		db, err = openDB(ctx, p.cfg, dsn, p)
+	}
+	if err != nil {
+		err = redactError(err)
+		p.emitFailoverEvent(ProbeEventRebuildFailed, p.hostName(idx), "", "Pool rebuild failed", err)
+		return fmt.Errorf("failed to rebuild pool: %w", err)
+	}
+
+	if err := p.replaceDB(db, next, dsn); err != nil {
+		return err
+	}
+	p.emitFailoverEvent(ProbeEventRebuildSuccess, p.hostName(idx), p.hostName(next), "Pool rebuilt", nil)
+	return nil
+}
+
+// replaceDB swaps in db, connected to the host at idx with dsn, and closes
+// the previous handle once drained. When the pool was closed meanwhile, db
+// is closed instead and ErrPoolClosed returned.
+func (p *Pool) replaceDB(db *sql.DB, idx int, dsn string) error {
+	p.dbMu.Lock()
+	if p.closed {
+		p.dbMu.Unlock()
+		_ = db.Close()
+		return ErrPoolClosed
+	}
+	old := p.db
+	p.db = db
+	p.hostIdx = idx
+	p.dsn = dsn
+	p.dbMu.Unlock()
+	if old != nil {
+		go closeWhenDrained(old, poolDrainTimeout)
+	}
+	return nil
+}
+
+// hostName returns the host at idx, or an empty string.
+func (p *Pool) hostName(idx int) string {
+	if idx < 0 || idx >= len(p.hosts) {
+		return ""
+	}
+	return p.hosts[idx]
+}
+
+// closeWhenDrained closes db once none of its connections are borrowed,
+// or after timeout.
+func closeWhenDrained(db *sql.DB, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for db.Stats().InUse > 0 && time.Now().Before(deadline) {
+		time.Sleep(poolDrainPoll)
+	}
+	_ = db.Close()
+}
@@ -0,0 +1,176 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func newRebuildTestPool(t *testing.T) (*Pool, *fakeServer) {
+	t.Helper()
+	s := newFakeServer()
+	s.set("", serverResult{})
+	p := &Pool{cfg: Config{Driver: fakeServerDriver}, dsn: s.dsn(t), hostIdx: -1}
+	db, err := openDB(context.Background(), p.cfg, p.dsn, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.db = db
+	t.Cleanup(func() { p.Close() })
+	return p, s
+}
+
+func TestPool_Rebuild(t *testing.T) {
+	p, _ := newRebuildTestPool(t)
+	ctx := context.Background()
+	old := p.getDB()
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Rebuild(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.getDB() == old {
+		t.Fatal("handle was not replaced")
+	}
+
+	// The old handle stays open while its connection is borrowed
+	time.Sleep(2 * poolDrainPoll)
+	if err := old.PingContext(ctx); err != nil {
+		t.Fatalf("old handle closed before draining: %v", err)
+	}
+	conn.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for old.PingContext(ctx) == nil && time.Now().Before(deadline) {
+		time.Sleep(poolDrainPoll)
+	}
+	if err := old.PingContext(ctx); err == nil {
+		t.Fatal("old handle was not closed after draining")
+	}
+
+	if err := (&Pool{}).Rebuild(ctx); err == nil {
+		t.Fatal("expected an error for a pool without a Config")
+	}
+}
+
+func TestAutoReconnector_RebuildsPool(t *testing.T) {
+	p, s := newRebuildTestPool(t)
+	old := p.getDB()
+	s.breakConnections()
+	if err := p.Ping(context.Background()); err == nil {
+		t.Fatal("expected the stale connection to fail")
+	}
+
+	policy := ReconnectPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 2}
+	if NewAutoReconnector(p, policy).Reconnect(context.Background()) {
+		t.Fatal("reconnected without rebuilding")
+	}
+	policy.RebuildPool = true
+	if !NewAutoReconnector(p, policy).Reconnect(context.Background()) {
+		t.Fatal("reconnect failed")
+	}
+	if p.getDB() == old {
+		t.Fatal("handle was not replaced")
+	}
+	if err := p.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPool_RebuildAfterClose(t *testing.T) {
+	p, _ := newRebuildTestPool(t)
+	ctx := context.Background()
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Rebuild(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+	policy := ReconnectPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 2, RebuildPool: true}
+	if NewAutoReconnector(p, policy).Reconnect(ctx) {
+		t.Fatal("reconnect resurrected a closed pool")
+	}
+	if err := p.Ping(ctx); err == nil {
+		t.Fatal("ping succeeded on a closed pool")
+	}
+}
+
+func TestConnectionProbe_StopCancelsReconnect(t *testing.T) {
+	p, s := newRebuildTestPool(t)
+	s.stale.Store(math.MaxInt64)
+
+	policy := ReconnectPolicy{MaxAttempts: 100, InitialBackoff: time.Hour, MaxBackoff: time.Hour, BackoffMultiplier: 1}
+	probe := NewConnectionProbe(p, ProbeConfig{Interval: time.Hour, Timeout: time.Second, FailureThreshold: 1, SuccessThreshold: 1,
+		EnableAutoReconnect: true, ReconnectPolicy: policy, EventBuffer: 8})
+	events := probe.Events()
+	if err := probe.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !probe.reconnector.IsActive() {
+		if time.Now().After(deadline) {
+			t.Fatal("reconnection did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := probe.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for probe.reconnector.IsActive() {
+		if time.Now().After(deadline) {
+			t.Fatal("Stop did not cancel the reconnection")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for range events {
+	}
+}
+
+func TestConnectionProbe_Events(t *testing.T) {
+	p, _ := newRebuildTestPool(t)
+	probe := NewConnectionProbe(p, ProbeConfig{Interval: time.Hour, Timeout: time.Second, FailureThreshold: 1, SuccessThreshold: 1, EventBuffer: 2})
+	events := probe.Events()
+	if err := probe.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	next := func() ProbeEvent {
+		t.Helper()
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event")
+			return ProbeEvent{}
+		}
+	}
+	if e := next(); e.Type != ProbeEventHealthy {
+		t.Fatalf("first event: %v", e.Type)
+	}
+	if err := p.Rebuild(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if e := next(); e.Type != ProbeEventRebuildSuccess || e.State.Status != ProbeStatusHealthy {
+		t.Fatalf("rebuild event: %+v", e)
+	}
+
+	// A full channel drops events instead of blocking the probe
+	for i := 0; i < 3; i++ {
+		if err := p.Rebuild(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dropped := probe.GetMetrics().DroppedEvents; dropped != 1 {
+		t.Fatalf("dropped %d events", dropped)
+	}
+
+	if err := probe.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for range events {
+	}
+}
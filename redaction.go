@@ -0,0 +1,509 @@
+package ygggo_mysql
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ArgPolicy selects how query arguments are redacted.
+type ArgPolicy string
+
+const (
+	// ArgPolicyKeep keeps argument values, except those bound to columns
+	// matching RedactionConfig.ColumnPattern.
+	ArgPolicyKeep ArgPolicy = "keep"
+	// ArgPolicyAll replaces every value with a type placeholder such as "[string]".
+	ArgPolicyAll ArgPolicy = "all"
+	// ArgPolicyTypes replaces values whose Go type is listed in
+	// RedactionConfig.Types and keeps the others.
+	ArgPolicyTypes ArgPolicy = "types"
+	// ArgPolicyHash replaces every value with a keyed hash, so equal values
+	// can still be correlated without revealing them.
+	ArgPolicyHash ArgPolicy = "hash"
+)
+
+// DefaultSensitiveColumns matches column names whose values are always redacted.
+const DefaultSensitiveColumns = `(?i)(pass(word)?|passwd|pwd|secret|token|api_?key|ssn|credit_?card|card_?number|cvv)`
+
+// redactedPlaceholder replaces secrets in strings and column-matched arguments.
+const redactedPlaceholder = "[REDACTED]"
+
+// RedactionConfig controls how secrets and query data are masked before
+// they reach logs, slow query records, probe events and errors.
+type RedactionConfig struct {
+	// Args is the argument policy. Defaults to ArgPolicyAll.
+	Args ArgPolicy `json:"args"`
+
+	// ColumnPattern is a regular expression over column names. Arguments
+	// bound to a matching column (e.g. "password = ?") are always replaced
+	// with [REDACTED], whatever the policy. Empty disables the check.
+	ColumnPattern string `json:"column_pattern"`
+
+	// Types lists the Go types (as printed by %T, e.g. "string", "[]uint8",
+	// "time.Time") redacted by ArgPolicyTypes.
+	Types []string `json:"types"`
+
+	// HashKey keys the HMAC used by ArgPolicyHash. Use a per-deployment
+	// secret so hashes cannot be brute-forced from known values.
+	HashKey string `json:"-"`
+
+	// MaskLiterals replaces string and numeric literals in query text with
+	// '?' before it is logged or recorded.
+	MaskLiterals bool `json:"mask_literals"`
+}
+
+// DefaultRedactionConfig returns the redaction used when none is configured:
+// all arguments are replaced by type placeholders, arguments bound to
+// sensitive columns are fully redacted, and query text is kept.
+func DefaultRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Args:          ArgPolicyAll,
+		ColumnPattern: DefaultSensitiveColumns,
+		Types:         []string{"string", "[]uint8"},
+	}
+}
+
+// Redactor applies a RedactionConfig. It is safe for concurrent use.
+type Redactor struct {
+	cfg     RedactionConfig
+	columns *regexp.Regexp
+	types   map[string]bool
+}
+
+// NewRedactor compiles cfg into a Redactor.
+func NewRedactor(cfg RedactionConfig) (*Redactor, error) {
+	if cfg.Args == "" {
+		cfg.Args = ArgPolicyAll
+	}
+	switch cfg.Args {
+	case ArgPolicyKeep, ArgPolicyAll, ArgPolicyTypes, ArgPolicyHash:
+	default:
+		return nil, fmt.Errorf("unknown argument redaction policy %q", cfg.Args)
+	}
+	r := &Redactor{cfg: cfg, types: make(map[string]bool, len(cfg.Types))}
+	if cfg.ColumnPattern != "" {
+		re, err := regexp.Compile(cfg.ColumnPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction column pattern: %w", err)
+		}
+		r.columns = re
+	}
+	for _, t := range cfg.Types {
+		r.types[t] = true
+	}
+	return r, nil
+}
+
+// defaultRedactor is used by pools and recorders without their own Redactor.
+var defaultRedactor = func() *Redactor {
+	r, err := NewRedactor(DefaultRedactionConfig())
+	if err != nil {
+		panic(err)
+	}
+	return r
+}()
+
+// DefaultRedactor returns the Redactor built from DefaultRedactionConfig.
+func DefaultRedactor() *Redactor {
+	return defaultRedactor
+}
+
+// Config returns the configuration r was built from.
+func (r *Redactor) Config() RedactionConfig {
+	return r.cfg
+}
+
+// Args returns a redacted copy of the arguments bound to query.
+func (r *Redactor) Args(query string, args []any) []any {
+	return r.args(query, args, r.cfg.Args)
+}
+
+// args redacts args with policy instead of the configured one.
+func (r *Redactor) args(query string, args []any, policy ArgPolicy) []any {
+	if len(args) == 0 {
+		return args
+	}
+	var cols []string
+	if r.columns != nil {
+		cols = placeholderColumns(query)
+	}
+	out := make([]any, len(args))
+	for i, arg := range args {
+		if i < len(cols) && cols[i] != "" && r.columns.MatchString(cols[i]) {
+			out[i] = redactedPlaceholder
+			continue
+		}
+		out[i] = r.arg(arg, policy)
+	}
+	return out
+}
+
+// arg applies the argument policy to a single value.
+func (r *Redactor) arg(v any, policy ArgPolicy) any {
+	switch policy {
+	case ArgPolicyKeep:
+		return v
+	case ArgPolicyTypes:
+		if !r.types[fmt.Sprintf("%T", v)] {
+			return v
+		}
+		return typePlaceholder(v)
+	case ArgPolicyHash:
+		if v == nil {
+			return nil
+		}
+		mac := hmac.New(sha256.New, []byte(r.cfg.HashKey))
+		fmt.Fprintf(mac, "%T:%v", v, v)
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	default:
+		return typePlaceholder(v)
+	}
+}
+
+// typePlaceholder describes v without revealing its value.
+func typePlaceholder(v any) any {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return "[string]"
+	case []byte:
+		return fmt.Sprintf("[bytes:%d]", len(t))
+	case time.Time:
+		return "[time]"
+	default:
+		return fmt.Sprintf("[%T]", v)
+	}
+}
+
+// Query returns query with literals masked when MaskLiterals is set.
+func (r *Redactor) Query(query string) string {
+	if !r.cfg.MaskLiterals {
+		return query
+	}
+	return maskLiterals(query)
+}
+
+// String masks credentials embedded in free text such as error messages.
+func (r *Redactor) String(s string) string {
+	return RedactString(s)
+}
+
+// Error wraps err so that its message is redacted. errors.Is and
+// errors.As still see the original error. It returns nil for nil.
+func (r *Redactor) Error(err error) error {
+	return redactError(err)
+}
+
+// redactedError carries a redacted message for a wrapped error.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError returns err with credentials removed from its message.
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*redactedError); ok {
+		return err
+	}
+	msg := err.Error()
+	redacted := RedactString(msg)
+	if redacted == msg {
+		return err
+	}
+	return &redactedError{msg: redacted, err: err}
+}
+
+// RedactDSN returns dsn with its password replaced by "***".
+// It follows the go-sql-driver/mysql DSN layout
+// [user[:password]@][net[(addr)]]/dbname[?params].
+func RedactDSN(dsn string) string {
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return dsn
+	}
+	at := strings.LastIndex(dsn[:slash], "@")
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+	return dsn[:colon+1] + "***" + dsn[at:]
+}
+
+// RedactedDSN returns the pool's DSN with the password masked.
+// Use it instead of DSN whenever the value is printed or logged.
+func (p *Pool) RedactedDSN() string {
+	return RedactDSN(p.DSN())
+}
+
+// SetRedaction replaces the redaction applied to the pool's query logs,
+// slow query records and failover events.
+//
+// Thread Safety: This method is safe for concurrent use.
+func (p *Pool) SetRedaction(cfg RedactionConfig) error {
+	r, err := NewRedactor(cfg)
+	if err != nil {
+		return err
+	}
+	p.redactor.Store(r)
+	if p.slowQueryRecorder != nil {
+		p.slowQueryRecorder.SetRedactor(r)
+	}
+	return nil
+}
+
+// Redactor returns the redaction currently applied by the pool.
+func (p *Pool) Redactor() *Redactor {
+	return p.getRedactor()
+}
+
+// getRedactor returns the pool's Redactor, or the default one.
+func (p *Pool) getRedactor() *Redactor {
+	if p != nil {
+		if r := p.redactor.Load(); r != nil {
+			return r
+		}
+	}
+	return defaultRedactor
+}
+
+var (
+	// dsnCredentials matches "user:password@net(" inside free text.
+	dsnCredentials = regexp.MustCompile(`([\w.\-]+):[^\s@]+@(tcp|tcp4|tcp6|unix|udp)?\(`)
+	// secretAssignments matches key=value pairs whose key names a secret.
+	secretAssignments = regexp.MustCompile(`(?i)\b(\w*(?:password|passwd|pwd|secret|token))=([^\s&,;]+)`)
+)
+
+// RedactString masks DSN passwords and secret key=value pairs
+// (e.g. MYSQL_ROOT_PASSWORD=..., token=...) in s.
+func RedactString(s string) string {
+	s = dsnCredentials.ReplaceAllString(s, "$1:***@$2(")
+	return secretAssignments.ReplaceAllString(s, "$1=***")
+}
+
+// maskLiterals replaces quoted strings and numeric literals with '?',
+// leaving identifiers, backquoted names and placeholders untouched.
+func maskLiterals(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			j := skipQuoted(query, i)
+			b.WriteByte('?')
+			i = j
+		case c == '`':
+			j := skipQuoted(query, i)
+			b.WriteString(query[i:j])
+			i = j
+		case isDigit(c) && (i == 0 || !isIdentByte(query[i-1])):
+			j := i
+			for j < len(query) && (isIdentByte(query[j]) || query[j] == '.') {
+				j++
+			}
+			b.WriteByte('?')
+			i = j
+		case isIdentByte(c):
+			j := i
+			for j < len(query) && isIdentByte(query[j]) {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// skipQuoted returns the index just past the quoted token starting at i.
+func skipQuoted(s string, i int) int {
+	q := s[i]
+	j := i + 1
+	for j < len(s) {
+		switch {
+		case s[j] == '\\' && q != '`':
+			j += 2
+			continue
+		case s[j] == q:
+			if j+1 < len(s) && s[j+1] == q {
+				j += 2 // doubled quote escape
+				continue
+			}
+			return j + 1
+		}
+		j++
+	}
+	return len(s)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z') || c >= 0x80
+}
+
+// placeholderColumns returns, for each '?' placeholder in query, the column
+// it is bound to when that can be inferred ("col = ?", "col IN (?, ?)",
+// "INSERT INTO t (a, b) VALUES (?, ?)"), or "" otherwise.
+func placeholderColumns(query string) []string {
+	toks := sqlTokens(query)
+	var cols []string
+	var insertCols []string
+	inValues := false
+	valuePos := 0
+	depth := 0
+	for i, t := range toks {
+		up := strings.ToUpper(t)
+		switch {
+		case up == "VALUES" || up == "VALUE":
+			inValues = true
+			insertCols = insertColumnList(toks[:i])
+		case t == "(":
+			depth++
+			if inValues && depth == 1 {
+				valuePos = 0
+			}
+		case t == ")":
+			depth--
+		case t == ",":
+			if inValues && depth == 1 {
+				valuePos++
+			}
+		case t == "?":
+			col := ""
+			if inValues && depth == 1 && valuePos < len(insertCols) {
+				col = insertCols[valuePos]
+			} else {
+				col = columnBefore(toks[:i])
+			}
+			cols = append(cols, col)
+		}
+		if inValues && depth == 0 && (up == "ON" || up == "SELECT") {
+			inValues = false
+		}
+	}
+	return cols
+}
+
+// insertColumnList returns the column list of "INSERT INTO t (a, b)" in toks.
+func insertColumnList(toks []string) []string {
+	end := -1
+	for i := len(toks) - 1; i >= 0; i-- {
+		if toks[i] == ")" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+	start := -1
+	for i := end - 1; i >= 0; i-- {
+		if toks[i] == "(" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	var cols []string
+	for _, t := range toks[start+1 : end] {
+		if t != "," {
+			cols = append(cols, strings.Trim(t, "`"))
+		}
+	}
+	return cols
+}
+
+// columnBefore finds the column compared with the placeholder that
+// follows toks, skipping operators, IN lists and LIKE.
+func columnBefore(toks []string) string {
+	i := len(toks) - 1
+	// Inside an IN list: skip back over "?, ?, (" to the IN keyword
+	for i >= 0 && (toks[i] == "," || toks[i] == "?" || toks[i] == "(") {
+		i--
+	}
+	compared := false
+	for i >= 0 {
+		switch strings.ToUpper(toks[i]) {
+		case "=", "<", ">", "<=", ">=", "<>", "!=", "<=>", "IN", "LIKE", "NOT", "IS":
+			compared = true
+			i--
+			continue
+		}
+		break
+	}
+	if !compared || i < 0 || !isIdentByte(toks[i][0]) && toks[i][0] != '`' {
+		return ""
+	}
+	name := strings.Trim(toks[i], "`")
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	return name
+}
+
+// sqlTokens splits query into identifiers, quoted tokens, placeholders,
+// punctuation and operators. Whitespace and comments are dropped.
+func sqlTokens(query string) []string {
+	var toks []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(query) && query[i+1] == '-', c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+		case c == '\'' || c == '"' || c == '`':
+			j := skipQuoted(query, i)
+			toks = append(toks, query[i:j])
+			i = j
+		case isIdentByte(c):
+			j := i
+			for j < len(query) && (isIdentByte(query[j]) || query[j] == '.' || query[j] == '`') {
+				j++
+			}
+			toks = append(toks, query[i:j])
+			i = j
+		case c == '<' || c == '>' || c == '!' || c == '=':
+			j := i + 1
+			for j < len(query) && j < i+3 && strings.IndexByte("<>=", query[j]) >= 0 {
+				j++
+			}
+			toks = append(toks, query[i:j])
+			i = j
+		default:
+			toks = append(toks, string(c))
+			i++
+		}
+	}
+	return toks
+}
@@ -0,0 +1,194 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+func TestRedactDSN(t *testing.T) {
+	cases := map[string]string{
+		"user:secret@tcp(db:3306)/app?charset=utf8mb4": "user:***@tcp(db:3306)/app?charset=utf8mb4",
+		"user:p@ss:w/rd@tcp(db:3306)/app":              "user:***@tcp(db:3306)/app",
+		"user@tcp(db:3306)/app":                        "user@tcp(db:3306)/app",
+		"/app":                                         "/app",
+		"":                                             "",
+	}
+	for in, want := range cases {
+		if got := RedactDSN(in); got != want {
+			t.Fatalf("RedactDSN(%q)=%q want %q", in, got, want)
+		}
+	}
+	p := &Pool{dsn: "root:hunter2@tcp(localhost:3306)/app"}
+	if got := p.RedactedDSN(); strings.Contains(got, "hunter2") {
+		t.Fatalf("RedactedDSN leaked password: %q", got)
+	}
+}
+
+func TestRedactString(t *testing.T) {
+	in := "dial root:hunter2@tcp(db:3306)/app failed; MYSQL_ROOT_PASSWORD=hunter2 token=abc"
+	got := RedactString(in)
+	if strings.Contains(got, "hunter2") || strings.Contains(got, "abc") {
+		t.Fatalf("secrets not redacted: %q", got)
+	}
+	if !strings.Contains(got, "root:***@tcp(db:3306)") || !strings.Contains(got, "MYSQL_ROOT_PASSWORD=***") {
+		t.Fatalf("unexpected redaction: %q", got)
+	}
+}
+
+func TestRedactor_ArgPolicies(t *testing.T) {
+	query := "UPDATE users SET password = ?, name = ? WHERE id = ?"
+	args := []any{"s3cret", "alice", 42}
+
+	keep, _ := NewRedactor(RedactionConfig{Args: ArgPolicyKeep, ColumnPattern: DefaultSensitiveColumns})
+	got := keep.Args(query, args)
+	if got[0] != "[REDACTED]" || got[1] != "alice" || got[2] != 42 {
+		t.Fatalf("keep: %v", got)
+	}
+
+	all, _ := NewRedactor(RedactionConfig{Args: ArgPolicyAll})
+	got = all.Args(query, append(args, []byte("xyz"), nil))
+	if got[0] != "[string]" || got[2] != "[int]" || got[3] != "[bytes:3]" || got[4] != nil {
+		t.Fatalf("all: %v", got)
+	}
+
+	types, _ := NewRedactor(RedactionConfig{Args: ArgPolicyTypes, Types: []string{"string"}})
+	got = types.Args(query, args)
+	if got[0] != "[string]" || got[2] != 42 {
+		t.Fatalf("types: %v", got)
+	}
+
+	hash, _ := NewRedactor(RedactionConfig{Args: ArgPolicyHash, HashKey: "k"})
+	a := hash.Args(query, args)
+	b := hash.Args(query, args)
+	if a[1] != b[1] || a[1] == "alice" || !strings.HasPrefix(a[1].(string), "hmac:") {
+		t.Fatalf("hash: %v %v", a, b)
+	}
+
+	if _, err := NewRedactor(RedactionConfig{Args: "some"}); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+	if _, err := NewRedactor(RedactionConfig{ColumnPattern: "("}); err == nil {
+		t.Fatalf("expected error for invalid column pattern")
+	}
+}
+
+func TestPlaceholderColumns(t *testing.T) {
+	cases := map[string][]string{
+		"SELECT * FROM u WHERE u.email = ? AND api_key IN (?, ?)":        {"email", "api_key", "api_key"},
+		"INSERT INTO u (`name`, password) VALUES (?, ?), (?, ?)":         {"name", "password", "name", "password"},
+		"SELECT * FROM u WHERE name LIKE ? LIMIT ?":                      {"name", ""},
+		"SELECT * FROM u WHERE token = '?' AND created_at >= ? -- x = ?": {"created_at"},
+	}
+	for q, want := range cases {
+		got := placeholderColumns(q)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("%s: got %v want %v", q, got, want)
+		}
+	}
+}
+
+func TestRedactor_MaskLiterals(t *testing.T) {
+	r, _ := NewRedactor(RedactionConfig{MaskLiterals: true})
+	got := r.Query("SELECT * FROM t1 WHERE `col2` = 'it''s' AND n > 42 AND x = ?")
+	want := "SELECT * FROM t1 WHERE `col2` = ? AND n > ? AND x = ?"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if q := DefaultRedactor().Query("SELECT 1"); q != "SELECT 1" {
+		t.Fatalf("default redactor must keep query text: %q", q)
+	}
+}
+
+func TestRedactError_KeepsChain(t *testing.T) {
+	base := &mysql.MySQLError{Number: 1045, Message: "denied"}
+	err := redactError(errors.Join(errors.New("dsn u:pw@tcp(h:3306)/d"), base))
+	if strings.Contains(err.Error(), ":pw@") {
+		t.Fatalf("not redacted: %v", err)
+	}
+	var me *mysql.MySQLError
+	if !errors.As(err, &me) || me.Number != 1045 {
+		t.Fatalf("error chain lost: %v", err)
+	}
+	plain := errors.New("boom")
+	if redactError(plain) != plain {
+		t.Fatalf("errors without secrets should be returned as is")
+	}
+}
+
+func TestSlowQueryRecorder_Redaction(t *testing.T) {
+	storage := NewMemorySlowQueryStorage(10)
+	cfg := DefaultSlowQueryConfig()
+	cfg.Enabled = true
+	cfg.Threshold = time.Millisecond
+	cfg.SanitizeArgs = false
+	rec := NewSlowQueryRecorder(cfg, storage)
+
+	long := strings.Repeat("x", 80)
+	query := "UPDATE users SET password = ?, bio = ? WHERE id = 7"
+	if err := rec.Record(context.Background(), query, []any{"s3cret", long}, time.Second, errors.New("u:pw@tcp(h)/d")); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	records, _ := rec.GetRecords(context.Background(), SlowQueryFilter{})
+	if len(records) != 1 {
+		t.Fatalf("records=%d", len(records))
+	}
+	r := records[0]
+	if r.Args[0] != "[REDACTED]" || r.Args[1] != long {
+		t.Fatalf("column redaction without sanitize: %v", r.Args)
+	}
+	if strings.Contains(r.Error, "pw") {
+		t.Fatalf("error not redacted: %q", r.Error)
+	}
+
+	// sanitized long strings no longer leak a prefix of the value
+	cfg.SanitizeArgs = true
+	rec.UpdateConfig(cfg)
+	rec.SetRedactor(mustRedactor(t, RedactionConfig{Args: ArgPolicyAll, MaskLiterals: true}))
+	_ = rec.Clear(context.Background())
+	_ = rec.Record(context.Background(), query, []any{"s3cret", long}, time.Second, nil)
+	records, _ = rec.GetRecords(context.Background(), SlowQueryFilter{})
+	r = records[0]
+	if r.Args[1] != "[string]" || strings.Contains(r.Query, "7") {
+		t.Fatalf("sanitized record: %+v", r)
+	}
+}
+
+func mustRedactor(t *testing.T, cfg RedactionConfig) *Redactor {
+	t.Helper()
+	r, err := NewRedactor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestApplyConfigMap_Redaction(t *testing.T) {
+	m, err := decodeConfigFile([]byte(`
+redaction:
+  args: types
+  types: [string, time.Time]
+  mask_literals: true
+`), ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if errs := applyConfigMap(&cfg, "", m); len(errs) > 0 {
+		t.Fatalf("applyConfigMap: %v", errs)
+	}
+	r := cfg.Redaction
+	if r == nil || r.Args != ArgPolicyTypes || len(r.Types) != 2 || !r.MaskLiterals {
+		t.Fatalf("redaction=%+v", r)
+	}
+	if r.ColumnPattern != DefaultSensitiveColumns {
+		t.Fatalf("default column pattern lost: %q", r.ColumnPattern)
+	}
+	if err := ValidateConfig(Config{DSN: "u:p@tcp(db:3306)/app", Redaction: &RedactionConfig{Args: "nope"}}); err == nil {
+		t.Fatalf("expected redaction validation error")
+	}
+}
@@ -0,0 +1,323 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultPoolName is the registry name of the pool configured by the
+// un-prefixed YGGGO_MYSQL_* variables or the "default" file entry.
+const DefaultPoolName = "default"
+
+// ErrPoolNotFound is returned by Registry.Get for unknown pool names.
+var ErrPoolNotFound = errors.New("pool not found")
+
+// Registry manages several named pools, for services that talk to more
+// than one MySQL database.
+//
+// Pools are described up front (from environment prefixes, a config file
+// or Register) and opened lazily on first Get. Each pool keeps its own
+// configuration and DSN (see Pool.DSN).
+//
+// Example:
+//
+//	// YGGGO_MYSQL_ORDERS_HOST=orders-db YGGGO_MYSQL_ORDERS_DATABASE=orders
+//	// YGGGO_MYSQL_USERS_HOST=users-db  YGGGO_MYSQL_USERS_DATABASE=users
+//	reg, err := NewRegistryFromEnv()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer reg.CloseAll()
+//
+//	orders, err := reg.Get("orders")
+//
+// Thread Safety: Registry is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*registryEntry
+}
+
+// registryEntry holds the configuration of one named pool and, once
+// opened, the pool itself.
+type registryEntry struct {
+	cfg  Config
+	mu   sync.Mutex // serializes opening
+	pool *Pool
+}
+
+// NewRegistry creates an empty registry. Add pools with Register.
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]*registryEntry)}
+}
+
+// NewRegistryFromEnv creates a registry from environment variables.
+//
+// A pool named NAME is discovered from YGGGO_MYSQL_<NAME>_HOST,
+// YGGGO_MYSQL_<NAME>_HOSTS or YGGGO_MYSQL_<NAME>_DSN, and configured from
+// every YGGGO_MYSQL_<NAME>_<KEY> variable understood by LoadConfig
+// (for example YGGGO_MYSQL_ORDERS_POOL_MAX_OPEN). Names are lower-cased.
+// The plain YGGGO_MYSQL_HOST/DSN variables define DefaultPoolName.
+//
+// Every pool configuration is validated; all problems are returned together.
+func NewRegistryFromEnv() (*Registry, error) {
+	r := NewRegistry()
+	var errs []error
+	for _, name := range discoverEnvPoolNames(os.Environ()) {
+		var cfg Config
+		if err := applyEnvPrefix(&cfg, envPrefixFor(name)); err != nil {
+			errs = append(errs, fmt.Errorf("pool %q: %w", name, err))
+		}
+		if err := ValidateConfig(cfg); err != nil {
+			errs = append(errs, fmt.Errorf("pool %q: %w", name, err))
+		}
+		r.entries[name] = &registryEntry{cfg: cfg}
+	}
+	return r, errors.Join(errs...)
+}
+
+// NewRegistryFromFile creates a registry from a YAML, JSON or TOML file
+// with a top-level "pools" section mapping names to pool settings in the
+// LoadConfig format:
+//
+//	pools:
+//	  orders:
+//	    host: orders-db
+//	    database: orders
+//	  users:
+//	    dsn: ${USERS_DSN}
+//
+// YGGGO_MYSQL_<NAME>_<KEY> variables override the file per pool.
+func NewRegistryFromFile(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	m, err := decodeConfigFile(data, filepath.Ext(path))
+	if err != nil {
+		return nil, err
+	}
+	pools, ok := m["pools"].(map[string]any)
+	if !ok {
+		return nil, errors.New(`config file has no "pools" section`)
+	}
+
+	r := NewRegistry()
+	var errs []error
+	for key, v := range pools {
+		name := strings.ToLower(key)
+		section, ok := v.(map[string]any)
+		if !ok {
+			errs = append(errs, fmt.Errorf("pool %q: expected a section", name))
+			continue
+		}
+		var cfg Config
+		for _, err := range applyConfigMap(&cfg, "", section) {
+			errs = append(errs, fmt.Errorf("pool %q: %w", name, err))
+		}
+		if err := applyEnvPrefix(&cfg, envPrefixFor(name)); err != nil {
+			errs = append(errs, fmt.Errorf("pool %q: %w", name, err))
+		}
+		if err := ValidateConfig(cfg); err != nil {
+			errs = append(errs, fmt.Errorf("pool %q: %w", name, err))
+		}
+		r.entries[name] = &registryEntry{cfg: cfg}
+	}
+	return r, errors.Join(errs...)
+}
+
+// envPrefixFor returns the environment prefix of the pool called name.
+func envPrefixFor(name string) string {
+	if name == DefaultPoolName {
+		return envPrefix
+	}
+	return envPrefix + strings.ToUpper(name) + "_"
+}
+
+// discoverEnvPoolNames returns the sorted pool names found in environ.
+func discoverEnvPoolNames(environ []string) []string {
+	seen := map[string]bool{}
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(key, envPrefix)
+		if !ok {
+			continue
+		}
+		switch rest {
+		case "HOST", "HOSTS", "DSN":
+			seen[DefaultPoolName] = true
+			continue
+		}
+		for _, suffix := range []string{"_HOST", "_HOSTS", "_DSN"} {
+			if name, ok := strings.CutSuffix(rest, suffix); ok && name != "" {
+				seen[strings.ToLower(name)] = true
+				break
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register adds or replaces the configuration of the pool called name.
+// The configuration is used as is; environment variables are not applied.
+// It fails if a pool with that name is already open.
+func (r *Registry) Register(name string, cfg Config) error {
+	if name == "" {
+		return errors.New("pool name cannot be empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[name]; ok {
+		e.mu.Lock()
+		open := e.pool != nil
+		e.mu.Unlock()
+		if open {
+			return fmt.Errorf("pool %q is already open", name)
+		}
+	}
+	r.entries[name] = &registryEntry{cfg: cfg}
+	return nil
+}
+
+// Names returns the names of all configured pools, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config returns the configuration of the pool called name.
+func (r *Registry) Config(name string) (Config, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return Config{}, false
+	}
+	return e.cfg, true
+}
+
+// Get returns the pool called name, opening it on first use.
+func (r *Registry) Get(name string) (*Pool, error) {
+	return r.GetContext(context.Background(), name)
+}
+
+// GetContext is like Get but uses ctx when the pool has to be opened.
+func (r *Registry) GetContext(ctx context.Context, name string) (*Pool, error) {
+	r.mu.RLock()
+	e, ok := r.entries[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrPoolNotFound, name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pool != nil {
+		return e.pool, nil
+	}
+	p, err := newPool(ctx, e.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("pool %q: %w", name, err)
+	}
+	e.pool = p
+	return p, nil
+}
+
+// openPools returns the pools that have been opened, by name.
+func (r *Registry) openPools() map[string]*Pool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]*Pool, len(r.entries))
+	for name, e := range r.entries {
+		e.mu.Lock()
+		if e.pool != nil {
+			out[name] = e.pool
+		}
+		e.mu.Unlock()
+	}
+	return out
+}
+
+// CloseAll closes every open pool. Pools can be reopened with Get.
+// All close errors are returned together.
+func (r *Registry) CloseAll() error {
+	r.mu.RLock()
+	entries := make(map[string]*registryEntry, len(r.entries))
+	for name, e := range r.entries {
+		entries[name] = e
+	}
+	r.mu.RUnlock()
+
+	var errs []error
+	for name, e := range entries {
+		e.mu.Lock()
+		if e.pool != nil {
+			if err := e.pool.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("pool %q: %w", name, err))
+			}
+			e.pool = nil
+		}
+		e.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// RegistryHealth aggregates the health of all open pools.
+type RegistryHealth struct {
+	Healthy bool                     `json:"healthy"`
+	Pools   map[string]*HealthStatus `json:"pools"`
+}
+
+// HealthCheck runs Pool.HealthCheck on every open pool. The registry is
+// healthy only if all of them are. Pools that were never opened are skipped.
+func (r *Registry) HealthCheck(ctx context.Context) *RegistryHealth {
+	pools := r.openPools()
+	result := &RegistryHealth{Healthy: true, Pools: make(map[string]*HealthStatus, len(pools))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, p := range pools {
+		wg.Add(1)
+		go func(name string, p *Pool) {
+			defer wg.Done()
+			status, err := p.HealthCheck(ctx)
+			if err != nil {
+				status = &HealthStatus{Errors: []HealthError{{Type: "health_check", Message: err.Error()}}}
+			}
+			mu.Lock()
+			result.Pools[name] = status
+			if !status.Healthy {
+				result.Healthy = false
+			}
+			mu.Unlock()
+		}(name, p)
+	}
+	wg.Wait()
+	return result
+}
+
+// Stats returns GetPoolStats for every open pool, by name.
+func (r *Registry) Stats() map[string]PoolStats {
+	pools := r.openPools()
+	out := make(map[string]PoolStats, len(pools))
+	for name, p := range pools {
+		out[name] = p.GetPoolStats()
+	}
+	return out
+}
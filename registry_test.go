@@ -0,0 +1,150 @@
+package ygggo_mysql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoverEnvPoolNames(t *testing.T) {
+	environ := []string{
+		"YGGGO_MYSQL_HOST=localhost",
+		"YGGGO_MYSQL_ORDERS_HOST=orders-db",
+		"YGGGO_MYSQL_ORDERS_DATABASE=orders",
+		"YGGGO_MYSQL_ORDER_ITEMS_DSN=u:p@tcp(items:3306)/items",
+		"YGGGO_MYSQL_USERS_HOSTS=u1,u2",
+		"YGGGO_MYSQL_POOL_MAX_OPEN=10",
+		"PATH=/usr/bin",
+	}
+	got := discoverEnvPoolNames(environ)
+	want := []string{"default", "order_items", "orders", "users"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+}
+
+func TestNewRegistryFromEnv_PerPoolSettings(t *testing.T) {
+	t.Setenv("YGGGO_MYSQL_ORDERS_HOST", "orders-db")
+	t.Setenv("YGGGO_MYSQL_ORDERS_DATABASE", "orders")
+	t.Setenv("YGGGO_MYSQL_ORDERS_POOL_MAX_OPEN", "40")
+	t.Setenv("YGGGO_MYSQL_USERS_DSN", "u:p@tcp(users-db:3306)/users")
+	t.Setenv("YGGGO_MYSQL_USERS_RETRY_MAX_ATTEMPTS", "4")
+
+	reg, err := NewRegistryFromEnv()
+	if err != nil {
+		t.Fatalf("NewRegistryFromEnv: %v", err)
+	}
+	orders, ok := reg.Config("orders")
+	if !ok || orders.Host != "orders-db" || orders.Database != "orders" || orders.Pool.MaxOpen != 40 {
+		t.Fatalf("orders config %+v", orders)
+	}
+	users, ok := reg.Config("users")
+	if !ok || users.DSN != "u:p@tcp(users-db:3306)/users" || users.Retry.MaxAttempts != 4 {
+		t.Fatalf("users config %+v", users)
+	}
+	if users.Host != "" || users.Pool.MaxOpen != 0 {
+		t.Fatalf("settings leaked between pools: %+v", users)
+	}
+}
+
+func TestNewRegistryFromFile(t *testing.T) {
+	t.Setenv("YGGGO_MYSQL_USERS_POOL_MAX_OPEN", "12")
+	path := writeConfigFile(t, "pools.yaml", `
+pools:
+  orders:
+    host: orders-db
+    database: orders
+    pool:
+      max_open: 20
+  users:
+    dsn: u:p@tcp(users-db:3306)/users
+`)
+	reg, err := NewRegistryFromFile(path)
+	if err != nil {
+		t.Fatalf("NewRegistryFromFile: %v", err)
+	}
+	names := reg.Names()
+	if len(names) != 2 || names[0] != "orders" || names[1] != "users" {
+		t.Fatalf("names=%v", names)
+	}
+	orders, _ := reg.Config("orders")
+	if orders.Pool.MaxOpen != 20 {
+		t.Fatalf("orders pool=%+v", orders.Pool)
+	}
+	users, _ := reg.Config("users")
+	if users.Pool.MaxOpen != 12 {
+		t.Fatalf("env overlay not applied to users: %+v", users.Pool)
+	}
+}
+
+func TestNewRegistryFromFile_CollectsErrors(t *testing.T) {
+	path := writeConfigFile(t, "pools.yaml", `
+pools:
+  orders:
+    host: orders-db
+  users:
+    dsn: u:p@tcp(users-db:3306)/users
+    colour: red
+`)
+	_, err := NewRegistryFromFile(path)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	msg := err.Error()
+	if !containsAll(msg, `pool "orders"`, "database name is required", `pool "users"`, `unknown config key "colour"`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func containsAll(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if !strings.Contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRegistry_GetUnknownAndFailedOpen(t *testing.T) {
+	reg := NewRegistry()
+	if _, err := reg.Get("missing"); !errors.Is(err, ErrPoolNotFound) {
+		t.Fatalf("expected ErrPoolNotFound, got %v", err)
+	}
+	if err := reg.Register("", Config{}); err == nil {
+		t.Fatalf("expected error for empty name")
+	}
+	cfg := Config{Host: "127.0.0.1", Port: 1, Username: "u", Pool: PoolConfig{ConnMaxLifetime: time.Minute}}
+	if err := reg.Register("broken", cfg); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := reg.Get("broken"); err == nil {
+		t.Fatalf("expected open error for unreachable host")
+	}
+	if stats := reg.Stats(); len(stats) != 0 {
+		t.Fatalf("failed pools must not be reported: %v", stats)
+	}
+	if h := reg.HealthCheck(t.Context()); !h.Healthy || len(h.Pools) != 0 {
+		t.Fatalf("empty registry should be healthy: %+v", h)
+	}
+	if err := reg.CloseAll(); err != nil {
+		t.Fatalf("CloseAll: %v", err)
+	}
+}
+
+func TestPool_DSNIsPerPool(t *testing.T) {
+	a := &Pool{dsn: "a@tcp(a:3306)/a"}
+	b := &Pool{dsn: "b@tcp(b:3306)/b"}
+	if a.DSN() == b.DSN() {
+		t.Fatalf("pools must keep their own DSN")
+	}
+	var nilPool *Pool
+	if nilPool.DSN() != "" {
+		t.Fatalf("nil pool DSN should be empty")
+	}
+}
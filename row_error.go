@@ -0,0 +1,50 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+)
+
+// errRowDB runs queries on connections that fail with the error carried
+// by the context. database/sql offers no other way to build a *sql.Row
+// holding an error.
+var (
+	errRowOnce sync.Once
+	errRowDB   *sql.DB
+)
+
+type errRowKey struct{}
+
+// errRow returns a *sql.Row whose Scan returns err, for errors found
+// before a query reaches the server.
+func errRow(ctx context.Context, err error) *sql.Row {
+	errRowOnce.Do(func() { errRowDB = sql.OpenDB(errRowConnector{}) })
+	ctx = context.WithValue(context.WithoutCancel(ctx), errRowKey{}, err)
+	return errRowDB.QueryRowContext(ctx, "")
+}
+
+type errRowConnector struct{}
+
+func (errRowConnector) Connect(context.Context) (driver.Conn, error) { return errRowConn{}, nil }
+func (errRowConnector) Driver() driver.Driver                        { return errRowDriver{} }
+
+type errRowDriver struct{}
+
+func (errRowDriver) Open(string) (driver.Conn, error) { return errRowConn{}, nil }
+
+// errRowConn fails every query with the error from its context.
+type errRowConn struct{}
+
+func (errRowConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (errRowConn) Close() error                        { return nil }
+func (errRowConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (errRowConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err, ok := ctx.Value(errRowKey{}).(error); ok {
+		return nil, err
+	}
+	return nil, errors.New("no error for row")
+}
@@ -0,0 +1,450 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerStatus is a snapshot of SHOW GLOBAL STATUS. The common counters
+// are typed; every value is kept in Raw.
+type ServerStatus struct {
+	CollectedAt time.Time     `json:"collected_at"`
+	Uptime      time.Duration `json:"uptime"`
+
+	Questions   int64 `json:"questions"`    // Statements sent by clients
+	Queries     int64 `json:"queries"`      // Statements including those run by stored programs
+	SlowQueries int64 `json:"slow_queries"` // Statements above long_query_time
+
+	ComSelect   int64 `json:"com_select"`
+	ComInsert   int64 `json:"com_insert"`
+	ComUpdate   int64 `json:"com_update"`
+	ComDelete   int64 `json:"com_delete"`
+	ComReplace  int64 `json:"com_replace"`
+	ComBegin    int64 `json:"com_begin"`
+	ComCommit   int64 `json:"com_commit"`
+	ComRollback int64 `json:"com_rollback"`
+
+	// Commands holds every Com_* counter, keyed without the prefix
+	Commands map[string]int64 `json:"commands,omitempty"`
+
+	ThreadsConnected int64 `json:"threads_connected"`
+	ThreadsRunning   int64 `json:"threads_running"`
+	ThreadsCreated   int64 `json:"threads_created"`
+	ThreadsCached    int64 `json:"threads_cached"`
+
+	InnodbRowsRead     int64 `json:"innodb_rows_read"`
+	InnodbRowsInserted int64 `json:"innodb_rows_inserted"`
+	InnodbRowsUpdated  int64 `json:"innodb_rows_updated"`
+	InnodbRowsDeleted  int64 `json:"innodb_rows_deleted"`
+
+	BytesReceived int64 `json:"bytes_received"`
+	BytesSent     int64 `json:"bytes_sent"`
+
+	Raw map[string]string `json:"raw"`
+}
+
+// fields maps status names to the typed counters of s.
+func (s *ServerStatus) fields() map[string]*int64 {
+	return map[string]*int64{
+		"Questions":            &s.Questions,
+		"Queries":              &s.Queries,
+		"Slow_queries":         &s.SlowQueries,
+		"Com_select":           &s.ComSelect,
+		"Com_insert":           &s.ComInsert,
+		"Com_update":           &s.ComUpdate,
+		"Com_delete":           &s.ComDelete,
+		"Com_replace":          &s.ComReplace,
+		"Com_begin":            &s.ComBegin,
+		"Com_commit":           &s.ComCommit,
+		"Com_rollback":         &s.ComRollback,
+		"Threads_connected":    &s.ThreadsConnected,
+		"Threads_running":      &s.ThreadsRunning,
+		"Threads_created":      &s.ThreadsCreated,
+		"Threads_cached":       &s.ThreadsCached,
+		"Innodb_rows_read":     &s.InnodbRowsRead,
+		"Innodb_rows_inserted": &s.InnodbRowsInserted,
+		"Innodb_rows_updated":  &s.InnodbRowsUpdated,
+		"Innodb_rows_deleted":  &s.InnodbRowsDeleted,
+		"Bytes_received":       &s.BytesReceived,
+		"Bytes_sent":           &s.BytesSent,
+	}
+}
+
+// NewServerStatus builds a ServerStatus from SHOW GLOBAL STATUS values.
+func NewServerStatus(raw map[string]string, collectedAt time.Time) *ServerStatus {
+	s := &ServerStatus{CollectedAt: collectedAt, Raw: raw, Commands: make(map[string]int64)}
+	fields := s.fields()
+	for name, v := range raw {
+		if f, ok := fields[name]; ok {
+			*f = serverInt(v)
+		}
+		if cmd, ok := strings.CutPrefix(name, "Com_"); ok {
+			s.Commands[cmd] = serverInt(v)
+		}
+	}
+	s.Uptime = time.Duration(serverInt(raw["Uptime"])) * time.Second
+	return s
+}
+
+// ServerStatus reads SHOW GLOBAL STATUS.
+func (p *Pool) ServerStatus(ctx context.Context) (*ServerStatus, error) {
+	db := p.getDB()
+	if db == nil {
+		return nil, fmt.Errorf("pool or database is nil")
+	}
+	ctx = internalContext(ctx)
+	raw, err := showGlobal(ctx, db, "STATUS")
+	if err != nil {
+		return nil, err
+	}
+	return NewServerStatus(raw, time.Now()), nil
+}
+
+// ServerVariables is a snapshot of SHOW GLOBAL VARIABLES. The common
+// settings are typed; every value is kept in Raw.
+type ServerVariables struct {
+	Version              string        `json:"version"`
+	VersionComment       string        `json:"version_comment"`
+	ServerID             int64         `json:"server_id"`
+	ReadOnly             bool          `json:"read_only"`
+	SuperReadOnly        bool          `json:"super_read_only"`
+	MaxConnections       int64         `json:"max_connections"`
+	MaxAllowedPacket     int64         `json:"max_allowed_packet"`
+	InnodbBufferPoolSize int64         `json:"innodb_buffer_pool_size"`
+	LongQueryTime        time.Duration `json:"long_query_time"`
+	SlowQueryLog         bool          `json:"slow_query_log"`
+	WaitTimeout          time.Duration `json:"wait_timeout"`
+	TransactionIsolation string        `json:"transaction_isolation"`
+	SQLMode              string        `json:"sql_mode"`
+	TimeZone             string        `json:"time_zone"`
+	CharacterSetServer   string        `json:"character_set_server"`
+	CollationServer      string        `json:"collation_server"`
+
+	Raw map[string]string `json:"raw"`
+}
+
+// NewServerVariables builds ServerVariables from SHOW GLOBAL VARIABLES
+// values.
+func NewServerVariables(raw map[string]string) *ServerVariables {
+	v := &ServerVariables{
+		Version:              raw["version"],
+		VersionComment:       raw["version_comment"],
+		ServerID:             serverInt(raw["server_id"]),
+		ReadOnly:             serverFlag(raw["read_only"]),
+		SuperReadOnly:        serverFlag(raw["super_read_only"]),
+		MaxConnections:       serverInt(raw["max_connections"]),
+		MaxAllowedPacket:     serverInt(raw["max_allowed_packet"]),
+		InnodbBufferPoolSize: serverInt(raw["innodb_buffer_pool_size"]),
+		SlowQueryLog:         serverFlag(raw["slow_query_log"]),
+		WaitTimeout:          time.Duration(serverInt(raw["wait_timeout"])) * time.Second,
+		TransactionIsolation: raw["transaction_isolation"],
+		SQLMode:              raw["sql_mode"],
+		TimeZone:             raw["time_zone"],
+		CharacterSetServer:   raw["character_set_server"],
+		CollationServer:      raw["collation_server"],
+		Raw:                  raw,
+	}
+	if v.TransactionIsolation == "" {
+		v.TransactionIsolation = raw["tx_isolation"] // before MySQL 8.0
+	}
+	if secs, err := strconv.ParseFloat(raw["long_query_time"], 64); err == nil {
+		v.LongQueryTime = time.Duration(secs * float64(time.Second))
+	}
+	return v
+}
+
+// ServerVariables reads SHOW GLOBAL VARIABLES.
+func (p *Pool) ServerVariables(ctx context.Context) (*ServerVariables, error) {
+	db := p.getDB()
+	if db == nil {
+		return nil, fmt.Errorf("pool or database is nil")
+	}
+	ctx = internalContext(ctx)
+	raw, err := showGlobal(ctx, db, "VARIABLES")
+	if err != nil {
+		return nil, err
+	}
+	return NewServerVariables(raw), nil
+}
+
+// StatusRates are the per-second rates of the counters of two ServerStatus
+// snapshots. Threads connected and running are gauges and hold the value of
+// the later snapshot.
+type StatusRates struct {
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Interval time.Duration `json:"interval"`
+
+	Questions   float64 `json:"questions"`
+	Queries     float64 `json:"queries"`
+	SlowQueries float64 `json:"slow_queries"`
+
+	ComSelect   float64 `json:"com_select"`
+	ComInsert   float64 `json:"com_insert"`
+	ComUpdate   float64 `json:"com_update"`
+	ComDelete   float64 `json:"com_delete"`
+	ComReplace  float64 `json:"com_replace"`
+	ComBegin    float64 `json:"com_begin"`
+	ComCommit   float64 `json:"com_commit"`
+	ComRollback float64 `json:"com_rollback"`
+
+	ThreadsConnected int64   `json:"threads_connected"`
+	ThreadsRunning   int64   `json:"threads_running"`
+	ThreadsCreated   float64 `json:"threads_created"`
+
+	InnodbRowsRead     float64 `json:"innodb_rows_read"`
+	InnodbRowsInserted float64 `json:"innodb_rows_inserted"`
+	InnodbRowsUpdated  float64 `json:"innodb_rows_updated"`
+	InnodbRowsDeleted  float64 `json:"innodb_rows_deleted"`
+
+	BytesReceived float64 `json:"bytes_received"`
+	BytesSent     float64 `json:"bytes_sent"`
+
+	// Raw holds the rate of every numeric value present in both snapshots
+	Raw map[string]float64 `json:"raw,omitempty"`
+}
+
+// ErrServerRestarted is returned by StatusDiff when the server restarted
+// between the snapshots, resetting its counters.
+var ErrServerRestarted = errors.New("server restarted between status snapshots")
+
+// StatusDiff computes the per-second rates between snapshots a and b,
+// where a was taken first.
+func StatusDiff(a, b *ServerStatus) (*StatusRates, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("status snapshots must not be nil")
+	}
+	if a.Uptime > 0 && b.Uptime > 0 && b.Uptime < a.Uptime {
+		return nil, ErrServerRestarted
+	}
+	interval := b.CollectedAt.Sub(a.CollectedAt)
+	if interval <= 0 {
+		interval = b.Uptime - a.Uptime
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("snapshots must be taken at different times, got interval %v", interval)
+	}
+	secs := interval.Seconds()
+	rate := func(x, y int64) float64 { return float64(y-x) / secs }
+
+	r := &StatusRates{
+		From:     a.CollectedAt,
+		To:       b.CollectedAt,
+		Interval: interval,
+
+		Questions:   rate(a.Questions, b.Questions),
+		Queries:     rate(a.Queries, b.Queries),
+		SlowQueries: rate(a.SlowQueries, b.SlowQueries),
+
+		ComSelect:   rate(a.ComSelect, b.ComSelect),
+		ComInsert:   rate(a.ComInsert, b.ComInsert),
+		ComUpdate:   rate(a.ComUpdate, b.ComUpdate),
+		ComDelete:   rate(a.ComDelete, b.ComDelete),
+		ComReplace:  rate(a.ComReplace, b.ComReplace),
+		ComBegin:    rate(a.ComBegin, b.ComBegin),
+		ComCommit:   rate(a.ComCommit, b.ComCommit),
+		ComRollback: rate(a.ComRollback, b.ComRollback),
+
+		ThreadsConnected: b.ThreadsConnected,
+		ThreadsRunning:   b.ThreadsRunning,
+		ThreadsCreated:   rate(a.ThreadsCreated, b.ThreadsCreated),
+
+		InnodbRowsRead:     rate(a.InnodbRowsRead, b.InnodbRowsRead),
+		InnodbRowsInserted: rate(a.InnodbRowsInserted, b.InnodbRowsInserted),
+		InnodbRowsUpdated:  rate(a.InnodbRowsUpdated, b.InnodbRowsUpdated),
+		InnodbRowsDeleted:  rate(a.InnodbRowsDeleted, b.InnodbRowsDeleted),
+
+		BytesReceived: rate(a.BytesReceived, b.BytesReceived),
+		BytesSent:     rate(a.BytesSent, b.BytesSent),
+
+		Raw: make(map[string]float64),
+	}
+	for name, bv := range b.Raw {
+		y, err := strconv.ParseInt(bv, 10, 64)
+		if err != nil {
+			continue
+		}
+		x, err := strconv.ParseInt(a.Raw[name], 10, 64)
+		if err != nil {
+			continue
+		}
+		r.Raw[name] = rate(x, y)
+	}
+	return r, nil
+}
+
+// ServerStatusSamplerConfig configures a ServerStatusSampler.
+type ServerStatusSamplerConfig struct {
+	Interval time.Duration `json:"interval"` // Time between snapshots; defaults to 10s
+	Capacity int           `json:"capacity"` // Snapshots kept; defaults to 360 (an hour at 10s)
+}
+
+// ServerStatusSampler takes ServerStatus snapshots in the background and
+// keeps the most recent ones in a ring buffer, for dashboards and to
+// attach server rates to benchmark results.
+type ServerStatusSampler struct {
+	pool   *Pool
+	config ServerStatusSamplerConfig
+
+	mu      sync.RWMutex
+	ring    []*ServerStatus
+	next    int // index of the next write
+	full    bool
+	lastErr error
+
+	stopChan     chan struct{}
+	done         chan struct{}
+	running      bool
+	runningMutex sync.Mutex
+}
+
+// NewServerStatusSampler creates a sampler for pool. Call Start to begin
+// sampling.
+func NewServerStatusSampler(pool *Pool, config ServerStatusSamplerConfig) *ServerStatusSampler {
+	if config.Interval <= 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 360
+	}
+	return &ServerStatusSampler{
+		pool:   pool,
+		config: config,
+		ring:   make([]*ServerStatus, config.Capacity),
+	}
+}
+
+// Start takes a snapshot and begins sampling every Interval.
+func (s *ServerStatusSampler) Start() error {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+	if s.running {
+		return fmt.Errorf("server status sampler is already running")
+	}
+	s.stopChan = make(chan struct{})
+	s.done = make(chan struct{})
+	s.running = true
+	go s.loop(s.stopChan, s.done)
+	return nil
+}
+
+// Stop stops sampling and waits for an in-flight snapshot to finish.
+func (s *ServerStatusSampler) Stop() error {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+	if !s.running {
+		return fmt.Errorf("server status sampler is not running")
+	}
+	close(s.stopChan)
+	<-s.done
+	s.running = false
+	return nil
+}
+
+// IsRunning returns whether the sampler is running.
+func (s *ServerStatusSampler) IsRunning() bool {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+	return s.running
+}
+
+func (s *ServerStatusSampler) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	s.Sample(context.Background())
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Sample(context.Background())
+		}
+	}
+}
+
+// Sample takes a snapshot now and adds it to the buffer.
+func (s *ServerStatusSampler) Sample(ctx context.Context) (*ServerStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Interval)
+	defer cancel()
+	status, err := s.pool.ServerStatus(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+	if err != nil {
+		return nil, err
+	}
+	s.ring[s.next] = status
+	s.next = (s.next + 1) % len(s.ring)
+	if s.next == 0 {
+		s.full = true
+	}
+	return status, nil
+}
+
+// LastError returns the error of the latest snapshot attempt, if it failed.
+func (s *ServerStatusSampler) LastError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastErr
+}
+
+// Snapshots returns the buffered snapshots, oldest first.
+func (s *ServerStatusSampler) Snapshots() []*ServerStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.full {
+		return append([]*ServerStatus(nil), s.ring[:s.next]...)
+	}
+	out := make([]*ServerStatus, 0, len(s.ring))
+	out = append(out, s.ring[s.next:]...)
+	return append(out, s.ring[:s.next]...)
+}
+
+// Latest returns the most recent snapshot, or nil.
+func (s *ServerStatusSampler) Latest() *ServerStatus {
+	snaps := s.Snapshots()
+	if len(snaps) == 0 {
+		return nil
+	}
+	return snaps[len(snaps)-1]
+}
+
+// Rates returns the rates between consecutive snapshots, oldest first.
+// Intervals spanning a server restart are skipped.
+func (s *ServerStatusSampler) Rates() []*StatusRates {
+	snaps := s.Snapshots()
+	var out []*StatusRates
+	for i := 1; i < len(snaps); i++ {
+		if r, err := StatusDiff(snaps[i-1], snaps[i]); err == nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// RatesBetween returns the rates between the first and last snapshots
+// taken within [from, to].
+func (s *ServerStatusSampler) RatesBetween(from, to time.Time) (*StatusRates, error) {
+	var first, last *ServerStatus
+	for _, snap := range s.Snapshots() {
+		if snap.CollectedAt.Before(from) || snap.CollectedAt.After(to) {
+			continue
+		}
+		if first == nil {
+			first = snap
+		}
+		last = snap
+	}
+	if first == nil || first == last {
+		return nil, fmt.Errorf("fewer than two snapshots between %v and %v", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return StatusDiff(first, last)
+}
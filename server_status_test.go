@@ -0,0 +1,166 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setStatusCounters answers SHOW GLOBAL STATUS with counters scaled by n.
+func setStatusCounters(s *fakeServer, n int64, uptime int64) {
+	s.setGlobals("STATUS", map[string]string{
+		"Uptime":            strconv.FormatInt(uptime, 10),
+		"Questions":         strconv.FormatInt(100*n, 10),
+		"Com_select":        strconv.FormatInt(80*n, 10),
+		"Com_show_status":   strconv.FormatInt(n, 10),
+		"Threads_connected": "12",
+		"Threads_running":   strconv.FormatInt(n, 10),
+		"Innodb_rows_read":  strconv.FormatInt(1000*n, 10),
+		"Bytes_sent":        strconv.FormatInt(4096*n, 10),
+		"Ssl_version":       "TLSv1.3",
+	})
+}
+
+func TestServerStatusAndVariables(t *testing.T) {
+	s := newFakeServer()
+	setStatusCounters(s, 2, 3600)
+	s.setGlobals("VARIABLES", map[string]string{
+		"version": "8.0.36", "max_connections": "151", "long_query_time": "0.500000",
+		"tx_isolation": "REPEATABLE-READ", "read_only": "ON", "wait_timeout": "28800",
+	})
+	p := s.pool(t)
+	ctx := context.Background()
+
+	st, err := p.ServerStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Uptime != time.Hour || st.Questions != 200 || st.ComSelect != 160 || st.ThreadsConnected != 12 || st.InnodbRowsRead != 2000 || st.BytesSent != 8192 {
+		t.Fatalf("status: %+v", st)
+	}
+	if st.Commands["show_status"] != 2 || st.Raw["Ssl_version"] != "TLSv1.3" {
+		t.Fatalf("commands %v raw %v", st.Commands, st.Raw)
+	}
+
+	vars, err := p.ServerVariables(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars.Version != "8.0.36" || vars.MaxConnections != 151 || vars.LongQueryTime != 500*time.Millisecond ||
+		vars.TransactionIsolation != "REPEATABLE-READ" || !vars.ReadOnly || vars.WaitTimeout != 8*time.Hour {
+		t.Fatalf("variables: %+v", vars)
+	}
+}
+
+func TestStatusDiff(t *testing.T) {
+	start := time.Now()
+	a := NewServerStatus(map[string]string{"Uptime": "100", "Questions": "1000", "Threads_running": "3", "Ssl_version": "TLSv1.3"}, start)
+	b := NewServerStatus(map[string]string{"Uptime": "110", "Questions": "1500", "Threads_running": "7", "Ssl_version": "TLSv1.3"}, start.Add(10*time.Second))
+
+	r, err := StatusDiff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Interval != 10*time.Second || r.Questions != 50 || r.ThreadsRunning != 7 || r.Raw["Questions"] != 50 {
+		t.Fatalf("rates: %+v", r)
+	}
+	if _, ok := r.Raw["Ssl_version"]; ok {
+		t.Fatalf("non-numeric value in raw rates: %v", r.Raw)
+	}
+
+	restarted := NewServerStatus(map[string]string{"Uptime": "5"}, start.Add(20*time.Second))
+	if _, err := StatusDiff(b, restarted); !errors.Is(err, ErrServerRestarted) {
+		t.Fatalf("expected restart error, got %v", err)
+	}
+	if _, err := StatusDiff(a, a); err == nil {
+		t.Fatal("expected an error for identical snapshots")
+	}
+}
+
+func TestServerStatusSampler_RingBuffer(t *testing.T) {
+	s := newFakeServer()
+	p := s.pool(t)
+	sampler := NewServerStatusSampler(p, ServerStatusSamplerConfig{Interval: time.Hour, Capacity: 3})
+	ctx := context.Background()
+
+	for i := int64(1); i <= 5; i++ {
+		setStatusCounters(s, i, 100*i)
+		if _, err := sampler.Sample(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snaps := sampler.Snapshots()
+	if len(snaps) != 3 || snaps[0].Questions != 300 || sampler.Latest().Questions != 500 {
+		t.Fatalf("snapshots: %d, first %+v", len(snaps), snaps[0])
+	}
+	if rates := sampler.Rates(); len(rates) != 2 {
+		t.Fatalf("rates: %+v", rates)
+	}
+	if _, err := sampler.RatesBetween(snaps[0].CollectedAt, snaps[2].CollectedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	s.set("SHOW GLOBAL STATUS", serverResult{err: errors.New("gone away")})
+	if _, err := sampler.Sample(ctx); err == nil || sampler.LastError() == nil || len(sampler.Snapshots()) != 3 {
+		t.Fatalf("failed sample: %v", err)
+	}
+}
+
+func TestServerStatusSampler_StartStop(t *testing.T) {
+	s := newFakeServer()
+	setStatusCounters(s, 1, 100)
+	sampler := NewServerStatusSampler(s.pool(t), ServerStatusSamplerConfig{Interval: 10 * time.Millisecond})
+	if err := sampler.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sampler.Start(); err == nil {
+		t.Fatal("expected an error starting twice")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sampler.Snapshots()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := sampler.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(sampler.Snapshots()); n < 2 || sampler.IsRunning() {
+		t.Fatalf("sampled %d snapshots", n)
+	}
+}
+
+// funcBenchmarkTest runs run for every operation.
+type funcBenchmarkTest struct{ run func() }
+
+func (funcBenchmarkTest) Name() string                                   { return "func" }
+func (funcBenchmarkTest) Setup(context.Context, DatabasePool) error      { return nil }
+func (f funcBenchmarkTest) Run(context.Context, DatabasePool, int) error { f.run(); return nil }
+func (funcBenchmarkTest) Cleanup(context.Context, DatabasePool) error    { return nil }
+
+func TestBenchmarkRunner_AttachesServerRates(t *testing.T) {
+	s := newFakeServer()
+	setStatusCounters(s, 1, 100)
+	p := s.pool(t)
+	sampler := NewServerStatusSampler(p, ServerStatusSamplerConfig{Capacity: 10})
+
+	config := DefaultBenchmarkConfig()
+	config.Duration = 20 * time.Millisecond
+	config.WarmupTime = 0
+	config.Concurrency = 1
+	config.ReportInterval = 0
+	runner := NewBenchmarkRunner(config, p)
+	runner.SetServerStatusSampler(sampler)
+
+	// Counters advance while the benchmark runs
+	var once sync.Once
+	test := funcBenchmarkTest{run: func() { once.Do(func() { setStatusCounters(s, 3, 101) }) }}
+	result, err := runner.RunBenchmark(context.Background(), test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ServerRates == nil || result.ServerRates.Questions <= 0 || len(sampler.Snapshots()) != 2 {
+		t.Fatalf("server rates: %+v", result.ServerRates)
+	}
+}
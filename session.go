@@ -0,0 +1,250 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// sessionConnector wraps a driver.Connector so that every new physical
+// connection runs the configured session initialization statements, and
+// connections on which a caller ran a session-changing statement (see
+// changesSession) are discarded instead of being reused.
+type sessionConnector struct {
+	inner driver.Connector
+	init  []string
+	reset bool
+}
+
+// newSessionConnector wraps inner with Config.SessionInit and
+// Config.ResetSession behaviour.
+func newSessionConnector(inner driver.Connector, init []string, reset bool) driver.Connector {
+	return &sessionConnector{inner: inner, init: init, reset: reset}
+}
+
+// Connect implements driver.Connector.
+func (c *sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.inner.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range c.init {
+		if err := execDriverConn(ctx, conn, stmt); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("session init %q failed: %w", stmt, err)
+		}
+	}
+	return &sessionConn{Conn: conn, reset: c.reset}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *sessionConnector) Driver() driver.Driver {
+	return c.inner.Driver()
+}
+
+// driverConnector returns a driver.Connector for a registered driver name.
+func driverConnector(driverName, dsn string) (driver.Connector, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	_ = db.Close()
+	if dc, ok := drv.(driver.DriverContext); ok {
+		return dc.OpenConnector(dsn)
+	}
+	return &dsnConnector{driver: drv, dsn: dsn}, nil
+}
+
+// dsnConnector adapts a driver without driver.DriverContext.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+// Connect implements driver.Connector.
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver implements driver.Connector.
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// execDriverConn runs stmt directly on a driver connection.
+func execDriverConn(ctx context.Context, conn driver.Conn, stmt string) error {
+	if ex, ok := conn.(driver.ExecerContext); ok {
+		_, err := ex.ExecContext(ctx, stmt, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	st, err := conn.Prepare(stmt)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	_, err = st.Exec(nil) //nolint:staticcheck // fallback for drivers without ExecerContext
+	return err
+}
+
+// sessionConn is a driver connection that remembers whether a caller ran
+// a statement that changesSession recognizes. It forwards the optional
+// driver interfaces of the wrapped connection.
+type sessionConn struct {
+	driver.Conn
+	reset          bool
+	sessionChanged atomic.Bool // a tracked statement was seen
+}
+
+// changesSession reports whether query is one of the statements known to
+// leave session state behind for the next borrower:
+//
+//   - SET (session variables, NAMES, ROLE, user variables) and USE
+//   - CREATE TEMPORARY TABLE
+//   - LOCK TABLES
+//   - user variable assignment in any statement, as in SELECT @x := 1
+//
+// Only these are tracked; other session state, such as prepared statements
+// created with PREPARE or open HANDLERs, is not detected.
+func changesSession(query string) bool {
+	q := strings.TrimLeft(query, " \t\r\n(")
+	switch word := strings.ToUpper(firstWord(q)); word {
+	case "SET", "USE", "LOCK":
+		return true
+	case "CREATE":
+		rest := strings.TrimLeft(q[len(word):], " \t\r\n")
+		if strings.EqualFold(firstWord(rest), "TEMPORARY") {
+			return true
+		}
+	}
+	return assignsUserVariable(q)
+}
+
+// assignsUserVariable reports whether q assigns a user variable with :=.
+func assignsUserVariable(q string) bool {
+	for i := strings.IndexByte(q, '@'); i >= 0; {
+		j := i + 1
+		for j < len(q) && (isIdentByte(q[j]) || q[j] == '.') {
+			j++
+		}
+		rest := strings.TrimLeft(q[j:], " \t\r\n")
+		if j > i+1 && strings.HasPrefix(rest, ":=") {
+			return true
+		}
+		next := strings.IndexByte(q[j:], '@')
+		if next < 0 {
+			break
+		}
+		i = j + next
+	}
+	return false
+}
+
+// firstWord returns the leading keyword of q.
+func firstWord(q string) string {
+	for i := 0; i < len(q); i++ {
+		if !isIdentByte(q[i]) {
+			return q[:i]
+		}
+	}
+	return q
+}
+
+// observe records a session-changing statement run on the connection.
+func (c *sessionConn) observe(query string) {
+	if c.reset && changesSession(query) {
+		c.sessionChanged.Store(true)
+	}
+}
+
+// Prepare implements driver.Conn.
+func (c *sessionConn) Prepare(query string) (driver.Stmt, error) {
+	c.observe(query)
+	return c.Conn.Prepare(query)
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *sessionConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.observe(query)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return pc.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *sessionConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ex, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	c.observe(query)
+	return ex.ExecContext(ctx, query, args)
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *sessionConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	c.observe(query)
+	return qc.QueryContext(ctx, query, args)
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *sessionConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bt.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, fmt.Errorf("driver does not support transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without ConnBeginTx
+}
+
+// Ping implements driver.Pinger.
+func (c *sessionConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (c *sessionConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// IsValid implements driver.Validator.
+func (c *sessionConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// ResetSession implements driver.SessionResetter. It is called by
+// database/sql before a pooled connection is reused. The MySQL driver has
+// no way to issue COM_RESET_CONNECTION, so a connection that ran a
+// statement recognized by changesSession is reported as bad instead: it is
+// closed and the next borrower gets a fresh connection with only
+// SessionInit applied. Session state changed by untracked statements is
+// kept.
+func (c *sessionConn) ResetSession(ctx context.Context) error {
+	if c.sessionChanged.Load() {
+		return driver.ErrBadConn
+	}
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
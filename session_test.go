@@ -0,0 +1,128 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newSessionTestServer returns a fake server on which every statement
+// succeeds.
+func newSessionTestServer() *fakeServer {
+	s := newFakeServer()
+	s.set("", serverResult{})
+	return s
+}
+
+func TestSessionInit_RunsOnEachConnection(t *testing.T) {
+	s := newSessionTestServer()
+	init := []string{"SET time_zone = '+00:00'", "SET NAMES utf8mb4 COLLATE utf8mb4_0900_ai_ci"}
+	db, err := openDB(context.Background(), Config{Driver: fakeServerDriver, SessionInit: init}, s.dsn(t), nil)
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	c1, _ := db.Conn(ctx)
+	c2, _ := db.Conn(ctx)
+	defer c1.Close()
+	defer c2.Close()
+
+	conns := s.connections()
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+	for _, c := range conns {
+		if queries := s.connQueries(c); strings.Join(queries, ";") != strings.Join(init, ";") {
+			t.Fatalf("init statements not run: %v", queries)
+		}
+	}
+}
+
+func TestSessionInit_FailureFailsConnect(t *testing.T) {
+	s := newSessionTestServer()
+	s.set("SET sql_mode = 'BOGUS'", serverResult{err: errors.New("exec failed")})
+	cfg := Config{Driver: fakeServerDriver, SessionInit: []string{"SET sql_mode = 'BOGUS'"}}
+	if _, err := openDB(context.Background(), cfg, s.dsn(t), nil); err == nil || !strings.Contains(err.Error(), "session init") {
+		t.Fatalf("expected session init error, got %v", err)
+	}
+}
+
+func TestResetSession_DiscardsDirtyConnections(t *testing.T) {
+	s := newSessionTestServer()
+	db, err := openDB(context.Background(), Config{Driver: fakeServerDriver, ResetSession: true}, s.dsn(t), nil)
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	p := &Pool{db: db}
+	ctx := context.Background()
+
+	// Plain queries keep the connection
+	if err := p.WithConn(ctx, func(c DatabaseConn) error {
+		_, err := c.Exec(ctx, "UPDATE t SET a = 1")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WithConn(ctx, func(DatabaseConn) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.connections()); n != 1 {
+		t.Fatalf("clean connection should be reused, opened=%d", n)
+	}
+
+	// Changing session state on a pinned connection discards it
+	if err := p.WithConn(ctx, func(c DatabaseConn) error {
+		_, err := c.Exec(ctx, "SET SESSION sql_mode = ''")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WithConn(ctx, func(DatabaseConn) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.connections()); n != 2 {
+		t.Fatalf("dirty connection should be replaced, opened=%d", n)
+	}
+}
+
+func TestChangesSession(t *testing.T) {
+	for q, want := range map[string]bool{
+		"SET @a = 1":                        true,
+		"  set names utf8mb4":               true,
+		"USE other":                         true,
+		"SELECT * FROM settings":            false,
+		"UPDATE t SET a = 1":                false,
+		"INSERT INTO sets VALUES (1)":       false,
+		"(SET x = 1)":                       true,
+		"SETTINGS":                          false,
+		"CREATE TEMPORARY TABLE t (a INT)":  true,
+		"create  temporary table t (a INT)": true,
+		"CREATE TABLE t (a INT)":            false,
+		"LOCK TABLES t WRITE":               true,
+		"SELECT @x := 1":                    true,
+		"SELECT a, @row:=@row+1 FROM t":     true,
+		"SELECT @x, @@session.sql_mode":     false,
+		"SELECT * FROM t WHERE a = @x":      false,
+	} {
+		if got := changesSession(q); got != want {
+			t.Fatalf("changesSession(%q)=%v want %v", q, got, want)
+		}
+	}
+}
+
+func TestApplyEnv_SessionInit(t *testing.T) {
+	t.Setenv("YGGGO_MYSQL_SESSIONS_SESSION_INIT", "SET time_zone = '+00:00'; SET sql_mode = 'A,B';")
+	t.Setenv("YGGGO_MYSQL_SESSIONS_RESET_SESSION", "true")
+	var cfg Config
+	if err := applyEnvPrefix(&cfg, envPrefixFor("sessions")); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.SessionInit) != 2 || cfg.SessionInit[1] != "SET sql_mode = 'A,B'" || !cfg.ResetSession {
+		t.Fatalf("session settings: %q reset=%v", cfg.SessionInit, cfg.ResetSession)
+	}
+}
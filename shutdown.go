@@ -0,0 +1,230 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosing is returned by Acquire, WithConn and WithinTx once
+// Shutdown has been called.
+var ErrPoolClosing = errors.New("pool is shutting down")
+
+// ErrPoolClosed is returned by Rebuild and failover once Close or Shutdown
+// has closed the pool.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// shutdownPollInterval is how often Shutdown checks for drained work.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// ForcedResource describes a borrowed connection or transaction that was
+// still in use when Shutdown's context expired.
+type ForcedResource struct {
+	Kind    string        `json:"kind"` // "conn" or "tx"
+	HeldFor time.Duration `json:"held_for"`
+}
+
+// ShutdownReport summarizes a Shutdown.
+type ShutdownReport struct {
+	Duration time.Duration    `json:"duration"`
+	Drained  bool             `json:"drained"` // all work finished before the deadline
+	Forced   []ForcedResource `json:"forced,omitempty"`
+	Errors   []string         `json:"errors,omitempty"` // errors stopping components
+}
+
+// inflightEntry is one tracked connection or transaction.
+type inflightEntry struct {
+	kind   string
+	since  time.Time
+	conn   *sql.Conn          // set for borrowed connections
+	cancel context.CancelFunc // set for transactions
+}
+
+// inflightTracker records borrowed connections and open transactions so
+// Shutdown can wait for them. Registration and the closing flag share a
+// mutex, so no work can slip in after Shutdown starts waiting.
+type inflightTracker struct {
+	mu      sync.Mutex
+	closing bool
+	entries map[*inflightEntry]struct{}
+}
+
+// add registers new work, or returns ErrPoolClosing. cancel, if not nil,
+// aborts the work when Shutdown gives up waiting.
+func (t *inflightTracker) add(kind string, cancel context.CancelFunc) (*inflightEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closing {
+		return nil, ErrPoolClosing
+	}
+	if t.entries == nil {
+		t.entries = make(map[*inflightEntry]struct{})
+	}
+	e := &inflightEntry{kind: kind, since: time.Now(), cancel: cancel}
+	t.entries[e] = struct{}{}
+	return e, nil
+}
+
+// setConn records the connection backing e, so it can be force-closed.
+func (t *inflightTracker) setConn(e *inflightEntry, c *sql.Conn) {
+	t.mu.Lock()
+	e.conn = c
+	t.mu.Unlock()
+}
+
+// done unregisters e. It is safe to call more than once.
+func (t *inflightTracker) done(e *inflightEntry) {
+	if e == nil {
+		return
+	}
+	t.mu.Lock()
+	delete(t.entries, e)
+	t.mu.Unlock()
+}
+
+// isClosing reports whether Shutdown has started.
+func (t *inflightTracker) isClosing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closing
+}
+
+// startClosing sets the closing flag and reports whether it was already set.
+func (t *inflightTracker) startClosing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	was := t.closing
+	t.closing = true
+	return was
+}
+
+// count returns the amount of tracked work.
+func (t *inflightTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
+
+// drain removes and returns all tracked work.
+func (t *inflightTracker) drain() []*inflightEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]*inflightEntry, 0, len(t.entries))
+	for e := range t.entries {
+		out = append(out, e)
+		delete(t.entries, e)
+	}
+	return out
+}
+
+// Shutdown gracefully closes the pool.
+//
+// New Acquire, WithConn and WithinTx calls fail with ErrPoolClosing right
+// away. Shutdown then waits for borrowed connections to be closed and
+// running transactions to finish. When ctx expires first, the remaining
+// transactions are cancelled (and so rolled back) and the remaining
+// connections closed; they are listed in the report.
+//
+// Afterwards the health monitor and connection probe are stopped, the
+// kills sent for cancelled statements (Config.KillOnCancel) are awaited
+// until ctx expires, then the slow query recorder is closed and the
+// database handle is closed.
+// Calling Shutdown again returns an empty report.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+//	defer cancel()
+//	report, err := pool.Shutdown(ctx)
+//	if len(report.Forced) > 0 {
+//		log.Printf("forced %d resources closed", len(report.Forced))
+//	}
+func (p *Pool) Shutdown(ctx context.Context) (*ShutdownReport, error) {
+	start := time.Now()
+	report := &ShutdownReport{Drained: true}
+	if p.inflight.startClosing() {
+		return report, nil
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for p.inflight.count() > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	now := time.Now()
+	for _, e := range p.inflight.drain() {
+		report.Drained = false
+		report.Forced = append(report.Forced, ForcedResource{Kind: e.kind, HeldFor: now.Sub(e.since)})
+		if e.cancel != nil {
+			e.cancel()
+		}
+		if e.conn != nil {
+			// Close waits for a running query; don't let it hold up shutdown
+			go e.conn.Close()
+		}
+	}
+
+	var errs []error
+	if p.IsHealthMonitoringRunning() {
+		if err := p.StopHealthMonitoring(); err != nil {
+			errs = append(errs, fmt.Errorf("health monitor: %w", err))
+		}
+	}
+	if p.probe != nil && p.probe.IsRunning() {
+		if err := p.probe.Stop(); err != nil {
+			errs = append(errs, fmt.Errorf("connection probe: %w", err))
+		}
+	}
+	// Cancelled statements may still be killed on the server; the kills
+	// need the database handle and report to the slow query recorder
+	if !p.waitKills(ctx) {
+		errs = append(errs, fmt.Errorf("kill queries: %w", ctx.Err()))
+	}
+	if p.slowQueryRecorder != nil {
+		if err := p.slowQueryRecorder.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("slow query recorder: %w", err))
+		}
+	}
+	if db := p.markClosed(); db != nil {
+		if err := db.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("database: %w", err))
+		}
+	}
+	for _, err := range errs {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.Duration = time.Since(start)
+
+	if !report.Drained {
+		errs = append(errs, fmt.Errorf("forced %d in-flight resources closed: %w", len(report.Forced), ctx.Err()))
+	}
+	return report, errors.Join(errs...)
+}
+
+// waitKills waits for the KILL QUERY statements sent in the background
+// for cancelled statements. It returns false when ctx expires first.
+func (p *Pool) waitKills(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		p.killWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// IsClosing reports whether Shutdown has been called.
+func (p *Pool) IsClosing() bool {
+	return p.inflight.isClosing()
+}
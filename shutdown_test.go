@@ -0,0 +1,121 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newShutdownTestPool(t *testing.T) *Pool {
+	t.Helper()
+	db, err := sql.Open("enhanced_fake", "shutdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Pool{db: db}
+}
+
+func TestShutdown_WaitsForBorrowedConnAndTx(t *testing.T) {
+	p := newShutdownTestPool(t)
+	ctx := context.Background()
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	txStarted := make(chan struct{})
+	releaseTx := make(chan struct{})
+	txDone := make(chan error, 1)
+	go func() {
+		txDone <- p.WithinTx(ctx, func(tx DatabaseTx) error {
+			close(txStarted)
+			<-releaseTx
+			return nil
+		})
+	}()
+	<-txStarted
+
+	done := make(chan *ShutdownReport, 1)
+	go func() {
+		report, err := p.Shutdown(ctx)
+		if err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+		done <- report
+	}()
+
+	// New work is rejected while draining
+	deadline := time.Now().Add(time.Second)
+	for !p.IsClosing() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := p.Acquire(ctx); !errors.Is(err, ErrPoolClosing) {
+		t.Fatalf("Acquire during shutdown: %v", err)
+	}
+	if err := p.WithinTx(ctx, func(DatabaseTx) error { return nil }); !errors.Is(err, ErrPoolClosing) {
+		t.Fatalf("WithinTx during shutdown: %v", err)
+	}
+
+	select {
+	case <-done:
+		t.Fatalf("Shutdown returned before work finished")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	close(releaseTx)
+	if err := <-txDone; err != nil {
+		t.Fatalf("in-flight transaction failed: %v", err)
+	}
+	_ = conn.Close()
+
+	report := <-done
+	if !report.Drained || len(report.Forced) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// Shutdown is idempotent
+	if again, err := p.Shutdown(ctx); err != nil || len(again.Forced) != 0 {
+		t.Fatalf("second Shutdown: %+v %v", again, err)
+	}
+}
+
+func TestShutdown_ForcesOnDeadline(t *testing.T) {
+	p := newShutdownTestPool(t)
+
+	if _, err := p.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	txStarted := make(chan struct{})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	go func() {
+		_ = p.WithinTx(context.Background(), func(tx DatabaseTx) error {
+			close(txStarted)
+			<-release
+			return nil
+		})
+	}()
+	<-txStarted
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report, err := p.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if report.Drained || len(report.Forced) != 2 {
+		t.Fatalf("expected conn and tx to be forced: %+v", report)
+	}
+	kinds := map[string]bool{}
+	for _, f := range report.Forced {
+		kinds[f.Kind] = true
+		if f.HeldFor <= 0 {
+			t.Fatalf("HeldFor not set: %+v", f)
+		}
+	}
+	if !kinds["conn"] || !kinds["tx"] {
+		t.Fatalf("forced kinds=%v", kinds)
+	}
+}
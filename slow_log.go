@@ -0,0 +1,326 @@
+package ygggo_mysql
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slowLogTimeLayout is the "# Time:" format of MySQL 5.7 and later.
+const slowLogTimeLayout = "2006-01-02T15:04:05.000000Z"
+
+// SlowLogReader reads entries of a MySQL server slow query log (the
+// slow_query_log file) as SlowQueryRecords, so they can be stored and
+// analyzed like recorded queries:
+//
+//	f, _ := os.Open("/var/log/mysql/mysql-slow.log")
+//	storage := ygggo_mysql.NewMemorySlowQueryStorage(10000)
+//	ygggo_mysql.ImportSlowLog(ctx, f, storage, ygggo_mysql.NormalizeBasic)
+//	report, _ := ygggo_mysql.NewSlowQueryAnalyzer(storage).GenerateReport(ctx, ygggo_mysql.SlowQueryFilter{})
+//
+// Both the MySQL 5.7+ and the older "# Time: 230301 10:00:00" formats are
+// understood. Server start-up banners and unknown "#" attribute lines,
+// such as those added by Percona Server, are skipped.
+type SlowLogReader struct {
+	r    *bufio.Reader
+	mode string
+	next string // header line read ahead
+	err  error
+}
+
+// NewSlowLogReader returns a reader that normalizes queries with the given
+// normalization mode (see FingerprintQuery).
+func NewSlowLogReader(r io.Reader, normalizationMode string) *SlowLogReader {
+	return &SlowLogReader{r: bufio.NewReader(r), mode: normalizationMode}
+}
+
+// line returns the next line without its line ending.
+func (s *SlowLogReader) line() (string, bool) {
+	if s.next != "" {
+		l := s.next
+		s.next = ""
+		return l, true
+	}
+	if s.err != nil {
+		return "", false
+	}
+	l, err := s.r.ReadString('\n')
+	if err != nil {
+		s.err = err
+		if l == "" {
+			return "", false
+		}
+	}
+	return strings.TrimRight(l, "\r\n"), true
+}
+
+// Read returns the next entry, or io.EOF at the end of the log.
+func (s *SlowLogReader) Read() (*SlowQueryRecord, error) {
+	for {
+		record, err := s.readEntry()
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			return record, nil
+		}
+	}
+}
+
+// readEntry reads one header block and the statements following it. It
+// returns nil for blocks without a query.
+func (s *SlowLogReader) readEntry() (*SlowQueryRecord, error) {
+	record := &SlowQueryRecord{}
+	var endTime, startTime time.Time
+	var body []string
+	inBody := false
+	for {
+		l, ok := s.line()
+		if !ok {
+			if s.err != io.EOF {
+				return nil, s.err
+			}
+			if !inBody {
+				return nil, io.EOF
+			}
+			break
+		}
+		if strings.HasPrefix(l, "# ") {
+			if inBody {
+				s.next = l
+				break
+			}
+			if err := parseSlowLogHeader(l[2:], record, &endTime); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if isSlowLogBanner(l) {
+			if inBody {
+				break
+			}
+			continue
+		}
+		inBody = true
+		trimmed := strings.TrimSpace(l)
+		lower := strings.ToLower(trimmed)
+		switch {
+		case len(body) == 0 && strings.HasPrefix(lower, "use ") && strings.HasSuffix(trimmed, ";"):
+			record.Database = strings.Trim(strings.TrimSpace(trimmed[4:len(trimmed)-1]), "`")
+		case len(body) == 0 && strings.HasPrefix(lower, "set timestamp="):
+			v := strings.TrimSuffix(trimmed[len("set timestamp="):], ";")
+			sec, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid slow log timestamp %q: %w", v, err)
+			}
+			startTime = time.Unix(0, int64(sec*float64(time.Second))).UTC()
+		case len(body) == 0 && trimmed == "":
+		default:
+			body = append(body, l)
+		}
+	}
+
+	query := strings.TrimSpace(strings.Join(body, "\n"))
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if query == "" {
+		return nil, nil
+	}
+
+	// The record timestamp is the completion time, as for recorded queries
+	record.Timestamp = endTime
+	if record.Timestamp.IsZero() && !startTime.IsZero() {
+		record.Timestamp = startTime.Add(record.Duration)
+	}
+	record.ID = generateID()
+	record.Query = query
+	record.NormalizedQuery = FingerprintQuery(query, s.mode)
+	record.PatternID = QueryDigest(record.NormalizedQuery)
+	return record, nil
+}
+
+// parseSlowLogHeader parses a "# key: value" line of an entry.
+func parseSlowLogHeader(l string, record *SlowQueryRecord, endTime *time.Time) error {
+	switch {
+	case strings.HasPrefix(l, "Time:"):
+		t, err := parseSlowLogTime(strings.TrimSpace(l[len("Time:"):]))
+		if err != nil {
+			return err
+		}
+		*endTime = t
+	case strings.HasPrefix(l, "User@Host:"):
+		parseSlowLogUserHost(strings.TrimSpace(l[len("User@Host:"):]), record)
+	default:
+		// Query_time, Lock_time, Rows_sent and Rows_examined, possibly
+		// among other attributes on the same line; Percona Server also
+		// logs the Schema
+		fields := strings.Fields(l)
+		for i := 0; i+1 < len(fields); i++ {
+			key := strings.TrimSuffix(fields[i], ":")
+			if key == fields[i] {
+				continue
+			}
+			value := fields[i+1]
+			var err error
+			switch key {
+			case "Query_time":
+				record.Duration, err = parseSlowLogSeconds(value)
+			case "Lock_time":
+				record.LockTime, err = parseSlowLogSeconds(value)
+			case "Rows_sent":
+				record.RowsSent, err = strconv.ParseInt(value, 10, 64)
+			case "Rows_examined":
+				record.RowsExamined, err = strconv.ParseInt(value, 10, 64)
+			case "Schema":
+				record.Database = value
+			}
+			if err != nil {
+				return fmt.Errorf("invalid slow log %s %q: %w", key, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseSlowLogTime parses the value of a "# Time:" line.
+func parseSlowLogTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t.UTC(), nil
+	}
+	// MySQL 5.6 and earlier: "230301 10:00:00", hours may be space padded
+	if t, err := time.ParseInLocation("060102 15:04:05", strings.Join(strings.Fields(v), " "), time.Local); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid slow log time %q", v)
+}
+
+// parseSlowLogSeconds parses a duration in (fractional) seconds.
+func parseSlowLogSeconds(v string) (time.Duration, error) {
+	sec, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(sec*float64(time.Second) + 0.5), nil
+}
+
+// parseSlowLogUserHost parses "user[user] @ host [ip]  Id: 8".
+func parseSlowLogUserHost(v string, record *SlowQueryRecord) {
+	if i := strings.Index(v, "  Id:"); i >= 0 {
+		v = v[:i]
+	}
+	userPart, hostPart, _ := strings.Cut(v, "@")
+	userPart = strings.TrimSpace(userPart)
+	if i := strings.IndexByte(userPart, '['); i >= 0 {
+		userPart = userPart[:i]
+	}
+	record.User = userPart
+
+	hostPart = strings.TrimSpace(hostPart)
+	host, ip := hostPart, ""
+	if i := strings.IndexByte(hostPart, '['); i >= 0 {
+		host = strings.TrimSpace(hostPart[:i])
+		ip = strings.Trim(hostPart[i:], "[] ")
+	}
+	if host == "" {
+		host = ip
+	}
+	record.Host = host
+}
+
+// isSlowLogBanner reports whether l is part of the banner the server
+// writes when it opens the log.
+func isSlowLogBanner(l string) bool {
+	return strings.Contains(l, ", Version: ") && strings.Contains(l, "started with:") ||
+		strings.HasPrefix(l, "Tcp port: ") ||
+		strings.HasPrefix(l, "Time ") && strings.Contains(l, "Id Command") && strings.Contains(l, "Argument")
+}
+
+// ParseSlowLog reads all entries of a MySQL slow query log.
+func ParseSlowLog(r io.Reader, normalizationMode string) ([]*SlowQueryRecord, error) {
+	reader := NewSlowLogReader(r, normalizationMode)
+	var records []*SlowQueryRecord
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+// ImportSlowLog stores the entries of a MySQL slow query log in storage
+// and returns the number of entries stored.
+func ImportSlowLog(ctx context.Context, r io.Reader, storage SlowQueryStorage, normalizationMode string) (int, error) {
+	reader := NewSlowLogReader(r, normalizationMode)
+	n := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := storage.Store(ctx, record); err != nil {
+			return n, fmt.Errorf("failed to store slow log entry: %w", err)
+		}
+		n++
+	}
+}
+
+// WriteSlowLog writes records in the MySQL slow query log format, so tools
+// such as pt-query-digest can process them. Records are written in the
+// given order.
+func WriteSlowLog(w io.Writer, records []*SlowQueryRecord) error {
+	bw := bufio.NewWriter(w)
+	for _, record := range records {
+		writeSlowLogEntry(bw, record)
+	}
+	return bw.Flush()
+}
+
+// writeSlowLogEntry writes one entry.
+func writeSlowLogEntry(w *bufio.Writer, record *SlowQueryRecord) {
+	end := record.Timestamp.UTC()
+	start := end.Add(-record.Duration)
+	fmt.Fprintf(w, "# Time: %s\n", end.Format(slowLogTimeLayout))
+	if record.User != "" || record.Host != "" {
+		fmt.Fprintf(w, "# User@Host: %s[%s] @ %s []\n", record.User, record.User, record.Host)
+	}
+	fmt.Fprintf(w, "# Query_time: %.6f  Lock_time: %.6f Rows_sent: %d  Rows_examined: %d\n",
+		record.Duration.Seconds(), record.LockTime.Seconds(), record.RowsSent, record.RowsExamined)
+	if record.Database != "" {
+		fmt.Fprintf(w, "use %s;\n", record.Database)
+	}
+	fmt.Fprintf(w, "SET timestamp=%d;\n", start.Unix())
+	query := strings.TrimSpace(record.Query)
+	if !strings.HasSuffix(query, ";") {
+		query += ";"
+	}
+	w.WriteString(query)
+	w.WriteByte('\n')
+}
+
+// ExportSlowLog writes the records matching filter in the MySQL slow query
+// log format, oldest first.
+func (r *SlowQueryRecorder) ExportSlowLog(ctx context.Context, w io.Writer, filter SlowQueryFilter) error {
+	records, err := r.storage.GetRecords(ctx, filter)
+	if err != nil {
+		return err
+	}
+	sorted := append([]*SlowQueryRecord(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	return WriteSlowLog(w, sorted)
+}
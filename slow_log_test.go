@@ -0,0 +1,119 @@
+package ygggo_mysql
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleSlowLog = `/usr/sbin/mysqld, Version: 8.0.32 (MySQL Community Server - GPL). started with:
+Tcp port: 3306  Unix socket: /var/run/mysqld/mysqld.sock
+Time                 Id Command    Argument
+# Time: 2023-03-01T10:00:02.500000Z
+# User@Host: app[app] @ web1 [10.0.0.5]  Id:     8
+# Query_time: 2.500000  Lock_time: 0.000120 Rows_sent: 1  Rows_examined: 100000
+use shop;
+SET timestamp=1677664800;
+SELECT *
+  FROM orders
+ WHERE customer_id = 42;
+# Time: 2023-03-01T10:00:05.000000Z
+# User@Host: app[app] @  [10.0.0.6]  Id:     9
+# Query_time: 1.000000  Lock_time: 0.000000 Rows_sent: 1  Rows_examined: 90000
+SET timestamp=1677664804;
+SELECT * FROM orders WHERE customer_id = 7;
+# Time: 2023-03-01T10:00:06.000000Z
+# User@Host: app[app] @ web1 [10.0.0.5]  Id:     8
+# Query_time: 0.000010  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0
+SET timestamp=1677664806;
+# administrator command: Quit;
+# Time: 230301 10:00:07
+# User@Host: root[root] @ localhost []
+# Query_time: 3  Lock_time: 0  Rows_sent: 0  Rows_examined: 5
+SET timestamp=1677664804;
+UPDATE stock SET qty = qty - 1 WHERE sku = 'a';
+`
+
+func TestParseSlowLog(t *testing.T) {
+	records, err := ParseSlowLog(strings.NewReader(sampleSlowLog), NormalizeBasic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %+v", len(records), records)
+	}
+
+	r := records[0]
+	if r.Query != "SELECT *\n  FROM orders\n WHERE customer_id = 42" || r.NormalizedQuery != "SELECT * FROM ORDERS WHERE CUSTOMER_ID = ?" {
+		t.Fatalf("query: %q / %q", r.Query, r.NormalizedQuery)
+	}
+	if r.Duration != 2500*time.Millisecond || r.LockTime != 120*time.Microsecond || r.RowsSent != 1 || r.RowsExamined != 100000 {
+		t.Fatalf("attributes: %+v", r)
+	}
+	if r.Database != "shop" || r.User != "app" || r.Host != "web1" {
+		t.Fatalf("connection: %+v", r)
+	}
+	if !r.Timestamp.Equal(time.Date(2023, 3, 1, 10, 0, 2, 500000000, time.UTC)) {
+		t.Fatalf("timestamp: %v", r.Timestamp)
+	}
+	if records[1].Host != "10.0.0.6" || records[1].PatternID != r.PatternID {
+		t.Fatalf("second entry: %+v", records[1])
+	}
+	if records[2].Duration != 3*time.Second || records[2].User != "root" || records[2].Timestamp.IsZero() {
+		t.Fatalf("old format entry: %+v", records[2])
+	}
+}
+
+func TestImportSlowLogAndAnalyze(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemorySlowQueryStorage(100)
+	n, err := ImportSlowLog(ctx, strings.NewReader(sampleSlowLog), storage, NormalizeBasic)
+	if err != nil || n != 3 {
+		t.Fatalf("import: n=%d err=%v", n, err)
+	}
+	patterns, err := storage.GetPatterns(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 1 || patterns[0].Count != 2 || patterns[0].TotalDuration != 3500*time.Millisecond {
+		t.Fatalf("patterns: %+v", patterns)
+	}
+	report, err := NewSlowQueryAnalyzer(storage).GenerateReport(ctx, SlowQueryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary.TotalQueries != 3 {
+		t.Fatalf("report summary: %+v", report.Summary)
+	}
+}
+
+func TestExportSlowLogRoundTrip(t *testing.T) {
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = 0
+	recorder := NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(100))
+	defer recorder.Close()
+	ctx := context.Background()
+	recorder.Record(ctx, "SELECT * FROM users WHERE id = 1", nil, 150*time.Millisecond, nil)
+	recorder.Record(ctx, "UPDATE users\nSET name = 'x'", nil, 2*time.Second, nil)
+
+	var buf bytes.Buffer
+	if err := recorder.ExportSlowLog(ctx, &buf, SlowQueryFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "# Query_time: 0.150000  Lock_time: 0.000000 Rows_sent: 0  Rows_examined: 0\n") ||
+		!strings.Contains(out, "SELECT * FROM users WHERE id = 1;\n") {
+		t.Fatalf("unexpected slow log:\n%s", out)
+	}
+
+	records, err := ParseSlowLog(&buf, NormalizeBasic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Duration != 150*time.Millisecond || records[1].Query != "UPDATE users\nSET name = 'x'" {
+		t.Fatalf("round trip: %+v", records)
+	}
+}
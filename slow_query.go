@@ -4,12 +4,11 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
-	"regexp"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,7 +17,11 @@ type SlowQueryRecord struct {
 	ID          string        `json:"id"`          // Unique identifier
 	Query       string        `json:"query"`       // Original SQL query
 	NormalizedQuery string    `json:"normalized_query"` // Normalized query for pattern matching
+	PatternID   string        `json:"pattern_id,omitempty"` // Digest of NormalizedQuery (see QueryDigest)
 	Duration    time.Duration `json:"duration"`    // Query execution duration
+	LockTime    time.Duration `json:"lock_time,omitempty"` // Time spent waiting for locks (server slow log)
+	RowsSent    int64         `json:"rows_sent,omitempty"` // Rows returned to the client (server slow log)
+	RowsExamined int64        `json:"rows_examined,omitempty"` // Rows read by the server (server slow log)
 	Timestamp   time.Time     `json:"timestamp"`   // When the query was executed
 	Args        []interface{} `json:"args,omitempty"` // Query arguments (may be sanitized)
 	Error       string        `json:"error,omitempty"` // Error message if query failed
@@ -26,6 +29,8 @@ type SlowQueryRecord struct {
 	Database    string        `json:"database,omitempty"` // Database name
 	User        string        `json:"user,omitempty"` // Database user
 	Host        string        `json:"host,omitempty"` // Database host
+	Plan        *ExplainPlan  `json:"plan,omitempty"` // Execution plan (when CaptureExplain is enabled)
+	Statement   *StatementStat `json:"statement,omitempty"` // Statement statistics (records from Pool.ExportStatementStats)
 }
 
 // SlowQueryStats represents statistics for slow queries
@@ -41,6 +46,7 @@ type SlowQueryStats struct {
 
 // QueryPattern represents a pattern of similar queries
 type QueryPattern struct {
+	ID              string        `json:"id"`              // Digest of NormalizedQuery (see QueryDigest)
 	NormalizedQuery string        `json:"normalized_query"` // Normalized query pattern
 	Count           int64         `json:"count"`           // Number of occurrences
 	TotalDuration   time.Duration `json:"total_duration"`  // Total execution time
@@ -48,6 +54,7 @@ type QueryPattern struct {
 	MaxDuration     time.Duration `json:"max_duration"`    // Maximum execution time
 	LastSeen        time.Time     `json:"last_seen"`       // Last occurrence time
 	Examples        []string      `json:"examples,omitempty"` // Example queries (limited)
+	Latency         *LatencyHistogram `json:"latency,omitempty"` // Latency distribution
 }
 
 // SlowQueryConfig holds configuration for slow query recording
@@ -58,7 +65,10 @@ type SlowQueryConfig struct {
 	MaxPatterns       int           `json:"max_patterns"`       // Maximum query patterns to track
 	SanitizeArgs      bool          `json:"sanitize_args"`      // Whether to sanitize query arguments
 	IncludeStack      bool          `json:"include_stack"`      // Whether to include call stack
-	NormalizationMode string        `json:"normalization_mode"` // Query normalization mode
+	NormalizationMode string        `json:"normalization_mode"` // Query normalization mode: none, basic or fingerprint
+	CaptureExplain    bool          `json:"capture_explain"`    // Run EXPLAIN for slow SELECT/UPDATE/DELETE statements
+	ExplainInterval   time.Duration `json:"explain_interval"`   // Minimum time between EXPLAINs of the same pattern
+	ExplainTimeout    time.Duration `json:"explain_timeout"`    // Timeout for a single EXPLAIN
 }
 
 // DefaultSlowQueryConfig returns default configuration
@@ -71,6 +81,9 @@ func DefaultSlowQueryConfig() SlowQueryConfig {
 		SanitizeArgs:      true,
 		IncludeStack:      false,
 		NormalizationMode: "basic",
+		CaptureExplain:    false,
+		ExplainInterval:   time.Minute,
+		ExplainTimeout:    5 * time.Second,
 	}
 }
 
@@ -198,7 +211,7 @@ type SlowQueryFilter struct {
 	EndTime      *time.Time    `json:"end_time,omitempty"`
 	MinDuration  *time.Duration `json:"min_duration,omitempty"`
 	MaxDuration  *time.Duration `json:"max_duration,omitempty"`
-	QueryPattern string        `json:"query_pattern,omitempty"`
+	QueryPattern string        `json:"query_pattern,omitempty"` // Substring of the normalized query, or a pattern ID
 	Database     string        `json:"database,omitempty"`
 	Limit        int           `json:"limit,omitempty"`
 	Offset       int           `json:"offset,omitempty"`
@@ -208,6 +221,16 @@ type SlowQueryFilter struct {
 type SlowQueryRecorder struct {
 	configManager *SlowQueryConfigManager
 	storage       SlowQueryStorage
+	redactor      atomic.Pointer[Redactor]
+	alerts        atomic.Pointer[AlertManager]
+
+	explainMu sync.Mutex
+	explainer ExplainFunc
+	explained map[string]time.Time // last EXPLAIN per normalized pattern
+	pending   sync.WaitGroup       // EXPLAINs and stores in flight
+
+	closeMu sync.Mutex
+	closed  bool // set by Close; later records are dropped
 }
 
 // NewSlowQueryRecorder creates a new slow query recorder
@@ -248,8 +271,29 @@ func (r *SlowQueryRecorder) UpdateConfig(config SlowQueryConfig) {
 	r.configManager.UpdateConfig(config)
 }
 
+// SetRedactor sets the redaction applied to recorded queries, arguments
+// and errors. Arguments are redacted according to its policy when
+// SanitizeArgs is enabled; arguments bound to sensitive columns are
+// always redacted. A nil redactor restores DefaultRedactor.
+func (r *SlowQueryRecorder) SetRedactor(redactor *Redactor) {
+	r.redactor.Store(redactor)
+}
+
+// getRedactor returns the recorder's Redactor, or the default one
+func (r *SlowQueryRecorder) getRedactor() *Redactor {
+	if redactor := r.redactor.Load(); redactor != nil {
+		return redactor
+	}
+	return defaultRedactor
+}
+
 // Record records a slow query if it exceeds the threshold
 func (r *SlowQueryRecorder) Record(ctx context.Context, query string, args []interface{}, duration time.Duration, err error) error {
+	return r.record(ctx, query, args, duration, 0, err)
+}
+
+// record is Record with the number of rows the query returned
+func (r *SlowQueryRecorder) record(ctx context.Context, query string, args []interface{}, duration time.Duration, rowsSent int64, err error) error {
 	if !r.IsEnabled() {
 		return nil
 	}
@@ -258,26 +302,145 @@ func (r *SlowQueryRecorder) Record(ctx context.Context, query string, args []int
 	if duration <= threshold {
 		return nil
 	}
+	return r.capture(ctx, query, args, duration, rowsSent, err)
+}
+
+// capture records a statement whatever its duration
+func (r *SlowQueryRecorder) capture(ctx context.Context, query string, args []interface{}, duration time.Duration, rowsSent int64, err error) error {
+	if !r.IsEnabled() {
+		return nil
+	}
 
 	config := r.GetConfig()
+	redactor := r.getRedactor()
 	record := &SlowQueryRecord{
 		ID:              generateID(),
-		Query:           query,
+		Query:           redactor.Query(query),
 		NormalizedQuery: r.normalizeQuery(query, config.NormalizationMode),
 		Duration:        duration,
 		Timestamp:       time.Now(),
-		Args:            r.sanitizeArgs(args, config.SanitizeArgs),
+		Args:            r.sanitizeArgs(redactor, query, args, config.SanitizeArgs),
+		RowsSent:        rowsSent,
 	}
 
+	record.PatternID = QueryDigest(record.NormalizedQuery)
+
 	if err != nil {
-		record.Error = err.Error()
+		record.Error = redactor.String(err.Error())
 	}
 
 	if config.IncludeStack {
 		record.Stack = captureStack()
 	}
 
-	return r.storage.Store(ctx, record)
+	if !r.acquire() {
+		return nil
+	}
+	if explain := r.explainFor(config, record.PatternID, query); explain != nil {
+		// The plan is captured on another connection without delaying the
+		// caller; the record is stored once the EXPLAIN finishes.
+		go func() {
+			defer r.pending.Done()
+			timeout := config.ExplainTimeout
+			if timeout <= 0 {
+				timeout = DefaultSlowQueryConfig().ExplainTimeout
+			}
+			ectx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if plan, err := explain(ectx, query, args...); err == nil {
+				if config.SanitizeArgs {
+					// The raw plan shows conditions with bound values
+					plan.JSON = ""
+				}
+				record.Plan = plan
+			}
+			_ = r.save(context.Background(), record)
+		}()
+		return nil
+	}
+
+	defer r.pending.Done()
+	return r.save(ctx, record)
+}
+
+// acquire registers a store in flight, unless the recorder is closed.
+// Close waits for registered stores; the caller calls pending.Done.
+func (r *SlowQueryRecorder) acquire() bool {
+	r.closeMu.Lock()
+	defer r.closeMu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.pending.Add(1)
+	return true
+}
+
+// store saves a record, unless the recorder is closed, and evaluates the
+// alert rules against it
+func (r *SlowQueryRecorder) store(ctx context.Context, record *SlowQueryRecord) error {
+	if !r.acquire() {
+		return nil
+	}
+	defer r.pending.Done()
+	return r.save(ctx, record)
+}
+
+// save saves a record and evaluates the alert rules against it
+func (r *SlowQueryRecorder) save(ctx context.Context, record *SlowQueryRecord) error {
+	if err := r.storage.Store(ctx, record); err != nil {
+		return err
+	}
+	if alerts := r.alerts.Load(); alerts != nil {
+		alerts.Evaluate(ctx, record, r.storage)
+	}
+	return nil
+}
+
+// SetAlertManager sets the manager whose rules are evaluated for every
+// stored record. A nil manager disables alerting.
+func (r *SlowQueryRecorder) SetAlertManager(m *AlertManager) {
+	r.alerts.Store(m)
+}
+
+// SetExplainer sets the function used to capture execution plans when
+// CaptureExplain is enabled. Pool.EnableSlowQueryRecording sets it to
+// Pool.Explain.
+func (r *SlowQueryRecorder) SetExplainer(fn ExplainFunc) {
+	r.explainMu.Lock()
+	defer r.explainMu.Unlock()
+	r.explainer = fn
+}
+
+// explainFor returns the explainer when a plan should be captured for
+// query: capture is enabled, the statement can be explained and its
+// pattern was not explained within ExplainInterval.
+func (r *SlowQueryRecorder) explainFor(config SlowQueryConfig, pattern, query string) ExplainFunc {
+	if !config.CaptureExplain || !explainable(query) {
+		return nil
+	}
+	r.explainMu.Lock()
+	defer r.explainMu.Unlock()
+	if r.explainer == nil {
+		return nil
+	}
+	interval := config.ExplainInterval
+	if interval <= 0 {
+		interval = DefaultSlowQueryConfig().ExplainInterval
+	}
+	now := time.Now()
+	if last, ok := r.explained[pattern]; ok && now.Sub(last) < interval {
+		return nil
+	}
+	if r.explained == nil {
+		r.explained = make(map[string]time.Time)
+	}
+	for p, last := range r.explained {
+		if now.Sub(last) >= interval {
+			delete(r.explained, p)
+		}
+	}
+	r.explained[pattern] = now
+	return r.explainer
 }
 
 // GetRecords retrieves slow query records
@@ -300,14 +463,36 @@ func (r *SlowQueryRecorder) Clear(ctx context.Context) error {
 	return r.storage.Clear(ctx)
 }
 
-// Close closes the recorder
+// Close closes the recorder after pending EXPLAINs have finished and
+// pending alerts have been delivered. Records reported afterwards, such as
+// those of queries still draining when the pool closes, are dropped
 func (r *SlowQueryRecorder) Close() error {
+	r.closeMu.Lock()
+	if r.closed {
+		r.closeMu.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.closeMu.Unlock()
+	r.pending.Wait()
+	if alerts := r.alerts.Load(); alerts != nil {
+		alerts.Flush()
+	}
 	if r.storage != nil {
 		return r.storage.Close()
 	}
 	return nil
 }
 
+// patternID returns the pattern ID of a record, computing it for records
+// stored without one
+func patternID(record *SlowQueryRecord) string {
+	if record.PatternID != "" {
+		return record.PatternID
+	}
+	return QueryDigest(record.NormalizedQuery)
+}
+
 // Helper functions implementation
 func generateID() string {
 	bytes := make([]byte, 8)
@@ -315,53 +500,18 @@ func generateID() string {
 	return hex.EncodeToString(bytes)
 }
 
+// normalizeQuery returns the fingerprint of query for the given mode
 func (r *SlowQueryRecorder) normalizeQuery(query string, mode string) string {
-	if mode == "none" {
-		return query
-	}
-
-	// Basic normalization: replace values with placeholders
-	normalized := query
-
-	// Replace string literals
-	stringLiteralRe := regexp.MustCompile(`'[^']*'`)
-	normalized = stringLiteralRe.ReplaceAllString(normalized, "?")
-
-	// Replace numeric literals
-	numericRe := regexp.MustCompile(`\b\d+\b`)
-	normalized = numericRe.ReplaceAllString(normalized, "?")
-
-	// Normalize whitespace
-	whitespaceRe := regexp.MustCompile(`\s+`)
-	normalized = whitespaceRe.ReplaceAllString(strings.TrimSpace(normalized), " ")
-
-	// Convert to uppercase for consistency
-	normalized = strings.ToUpper(normalized)
-
-	return normalized
+	return FingerprintQuery(query, mode)
 }
 
-func (r *SlowQueryRecorder) sanitizeArgs(args []interface{}, shouldSanitize bool) []interface{} {
+// sanitizeArgs redacts args with the recorder's policy when shouldSanitize
+// is set. Arguments bound to sensitive columns are redacted regardless.
+func (r *SlowQueryRecorder) sanitizeArgs(redactor *Redactor, query string, args []interface{}, shouldSanitize bool) []interface{} {
 	if !shouldSanitize {
-		return args
+		return redactor.args(query, args, ArgPolicyKeep)
 	}
-
-	sanitized := make([]interface{}, len(args))
-	for i, arg := range args {
-		switch v := arg.(type) {
-		case string:
-			if len(v) > 50 {
-				sanitized[i] = v[:50] + "..."
-			} else {
-				sanitized[i] = "[string]"
-			}
-		case []byte:
-			sanitized[i] = fmt.Sprintf("[bytes:%d]", len(v))
-		default:
-			sanitized[i] = fmt.Sprintf("[%T]", v)
-		}
-	}
-	return sanitized
+	return redactor.Args(query, args)
 }
 
 func captureStack() string {
@@ -401,19 +551,23 @@ func (s *MemorySlowQueryStorage) Store(ctx context.Context, record *SlowQueryRec
 	}
 
 	// Update patterns
-	pattern, exists := s.patterns[record.NormalizedQuery]
+	id := patternID(record)
+	pattern, exists := s.patterns[id]
 	if !exists {
 		pattern = &QueryPattern{
+			ID:              id,
 			NormalizedQuery: record.NormalizedQuery,
 			Count:           0,
 			TotalDuration:   0,
 			MaxDuration:     0,
 			Examples:        make([]string, 0, 3),
+			Latency:         NewLatencyHistogram(),
 		}
-		s.patterns[record.NormalizedQuery] = pattern
+		s.patterns[id] = pattern
 	}
 
 	pattern.Count++
+	pattern.Latency.Record(record.Duration)
 	pattern.TotalDuration += record.Duration
 	pattern.AverageDuration = time.Duration(int64(pattern.TotalDuration) / pattern.Count)
 	pattern.LastSeen = record.Timestamp
@@ -482,7 +636,7 @@ func (s *MemorySlowQueryStorage) matchesFilter(record *SlowQueryRecord, filter S
 		return false
 	}
 
-	if filter.QueryPattern != "" && !strings.Contains(record.NormalizedQuery, filter.QueryPattern) {
+	if filter.QueryPattern != "" && !strings.Contains(record.NormalizedQuery, filter.QueryPattern) && patternID(record) != filter.QueryPattern {
 		return false
 	}
 
@@ -561,6 +715,16 @@ func (s *MemorySlowQueryStorage) GetPatterns(ctx context.Context, limit int) ([]
 	return patterns, nil
 }
 
+// GetPattern returns a copy of the pattern with the given ID, or nil.
+func (s *MemorySlowQueryStorage) GetPattern(ctx context.Context, id string) (*QueryPattern, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if pattern, ok := s.patterns[id]; ok {
+		return pattern.clone(), nil
+	}
+	return nil, nil
+}
+
 func (s *MemorySlowQueryStorage) getTopPatterns(limit int) []QueryPattern {
 	patterns := make([]QueryPattern, 0, len(s.patterns))
 	for _, pattern := range s.patterns {
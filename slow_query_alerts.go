@@ -0,0 +1,455 @@
+package ygggo_mysql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Alert is sent to AlertSinks when an AlertRule fires.
+type Alert struct {
+	Rule         string           `json:"rule"`                    // Name of the rule that fired
+	Message      string           `json:"message"`                 // Human readable reason
+	FiredAt      time.Time        `json:"fired_at"`                // When the rule fired
+	PatternID    string           `json:"pattern_id"`              // Pattern of the triggering query
+	Pattern      string           `json:"pattern"`                 // Normalized query of the pattern
+	ExampleQuery string           `json:"example_query"`           // Triggering query (redacted)
+	Record       *SlowQueryRecord `json:"record"`                  // Triggering record
+	PatternStats *QueryPattern    `json:"pattern_stats,omitempty"` // Statistics of the pattern
+	Stats        *SlowQueryStats  `json:"stats,omitempty"`         // Recorder statistics from GetStats
+}
+
+// AlertRule decides whether a recorded slow query raises an alert.
+// Implementations must be safe for concurrent use.
+type AlertRule interface {
+	// Name identifies the rule in alerts; it should be unique
+	Name() string
+
+	// Cooldown is the minimum time between two alerts of the rule
+	Cooldown() time.Duration
+
+	// Evaluate is called for each stored record and returns the alert
+	// message when the rule fires
+	Evaluate(record *SlowQueryRecord) (string, bool)
+}
+
+// rateRule fires when more than Count slow queries arrive within Window.
+type rateRule struct {
+	name     string
+	count    int
+	window   time.Duration
+	cooldown time.Duration
+
+	mu     sync.Mutex
+	recent []time.Time // timestamps of the last count+1 records
+}
+
+// NewSlowQueryRateRule returns a rule that fires when more than count slow
+// queries are recorded within window, for example more than 50 in 1m.
+func NewSlowQueryRateRule(name string, count int, window, cooldown time.Duration) AlertRule {
+	if count < 0 {
+		count = 0
+	}
+	return &rateRule{name: name, count: count, window: window, cooldown: cooldown}
+}
+
+func (r *rateRule) Name() string            { return r.name }
+func (r *rateRule) Cooldown() time.Duration { return r.cooldown }
+
+func (r *rateRule) Evaluate(record *SlowQueryRecord) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recent = append(r.recent, record.Timestamp)
+	if len(r.recent) > r.count+1 {
+		r.recent = r.recent[len(r.recent)-(r.count+1):]
+	}
+	if len(r.recent) == r.count+1 && record.Timestamp.Sub(r.recent[0]) <= r.window {
+		return fmt.Sprintf("more than %d slow queries within %v", r.count, r.window), true
+	}
+	return "", false
+}
+
+// durationRule fires for any query slower than a threshold.
+type durationRule struct {
+	name      string
+	threshold time.Duration
+	cooldown  time.Duration
+}
+
+// NewQueryDurationRule returns a rule that fires for any query that took
+// longer than threshold.
+func NewQueryDurationRule(name string, threshold, cooldown time.Duration) AlertRule {
+	return &durationRule{name: name, threshold: threshold, cooldown: cooldown}
+}
+
+func (r *durationRule) Name() string            { return r.name }
+func (r *durationRule) Cooldown() time.Duration { return r.cooldown }
+
+func (r *durationRule) Evaluate(record *SlowQueryRecord) (string, bool) {
+	if record.Duration > r.threshold {
+		return fmt.Sprintf("query took %v (limit %v)", record.Duration, r.threshold), true
+	}
+	return "", false
+}
+
+// maxTrackedPatterns bounds the patterns remembered by the new pattern rule.
+const maxTrackedPatterns = 10000
+
+// newPatternRule fires the first time a pattern is seen.
+type newPatternRule struct {
+	name     string
+	cooldown time.Duration
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// NewPatternRule returns a rule that fires when a query pattern is seen
+// for the first time. The rule remembers up to 10000 patterns; once the
+// limit is reached it starts over.
+func NewPatternRule(name string, cooldown time.Duration) AlertRule {
+	return &newPatternRule{name: name, cooldown: cooldown, seen: make(map[string]struct{})}
+}
+
+func (r *newPatternRule) Name() string            { return r.name }
+func (r *newPatternRule) Cooldown() time.Duration { return r.cooldown }
+
+func (r *newPatternRule) Evaluate(record *SlowQueryRecord) (string, bool) {
+	id := patternID(record)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.seen[id]; ok {
+		return "", false
+	}
+	if len(r.seen) >= maxTrackedPatterns {
+		r.seen = make(map[string]struct{})
+	}
+	r.seen[id] = struct{}{}
+	return "new slow query pattern: " + record.NormalizedQuery, true
+}
+
+// AlertSink delivers alerts.
+type AlertSink interface {
+	Send(ctx context.Context, alert *Alert) error
+}
+
+// AlertSinkFunc adapts a callback to an AlertSink.
+type AlertSinkFunc func(ctx context.Context, alert *Alert) error
+
+// Send calls f(ctx, alert).
+func (f AlertSinkFunc) Send(ctx context.Context, alert *Alert) error {
+	return f(ctx, alert)
+}
+
+// slogAlertSink logs alerts.
+type slogAlertSink struct {
+	logger *slog.Logger
+}
+
+// NewSlogAlertSink returns a sink that logs alerts at Warn level. A nil
+// logger uses slog.Default().
+func NewSlogAlertSink(logger *slog.Logger) AlertSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogAlertSink{logger: logger}
+}
+
+func (s *slogAlertSink) Send(ctx context.Context, alert *Alert) error {
+	attrs := []slog.Attr{
+		slog.String("rule", alert.Rule),
+		slog.String("pattern_id", alert.PatternID),
+		slog.String("pattern", alert.Pattern),
+		slog.String("query", alert.ExampleQuery),
+	}
+	if alert.Record != nil {
+		attrs = append(attrs, slog.Float64("duration_ms", float64(alert.Record.Duration.Nanoseconds())/1e6))
+	}
+	if alert.Stats != nil {
+		attrs = append(attrs, slog.Int64("total_slow_queries", alert.Stats.TotalCount))
+	}
+	s.logger.LogAttrs(ctx, slog.LevelWarn, "slow query alert: "+alert.Message, attrs...)
+	return nil
+}
+
+// webhookAlertSink posts alerts as JSON.
+type webhookAlertSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookAlertSink returns a sink that POSTs each alert as JSON to url.
+// Responses other than 2xx are reported as errors. A nil client uses a
+// client with a 10 second timeout.
+func NewWebhookAlertSink(url string, client *http.Client) AlertSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &webhookAlertSink{url: url, client: client}
+}
+
+func (s *webhookAlertSink) Send(ctx context.Context, alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// FileAlertSink appends alerts to a file, one JSON object per line.
+type FileAlertSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAlertSink opens (or creates) the alert file.
+func NewFileAlertSink(filePath string) (*FileAlertSink, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return &FileAlertSink{file: file}, nil
+}
+
+// Send appends the alert to the file.
+func (s *FileAlertSink) Send(ctx context.Context, alert *Alert) error {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the file.
+func (s *FileAlertSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// AlertManager evaluates alert rules against slow query records as they
+// are stored and sends the resulting alerts to its sinks. Sinks are called
+// in the background so a slow webhook never delays queries.
+//
+// Example:
+//
+//	alerts := ygggo_mysql.NewAlertManager(
+//		ygggo_mysql.NewSlowQueryRateRule("burst", 50, time.Minute, 5*time.Minute),
+//		ygggo_mysql.NewQueryDurationRule("very-slow", 10*time.Second, time.Minute),
+//		ygggo_mysql.NewPatternRule("new-pattern", 0),
+//	)
+//	alerts.AddSink(ygggo_mysql.NewWebhookAlertSink("https://alerts.example.com/hook", nil))
+//	pool.GetSlowQueryRecorder().SetAlertManager(alerts)
+type AlertManager struct {
+	mu        sync.Mutex
+	rules     []AlertRule
+	sinks     []AlertSink
+	lastFired map[string]time.Time
+	onError   func(alert *Alert, err error)
+	pending   sync.WaitGroup
+}
+
+// NewAlertManager creates a manager with the given rules.
+func NewAlertManager(rules ...AlertRule) *AlertManager {
+	return &AlertManager{rules: rules, lastFired: make(map[string]time.Time)}
+}
+
+// AddRule adds a rule.
+func (m *AlertManager) AddRule(rule AlertRule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = append(m.rules, rule)
+}
+
+// AddSink adds a sink that receives every alert.
+func (m *AlertManager) AddSink(sink AlertSink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sinks = append(m.sinks, sink)
+}
+
+// SetErrorHandler sets the function called when a sink fails. By default
+// failures are logged with slog.
+func (m *AlertManager) SetErrorHandler(fn func(alert *Alert, err error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onError = fn
+}
+
+// Evaluate checks record against every rule and sends an alert for each
+// rule that fires and is not cooling down. storage provides the pattern
+// and recorder statistics included in the alert; it may be nil. They are
+// read in the background, with the sinks, so Evaluate does not wait for
+// the storage.
+func (m *AlertManager) Evaluate(ctx context.Context, record *SlowQueryRecord, storage SlowQueryStorage) {
+	m.mu.Lock()
+	rules := append([]AlertRule(nil), m.rules...)
+	m.mu.Unlock()
+
+	var alerts []*Alert
+	for _, rule := range rules {
+		msg, fire := rule.Evaluate(record)
+		if !fire || !m.take(rule) {
+			continue
+		}
+		alerts = append(alerts, &Alert{
+			Rule:         rule.Name(),
+			Message:      msg,
+			FiredAt:      time.Now(),
+			PatternID:    patternID(record),
+			Pattern:      record.NormalizedQuery,
+			ExampleQuery: record.Query,
+			Record:       record,
+		})
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	m.dispatch(ctx, alerts, storage)
+}
+
+// take reports whether rule may fire now and starts its cooldown.
+func (m *AlertManager) take(rule AlertRule) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if last, ok := m.lastFired[rule.Name()]; ok && now.Sub(last) < rule.Cooldown() {
+		return false
+	}
+	m.lastFired[rule.Name()] = now
+	return true
+}
+
+// alertSendTimeout bounds the delivery of an alert to a sink, and the
+// storage reads that complete the alerts.
+const alertSendTimeout = 30 * time.Second
+
+// dispatch completes alerts with the statistics from storage and sends
+// them to every sink, in the background.
+func (m *AlertManager) dispatch(ctx context.Context, alerts []*Alert, storage SlowQueryStorage) {
+	m.mu.Lock()
+	sinks := append([]AlertSink(nil), m.sinks...)
+	onError := m.onError
+	m.mu.Unlock()
+	if len(sinks) == 0 {
+		return
+	}
+	m.pending.Add(1)
+	go func() {
+		defer m.pending.Done()
+		if storage != nil {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), alertSendTimeout)
+			stats, _ := storage.GetStats(ctx)
+			pattern := findPattern(ctx, storage, alerts[0].PatternID)
+			cancel()
+			for _, a := range alerts {
+				a.Stats = stats
+				a.PatternStats = pattern
+			}
+		}
+		for _, alert := range alerts {
+			for _, sink := range sinks {
+				m.pending.Add(1)
+				go func(alert *Alert, sink AlertSink) {
+					defer m.pending.Done()
+					ctx, cancel := context.WithTimeout(context.Background(), alertSendTimeout)
+					defer cancel()
+					if err := sink.Send(ctx, alert); err != nil {
+						if onError != nil {
+							onError(alert, err)
+						} else {
+							slog.Default().Warn("slow query alert delivery failed", "rule", alert.Rule, "error", err)
+						}
+					}
+				}(alert, sink)
+			}
+		}
+	}()
+}
+
+// Flush waits until all alerts sent so far have been delivered.
+func (m *AlertManager) Flush() {
+	m.pending.Wait()
+}
+
+// Close flushes pending alerts and closes sinks that implement io.Closer.
+func (m *AlertManager) Close() error {
+	m.Flush()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var firstErr error
+	for _, sink := range m.sinks {
+		if c, ok := sink.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// patternGetter is implemented by storages that can look up a single
+// pattern by ID, returning a copy, or nil when it is unknown.
+type patternGetter interface {
+	GetPattern(ctx context.Context, id string) (*QueryPattern, error)
+}
+
+// findPattern returns a copy of the stored pattern with the given ID. It
+// scans GetPatterns when storage cannot look up a single pattern.
+func findPattern(ctx context.Context, storage SlowQueryStorage, id string) *QueryPattern {
+	if g, ok := storage.(patternGetter); ok {
+		p, err := g.GetPattern(ctx, id)
+		if err != nil {
+			return nil
+		}
+		return p
+	}
+	patterns, err := storage.GetPatterns(ctx, 0)
+	if err != nil {
+		return nil
+	}
+	for _, p := range patterns {
+		if p.ID == id || p.ID == "" && QueryDigest(p.NormalizedQuery) == id {
+			return p.clone()
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of p that shares no mutable state with it.
+func (p *QueryPattern) clone() *QueryPattern {
+	c := *p
+	c.Examples = append([]string(nil), p.Examples...)
+	if p.Latency != nil {
+		c.Latency = p.Latency.Clone()
+	}
+	return &c
+}
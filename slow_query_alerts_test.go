@@ -0,0 +1,230 @@
+package ygggo_mysql
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newAlertTestRecorder(t *testing.T, threshold time.Duration) *SlowQueryRecorder {
+	t.Helper()
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = threshold
+	r := NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(100))
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+// collectAlerts returns a sink storing alerts and a function returning them.
+func collectAlerts() (AlertSink, func() []*Alert) {
+	var mu sync.Mutex
+	var alerts []*Alert
+	sink := AlertSinkFunc(func(_ context.Context, a *Alert) error {
+		mu.Lock()
+		defer mu.Unlock()
+		alerts = append(alerts, a)
+		return nil
+	})
+	return sink, func() []*Alert {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*Alert(nil), alerts...)
+	}
+}
+
+func TestAlerts_DurationRuleAndCooldown(t *testing.T) {
+	r := newAlertTestRecorder(t, 10*time.Millisecond)
+	m := NewAlertManager(NewQueryDurationRule("very-slow", time.Second, time.Hour))
+	sink, got := collectAlerts()
+	m.AddSink(sink)
+	r.SetAlertManager(m)
+
+	ctx := context.Background()
+	r.Record(ctx, "SELECT * FROM users WHERE id = 1", nil, 20*time.Millisecond, nil)
+	r.Record(ctx, "SELECT * FROM users WHERE id = 2", nil, 2*time.Second, nil)
+	r.Record(ctx, "SELECT * FROM users WHERE id = 3", nil, 3*time.Second, nil)
+	m.Flush()
+
+	alerts := got()
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert within the cooldown, got %d", len(alerts))
+	}
+	a := alerts[0]
+	if a.Rule != "very-slow" || a.ExampleQuery != "SELECT * FROM users WHERE id = 2" || a.Pattern != "SELECT * FROM USERS WHERE ID = ?" {
+		t.Fatalf("alert: %+v", a)
+	}
+	// Statistics are read when the alert is sent, after the second or the
+	// third record
+	if a.Stats == nil || a.Stats.TotalCount < 2 || a.PatternStats == nil || a.PatternStats.Count < 2 || a.PatternID != a.PatternStats.ID {
+		t.Fatalf("alert payload: stats=%+v pattern=%+v", a.Stats, a.PatternStats)
+	}
+}
+
+// slowStatsStorage blocks GetStats until release is closed.
+type slowStatsStorage struct {
+	*MemorySlowQueryStorage
+	release chan struct{}
+}
+
+func (s *slowStatsStorage) GetStats(ctx context.Context) (*SlowQueryStats, error) {
+	<-s.release
+	return s.MemorySlowQueryStorage.GetStats(ctx)
+}
+
+func TestAlerts_EvaluateDoesNotWaitForStorage(t *testing.T) {
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = time.Millisecond
+	storage := &slowStatsStorage{MemorySlowQueryStorage: NewMemorySlowQueryStorage(100), release: make(chan struct{})}
+	r := NewSlowQueryRecorder(config, storage)
+	m := NewAlertManager(NewQueryDurationRule("very-slow", time.Second, 0))
+	sink, got := collectAlerts()
+	m.AddSink(sink)
+	r.SetAlertManager(m)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Record(context.Background(), "SELECT * FROM users WHERE id = 1", nil, 2*time.Second, nil)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Record waited for the alert statistics")
+	}
+	close(storage.release)
+	r.Close()
+	if alerts := got(); len(alerts) != 1 || alerts[0].Stats == nil || alerts[0].PatternStats == nil {
+		t.Fatalf("alerts: %+v", alerts)
+	}
+}
+
+func TestAlerts_RateRule(t *testing.T) {
+	rule := NewSlowQueryRateRule("burst", 2, time.Minute, 0)
+	now := time.Now()
+	at := func(d time.Duration) *SlowQueryRecord {
+		return &SlowQueryRecord{NormalizedQuery: "SELECT ?", Timestamp: now.Add(d)}
+	}
+	if _, fire := rule.Evaluate(at(0)); fire {
+		t.Fatal("fired after 1 query")
+	}
+	if _, fire := rule.Evaluate(at(time.Second)); fire {
+		t.Fatal("fired after 2 queries")
+	}
+	if _, fire := rule.Evaluate(at(2 * time.Minute)); fire {
+		t.Fatal("fired for queries outside the window")
+	}
+	rule.Evaluate(at(2*time.Minute + time.Second))
+	if msg, fire := rule.Evaluate(at(2*time.Minute + 2*time.Second)); !fire || msg == "" {
+		t.Fatal("expected rule to fire for 3 queries within the window")
+	}
+}
+
+func TestAlerts_NewPatternRule(t *testing.T) {
+	r := newAlertTestRecorder(t, 0)
+	m := NewAlertManager(NewPatternRule("new-pattern", 0))
+	sink, got := collectAlerts()
+	m.AddSink(sink)
+	r.SetAlertManager(m)
+
+	ctx := context.Background()
+	r.Record(ctx, "SELECT * FROM a WHERE id = 1", nil, time.Millisecond, nil)
+	r.Record(ctx, "SELECT * FROM a WHERE id = 2", nil, time.Millisecond, nil)
+	r.Record(ctx, "SELECT * FROM b WHERE id = 1", nil, time.Millisecond, nil)
+	m.Flush()
+
+	alerts := got()
+	if len(alerts) != 2 || alerts[0].PatternID == alerts[1].PatternID {
+		t.Fatalf("expected one alert per new pattern, got %+v", alerts)
+	}
+}
+
+func TestAlerts_WebhookSink(t *testing.T) {
+	received := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var a Alert
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(req.Body).Decode(&a); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- a
+	}))
+	defer srv.Close()
+
+	r := newAlertTestRecorder(t, 0)
+	m := NewAlertManager(NewQueryDurationRule("very-slow", 0, 0))
+	m.AddSink(NewWebhookAlertSink(srv.URL, srv.Client()))
+	var sendErr error
+	m.SetErrorHandler(func(_ *Alert, err error) { sendErr = err })
+	r.SetAlertManager(m)
+
+	r.Record(context.Background(), "UPDATE t SET a = 1", nil, time.Millisecond, nil)
+	m.Flush()
+	if sendErr != nil {
+		t.Fatal(sendErr)
+	}
+	select {
+	case a := <-received:
+		if a.Rule != "very-slow" || a.Record == nil || a.Stats == nil || a.Stats.TotalCount != 1 {
+			t.Fatalf("webhook payload: %+v", a)
+		}
+	default:
+		t.Fatal("webhook was not called")
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := NewWebhookAlertSink(failing.URL, nil).Send(context.Background(), &Alert{}); err == nil {
+		t.Fatal("expected error for a 500 response")
+	}
+}
+
+func TestAlerts_FileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts", "alerts.jsonl")
+	sink, err := NewFileAlertSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewAlertManager(NewQueryDurationRule("very-slow", 0, 0))
+	m.AddSink(sink)
+	r := newAlertTestRecorder(t, 0)
+	r.SetAlertManager(m)
+
+	r.Record(context.Background(), "SELECT 1", nil, time.Millisecond, nil)
+	r.Record(context.Background(), "SELECT 2", nil, time.Millisecond, nil)
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var a Alert
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Fatalf("expected 2 alerts in file, got %d", lines)
+	}
+}
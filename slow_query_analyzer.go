@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -25,6 +26,7 @@ type AnalysisReport struct {
 	TopSlowQueries   []*SlowQueryRecord `json:"top_slow_queries"`
 	FrequentPatterns []*QueryPattern    `json:"frequent_patterns"`
 	TimeDistribution []TimeSlot         `json:"time_distribution"`
+	PatternLatencies []PatternLatency   `json:"pattern_latencies"`
 	Recommendations  []string           `json:"recommendations"`
 	GeneratedAt      time.Time          `json:"generated_at"`
 }
@@ -54,6 +56,38 @@ type TimeSlot struct {
 	QueryCount   int64         `json:"query_count"`
 	TotalTime    time.Duration `json:"total_time"`
 	AverageTime  time.Duration `json:"average_time"`
+	Latency      LatencyPercentiles `json:"latency"`
+}
+
+// PatternLatency describes the latency distribution of one query pattern
+type PatternLatency struct {
+	ID              string             `json:"id"`
+	NormalizedQuery string             `json:"normalized_query"`
+	Count           int64              `json:"count"`
+	Latency         LatencyPercentiles `json:"latency"`
+	Histogram       []HistogramBucket  `json:"histogram"`
+}
+
+// WindowComparison compares per-pattern latency between two time windows
+type WindowComparison struct {
+	Baseline    TimeRange           `json:"baseline"`
+	Current     TimeRange           `json:"current"`
+	Threshold   float64             `json:"threshold"`
+	Patterns    []PatternComparison `json:"patterns"`
+	Regressions []PatternComparison `json:"regressions"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+// PatternComparison holds the p95 latency of a pattern in both windows
+type PatternComparison struct {
+	ID              string        `json:"id"`
+	NormalizedQuery string        `json:"normalized_query"`
+	BaselineCount   int64         `json:"baseline_count"`
+	CurrentCount    int64         `json:"current_count"`
+	BaselineP95     time.Duration `json:"baseline_p95"`
+	CurrentP95      time.Duration `json:"current_p95"`
+	Change          float64       `json:"change"`    // Relative p95 change, 0.5 means 50% slower
+	Regressed       bool          `json:"regressed"` // Change exceeds the threshold
 }
 
 // GenerateReport generates a comprehensive analysis report
@@ -85,6 +119,9 @@ func (a *SlowQueryAnalyzer) GenerateReport(ctx context.Context, filter SlowQuery
 	// Generate time distribution
 	timeDistribution := a.generateTimeDistribution(records, 24) // 24 hour slots
 	
+	// Latency distribution per pattern
+	patternLatencies := a.generatePatternLatencies(records)
+	
 	// Generate recommendations
 	recommendations := a.generateRecommendations(records, patterns)
 	
@@ -93,6 +130,7 @@ func (a *SlowQueryAnalyzer) GenerateReport(ctx context.Context, filter SlowQuery
 		TopSlowQueries:   topSlow,
 		FrequentPatterns: patterns,
 		TimeDistribution: timeDistribution,
+		PatternLatencies: patternLatencies,
 		Recommendations:  recommendations,
 		GeneratedAt:      time.Now(),
 	}, nil
@@ -120,7 +158,7 @@ func (a *SlowQueryAnalyzer) generateSummary(records []*SlowQueryRecord) Analysis
 	
 	for i, record := range records {
 		totalDuration += record.Duration
-		patterns[record.NormalizedQuery] = true
+		patterns[patternID(record)] = true
 		
 		if i == 0 || record.Timestamp.Before(minTime) {
 			minTime = record.Timestamp
@@ -203,6 +241,7 @@ func (a *SlowQueryAnalyzer) generateTimeDistribution(records []*SlowQueryRecord,
 	}
 	
 	// Distribute records into slots
+	histograms := make([]*LatencyHistogram, slots)
 	for _, record := range records {
 		slotIndex := int(record.Timestamp.Sub(minTime) / slotDuration)
 		if slotIndex >= slots {
@@ -211,18 +250,142 @@ func (a *SlowQueryAnalyzer) generateTimeDistribution(records []*SlowQueryRecord,
 		
 		timeSlots[slotIndex].QueryCount++
 		timeSlots[slotIndex].TotalTime += record.Duration
+		if histograms[slotIndex] == nil {
+			histograms[slotIndex] = NewLatencyHistogram()
+		}
+		histograms[slotIndex].Record(record.Duration)
 	}
 	
-	// Calculate averages
+	// Calculate averages and percentiles
 	for i := range timeSlots {
 		if timeSlots[i].QueryCount > 0 {
 			timeSlots[i].AverageTime = time.Duration(int64(timeSlots[i].TotalTime) / timeSlots[i].QueryCount)
+			timeSlots[i].Latency = histograms[i].Percentiles()
 		}
 	}
 	
 	return timeSlots
 }
 
+// patternHistograms builds a latency histogram per pattern ID from records
+func patternHistograms(records []*SlowQueryRecord) (map[string]*LatencyHistogram, map[string]string) {
+	histograms := make(map[string]*LatencyHistogram)
+	queries := make(map[string]string)
+	for _, record := range records {
+		id := patternID(record)
+		h, ok := histograms[id]
+		if !ok {
+			h = NewLatencyHistogram()
+			histograms[id] = h
+			queries[id] = record.NormalizedQuery
+		}
+		h.Record(record.Duration)
+	}
+	return histograms, queries
+}
+
+// generatePatternLatencies reports percentiles and histograms per pattern,
+// slowest p95 first
+func (a *SlowQueryAnalyzer) generatePatternLatencies(records []*SlowQueryRecord) []PatternLatency {
+	histograms, queries := patternHistograms(records)
+	latencies := make([]PatternLatency, 0, len(histograms))
+	for id, h := range histograms {
+		latencies = append(latencies, PatternLatency{
+			ID:              id,
+			NormalizedQuery: queries[id],
+			Count:           h.Count(),
+			Latency:         h.Percentiles(),
+			Histogram:       h.Buckets(),
+		})
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		if latencies[i].Latency.P95 != latencies[j].Latency.P95 {
+			return latencies[i].Latency.P95 > latencies[j].Latency.P95
+		}
+		return latencies[i].ID < latencies[j].ID
+	})
+	return latencies
+}
+
+// RenderHistograms renders the latency histogram of each pattern in the
+// report as text, with bars up to width characters wide
+func (r *AnalysisReport) RenderHistograms(width int) string {
+	var sb strings.Builder
+	for _, p := range r.PatternLatencies {
+		fmt.Fprintf(&sb, "%s [%s]\n  count=%d p50=%v p90=%v p95=%v p99=%v\n",
+			p.NormalizedQuery, p.ID, p.Count, p.Latency.P50, p.Latency.P90, p.Latency.P95, p.Latency.P99)
+		sb.WriteString(renderBuckets(p.Histogram, width))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// CompareWindows compares the p95 latency of each pattern between a
+// baseline and a current time window. Patterns whose p95 grew by more than
+// threshold (0.2 means 20% slower) are reported as regressions, worst
+// first. Patterns seen in only one window are not compared.
+func (a *SlowQueryAnalyzer) CompareWindows(ctx context.Context, baseline, current TimeRange, threshold float64) (*WindowComparison, error) {
+	windowHistograms := func(w TimeRange) (map[string]*LatencyHistogram, map[string]string, error) {
+		start, end := w.Start, w.End
+		records, err := a.storage.GetRecords(ctx, SlowQueryFilter{StartTime: &start, EndTime: &end})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get records: %w", err)
+		}
+		h, q := patternHistograms(records)
+		return h, q, nil
+	}
+	before, _, err := windowHistograms(baseline)
+	if err != nil {
+		return nil, err
+	}
+	after, queries, err := windowHistograms(current)
+	if err != nil {
+		return nil, err
+	}
+	
+	result := &WindowComparison{
+		Baseline:    baseline,
+		Current:     current,
+		Threshold:   threshold,
+		Patterns:    []PatternComparison{},
+		Regressions: []PatternComparison{},
+		GeneratedAt: time.Now(),
+	}
+	for id, cur := range after {
+		base, ok := before[id]
+		if !ok {
+			continue
+		}
+		c := PatternComparison{
+			ID:              id,
+			NormalizedQuery: queries[id],
+			BaselineCount:   base.Count(),
+			CurrentCount:    cur.Count(),
+			BaselineP95:     base.Quantile(0.95),
+			CurrentP95:      cur.Quantile(0.95),
+		}
+		if c.BaselineP95 > 0 {
+			c.Change = float64(c.CurrentP95-c.BaselineP95) / float64(c.BaselineP95)
+		}
+		c.Regressed = c.Change > threshold
+		result.Patterns = append(result.Patterns, c)
+		if c.Regressed {
+			result.Regressions = append(result.Regressions, c)
+		}
+	}
+	byChange := func(list []PatternComparison) {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Change != list[j].Change {
+				return list[i].Change > list[j].Change
+			}
+			return list[i].ID < list[j].ID
+		})
+	}
+	byChange(result.Patterns)
+	byChange(result.Regressions)
+	return result, nil
+}
+
 // generateRecommendations creates optimization recommendations
 func (a *SlowQueryAnalyzer) generateRecommendations(records []*SlowQueryRecord, patterns []*QueryPattern) []string {
 	var recommendations []string
@@ -231,6 +394,17 @@ func (a *SlowQueryAnalyzer) generateRecommendations(records []*SlowQueryRecord,
 		return recommendations
 	}
 	
+	// Latest captured plan per pattern
+	plans := make(map[string]*ExplainPlan)
+	latest := make(map[string]time.Time)
+	for _, record := range records {
+		id := patternID(record)
+		if record.Plan != nil && !record.Timestamp.Before(latest[id]) {
+			plans[id] = record.Plan
+			latest[id] = record.Timestamp
+		}
+	}
+	
 	// Analyze patterns for common issues
 	for _, pattern := range patterns {
 		if pattern.Count > 10 && pattern.AverageDuration > 500*time.Millisecond {
@@ -239,6 +413,17 @@ func (a *SlowQueryAnalyzer) generateRecommendations(records []*SlowQueryRecord,
 					pattern.NormalizedQuery, pattern.Count, pattern.AverageDuration))
 		}
 		
+		// Prefer the captured execution plan over guessing from the query text
+		id := pattern.ID
+		if id == "" {
+			id = QueryDigest(pattern.NormalizedQuery)
+		}
+		if plan := plans[id]; plan != nil {
+			recommendations = append(recommendations, planRecommendations(pattern.NormalizedQuery, plan)...)
+			continue
+		}
+		
+		// No plan captured (CaptureExplain disabled): fall back to keyword heuristics
 		if containsKeyword(pattern.NormalizedQuery, "SELECT *") {
 			recommendations = append(recommendations, 
 				"Avoid SELECT * queries. Specify only needed columns to reduce data transfer.")
@@ -276,6 +461,40 @@ func (a *SlowQueryAnalyzer) generateRecommendations(records []*SlowQueryRecord,
 	return recommendations
 }
 
+// planRecommendations creates recommendations from the execution plan of a pattern
+func planRecommendations(pattern string, plan *ExplainPlan) []string {
+	var recommendations []string
+	
+	for _, table := range plan.Tables {
+		switch {
+		case table.AccessType == "ALL" && len(table.PossibleKeys) == 0:
+			recommendations = append(recommendations,
+				fmt.Sprintf("Full table scan of %s (about %d rows) in: %s. Add an index on the filtered or joined columns.",
+					table.Table, table.RowsExamined, pattern))
+		case table.AccessType == "ALL":
+			recommendations = append(recommendations,
+				fmt.Sprintf("Full table scan of %s (about %d rows) although indexes %s are available in: %s. Check index selectivity or the condition.",
+					table.Table, table.RowsExamined, strings.Join(table.PossibleKeys, ", "), pattern))
+		case table.AccessType == "index" && table.Key != "":
+			recommendations = append(recommendations,
+				fmt.Sprintf("Full index scan of %s using %s (about %d rows) in: %s. Narrow the condition so the index can be searched.",
+					table.Table, table.Key, table.RowsExamined, pattern))
+		}
+	}
+	
+	if plan.UsingFilesort {
+		recommendations = append(recommendations,
+			fmt.Sprintf("Query sorts with filesort: %s. Add an index matching the ORDER BY columns.", pattern))
+	}
+	
+	if plan.UsingTemporary {
+		recommendations = append(recommendations,
+			fmt.Sprintf("Query uses a temporary table: %s. Review GROUP BY, DISTINCT and ORDER BY on different columns.", pattern))
+	}
+	
+	return recommendations
+}
+
 // Helper function to check if query contains a keyword
 func containsKeyword(query, keyword string) bool {
 	return len(query) >= len(keyword) && findSubstring(query, keyword)
@@ -79,19 +79,23 @@ func (s *FileSlowQueryStorage) loadRecords() error {
 
 // updatePattern updates the pattern statistics for a record
 func (s *FileSlowQueryStorage) updatePattern(record *SlowQueryRecord) {
-	pattern, exists := s.patterns[record.NormalizedQuery]
+	id := patternID(record)
+	pattern, exists := s.patterns[id]
 	if !exists {
 		pattern = &QueryPattern{
+			ID:              id,
 			NormalizedQuery: record.NormalizedQuery,
 			Count:           0,
 			TotalDuration:   0,
 			MaxDuration:     0,
 			Examples:        make([]string, 0, 3),
+			Latency:         NewLatencyHistogram(),
 		}
-		s.patterns[record.NormalizedQuery] = pattern
+		s.patterns[id] = pattern
 	}
 	
 	pattern.Count++
+	pattern.Latency.Record(record.Duration)
 	pattern.TotalDuration += record.Duration
 	pattern.AverageDuration = time.Duration(int64(pattern.TotalDuration) / pattern.Count)
 	pattern.LastSeen = record.Timestamp
@@ -231,7 +235,7 @@ func (s *FileSlowQueryStorage) matchesFilter(record *SlowQueryRecord, filter Slo
 		return false
 	}
 	
-	if filter.QueryPattern != "" && !stringContains(record.NormalizedQuery, filter.QueryPattern) {
+	if filter.QueryPattern != "" && !stringContains(record.NormalizedQuery, filter.QueryPattern) && patternID(record) != filter.QueryPattern {
 		return false
 	}
 	
@@ -310,6 +314,16 @@ func (s *FileSlowQueryStorage) GetPatterns(ctx context.Context, limit int) ([]*Q
 	return patterns, nil
 }
 
+// GetPattern returns a copy of the pattern with the given ID, or nil.
+func (s *FileSlowQueryStorage) GetPattern(ctx context.Context, id string) (*QueryPattern, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if pattern, ok := s.patterns[id]; ok {
+		return pattern.clone(), nil
+	}
+	return nil, nil
+}
+
 func (s *FileSlowQueryStorage) getTopPatterns(limit int) []QueryPattern {
 	patterns := make([]QueryPattern, 0, len(s.patterns))
 	for _, pattern := range s.patterns {
@@ -3,6 +3,8 @@ package ygggo_mysql
 import (
 	"context"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -294,6 +296,52 @@ func TestSlowQueryRecorder_Clear(t *testing.T) {
 	assert.Equal(t, int64(0), stats.TotalCount)
 }
 
+// countingStorage counts the records stored after it was closed
+type countingStorage struct {
+	*MemorySlowQueryStorage
+	closed     atomic.Bool
+	afterClose atomic.Int64
+}
+
+func (s *countingStorage) Store(ctx context.Context, record *SlowQueryRecord) error {
+	if s.closed.Load() {
+		s.afterClose.Add(1)
+	}
+	return s.MemorySlowQueryStorage.Store(ctx, record)
+}
+
+func (s *countingStorage) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func TestSlowQueryRecorder_CloseDropsLateRecords(t *testing.T) {
+	ctx := context.Background()
+	storage := &countingStorage{MemorySlowQueryStorage: NewMemorySlowQueryStorage(1000)}
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = time.Millisecond
+	recorder := NewSlowQueryRecorder(config, storage)
+
+	// Records racing with Close must neither panic nor reach the storage
+	// once it is closed
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_ = recorder.Record(ctx, "SELECT 1", nil, time.Second, nil)
+			}
+		}()
+	}
+	require.NoError(t, recorder.Close())
+	wg.Wait()
+	require.NoError(t, recorder.Record(ctx, "SELECT 1", nil, time.Second, nil))
+	assert.Equal(t, int64(0), storage.afterClose.Load())
+	require.NoError(t, recorder.Close())
+}
+
 func TestFileSlowQueryStorage(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,264 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StatementStatsConfig configures in-process statement statistics
+// (Config.StatementStats), similar to pg_stat_statements.
+type StatementStatsConfig struct {
+	// MaxStatements bounds the number of fingerprints tracked. When the
+	// table is full, the statement with the least total time is evicted.
+	MaxStatements int `json:"max_statements"`
+
+	// NormalizationMode selects the fingerprint used as key, see
+	// FingerprintQuery. "none" is treated as "basic".
+	NormalizationMode string `json:"normalization_mode"`
+}
+
+// DefaultStatementStatsConfig returns the default statement statistics
+// configuration.
+func DefaultStatementStatsConfig() StatementStatsConfig {
+	return StatementStatsConfig{
+		MaxStatements:     1000,
+		NormalizationMode: NormalizeBasic,
+	}
+}
+
+// StatementStat holds the statistics of one statement fingerprint.
+type StatementStat struct {
+	ID            string           `json:"id"`    // QueryDigest of Query
+	Query         string           `json:"query"` // Fingerprint
+	Calls         int64            `json:"calls"`
+	Errors        int64            `json:"errors"`
+	ErrorsByClass map[string]int64 `json:"errors_by_class,omitempty"` // keyed by ErrorClass.String()
+	TotalTime     time.Duration    `json:"total_time"`
+	MinTime       time.Duration    `json:"min_time"`
+	MaxTime       time.Duration    `json:"max_time"`
+	MeanTime      time.Duration    `json:"mean_time"`
+	P99Time       time.Duration    `json:"p99_time"`
+	RowsReturned  int64            `json:"rows_returned"`
+	RowsAffected  int64            `json:"rows_affected"`
+	FirstSeen     time.Time        `json:"first_seen"`
+	LastSeen      time.Time        `json:"last_seen"`
+}
+
+// statementEntry is the mutable state behind a StatementStat.
+type statementEntry struct {
+	stat    StatementStat
+	latency *LatencyHistogram
+}
+
+// StatementStatsCollector aggregates statistics for every executed
+// statement, keyed by fingerprint, in bounded memory. It is safe for
+// concurrent use.
+type StatementStatsCollector struct {
+	mu      sync.Mutex
+	config  StatementStatsConfig
+	entries map[string]*statementEntry
+	evicted int64
+	since   time.Time
+}
+
+// NewStatementStatsCollector creates a collector. Zero config fields take
+// their defaults.
+func NewStatementStatsCollector(config StatementStatsConfig) *StatementStatsCollector {
+	def := DefaultStatementStatsConfig()
+	if config.MaxStatements <= 0 {
+		config.MaxStatements = def.MaxStatements
+	}
+	if config.NormalizationMode == "" || config.NormalizationMode == NormalizeNone {
+		config.NormalizationMode = def.NormalizationMode
+	}
+	return &StatementStatsCollector{
+		config:  config,
+		entries: make(map[string]*statementEntry),
+		since:   time.Now(),
+	}
+}
+
+// Record adds one execution of query.
+func (c *StatementStatsCollector) Record(query string, duration time.Duration, rowsReturned, rowsAffected int64, err error) {
+	fingerprint := FingerprintQuery(query, c.config.NormalizationMode)
+	id := QueryDigest(fingerprint)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok {
+		if len(c.entries) >= c.config.MaxStatements {
+			c.evictLocked()
+		}
+		e = &statementEntry{
+			stat:    StatementStat{ID: id, Query: fingerprint, MinTime: duration, FirstSeen: now},
+			latency: NewLatencyHistogram(),
+		}
+		c.entries[id] = e
+	}
+	s := &e.stat
+	s.Calls++
+	s.TotalTime += duration
+	if duration < s.MinTime {
+		s.MinTime = duration
+	}
+	if duration > s.MaxTime {
+		s.MaxTime = duration
+	}
+	s.RowsReturned += rowsReturned
+	s.RowsAffected += rowsAffected
+	s.LastSeen = now
+	if err != nil {
+		s.Errors++
+		if s.ErrorsByClass == nil {
+			s.ErrorsByClass = make(map[string]int64)
+		}
+		s.ErrorsByClass[Classify(err).String()]++
+	}
+	e.latency.Record(duration)
+}
+
+// evictLocked drops the statement with the least total time.
+func (c *StatementStatsCollector) evictLocked() {
+	var victim string
+	var least time.Duration
+	for id, e := range c.entries {
+		if victim == "" || e.stat.TotalTime < least {
+			victim, least = id, e.stat.TotalTime
+		}
+	}
+	if victim != "" {
+		delete(c.entries, victim)
+		c.evicted++
+	}
+}
+
+// Stats returns the tracked statements ordered by total time, largest
+// first.
+func (c *StatementStatsCollector) Stats() []StatementStat {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.statsLocked()
+}
+
+func (c *StatementStatsCollector) statsLocked() []StatementStat {
+	out := make([]StatementStat, 0, len(c.entries))
+	for _, e := range c.entries {
+		s := e.stat
+		s.MeanTime = s.TotalTime / time.Duration(s.Calls)
+		s.P99Time = e.latency.Quantile(0.99)
+		if s.ErrorsByClass != nil {
+			s.ErrorsByClass = make(map[string]int64, len(e.stat.ErrorsByClass))
+			for k, v := range e.stat.ErrorsByClass {
+				s.ErrorsByClass[k] = v
+			}
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TotalTime != out[j].TotalTime {
+			return out[i].TotalTime > out[j].TotalTime
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Snapshot returns the current statistics with collection metadata.
+func (c *StatementStatsCollector) Snapshot() *StatementStatsSnapshot {
+	return c.snapshot(false)
+}
+
+// snapshot returns the current statistics, clearing them in the same
+// critical section when reset is set so that no execution is lost between
+// the two.
+func (c *StatementStatsCollector) snapshot(reset bool) *StatementStatsSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snap := &StatementStatsSnapshot{
+		Taken:      time.Now(),
+		Since:      c.since,
+		Evicted:    c.evicted,
+		Statements: c.statsLocked(),
+	}
+	if reset {
+		c.resetLocked()
+	}
+	return snap
+}
+
+// Reset clears all statistics.
+func (c *StatementStatsCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resetLocked()
+}
+
+func (c *StatementStatsCollector) resetLocked() {
+	c.entries = make(map[string]*statementEntry)
+	c.evicted = 0
+	c.since = time.Now()
+}
+
+// StatementStatsSnapshot is a point-in-time copy of the statement table.
+type StatementStatsSnapshot struct {
+	Taken      time.Time       `json:"taken"`   // When the snapshot was taken
+	Since      time.Time       `json:"since"`   // Start of collection (creation or last reset)
+	Evicted    int64           `json:"evicted"` // Statements dropped to stay within MaxStatements
+	Statements []StatementStat `json:"statements"`
+}
+
+// slowQueryRecord converts s to a record for a SlowQueryStorage, taken at
+// the given time. Duration is the total time and RowsSent the rows
+// returned; the full statistics are kept in Statement.
+func (s StatementStat) slowQueryRecord(taken time.Time) *SlowQueryRecord {
+	stat := s
+	return &SlowQueryRecord{
+		ID:              generateID(),
+		Query:           s.Query,
+		NormalizedQuery: s.Query,
+		PatternID:       s.ID,
+		Duration:        s.TotalTime,
+		RowsSent:        s.RowsReturned,
+		Timestamp:       taken,
+		Statement:       &stat,
+	}
+}
+
+// StatementStats returns the statistics of every statement executed
+// through the pool, ordered by total time. It returns nil unless
+// Config.StatementStats is set.
+func (p *Pool) StatementStats() []StatementStat {
+	if p == nil || p.stmtStats == nil {
+		return nil
+	}
+	return p.stmtStats.Stats()
+}
+
+// ResetStatementStats clears the statement statistics.
+func (p *Pool) ResetStatementStats() {
+	if p != nil && p.stmtStats != nil {
+		p.stmtStats.Reset()
+	}
+}
+
+// ExportStatementStats stores a snapshot of the statement statistics in
+// storage, one record per statement with the snapshot time as Timestamp,
+// so the slow query storages can keep them too. With reset the statistics
+// are cleared atomically with the snapshot.
+func (p *Pool) ExportStatementStats(ctx context.Context, storage SlowQueryStorage, reset bool) error {
+	if p == nil || p.stmtStats == nil {
+		return fmt.Errorf("statement statistics are not enabled")
+	}
+	snapshot := p.stmtStats.snapshot(reset)
+	for _, s := range snapshot.Statements {
+		if err := storage.Store(ctx, s.slowQueryRecord(snapshot.Taken)); err != nil {
+			return fmt.Errorf("failed to export statement statistics: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package ygggo_mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mysql "github.com/go-sql-driver/mysql"
+)
+
+// newStatsTestPool returns a pool with statement statistics on a fake
+// server that returns three rows and affects two for every statement, and
+// fails inserts into dup with a duplicate key error.
+func newStatsTestPool(t *testing.T, cfg StatementStatsConfig) *Pool {
+	t.Helper()
+	s := newFakeServer()
+	s.set("", serverResult{cols: []string{"x"}, rows: [][]driver.Value{{int64(2)}, {int64(1)}, {int64(0)}}, affected: 2})
+	s.set("INSERT INTO dup", serverResult{err: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}})
+	p := &Pool{stmtStats: NewStatementStatsCollector(cfg)}
+	db, err := openDB(context.Background(), Config{Driver: fakeServerDriver}, s.dsn(t), p)
+	if err != nil {
+		t.Fatalf("openDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	p.db = db
+	return p
+}
+
+func findStat(stats []StatementStat, query string) *StatementStat {
+	for i := range stats {
+		if stats[i].Query == query {
+			return &stats[i]
+		}
+	}
+	return nil
+}
+
+func TestStatementStats_RecordsEveryStatement(t *testing.T) {
+	p := newStatsTestPool(t, StatementStatsConfig{})
+	ctx := context.Background()
+	err := p.WithConn(ctx, func(c DatabaseConn) error {
+		for id := 1; id <= 2; id++ {
+			rs, err := c.Query(ctx, "SELECT x FROM t WHERE id = ?", id)
+			if err != nil {
+				return err
+			}
+			for rs.Next() {
+			}
+			rs.Close()
+		}
+		if _, err := c.Exec(ctx, "UPDATE t SET a = 1 WHERE id = 7"); err != nil {
+			return err
+		}
+		if _, err := c.Exec(ctx, "INSERT INTO dup VALUES (1)"); err == nil {
+			return errors.New("expected duplicate error")
+		}
+		// Prepared statements are recorded as well
+		cc := c.(*Conn)
+		cc.EnableStmtCache(4)
+		_, err := cc.ExecCached(ctx, "UPDATE t SET a = ? WHERE id = 8", 1)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := p.StatementStats()
+	sel := findStat(stats, "SELECT X FROM T WHERE ID = ?")
+	if sel == nil || sel.Calls != 2 || sel.RowsReturned != 6 || sel.Errors != 0 {
+		t.Fatalf("select stats: %+v", sel)
+	}
+	if sel.MinTime > sel.MaxTime || sel.TotalTime < sel.MaxTime || sel.MeanTime == 0 || sel.P99Time == 0 || sel.FirstSeen.IsZero() {
+		t.Fatalf("select timings: %+v", sel)
+	}
+	upd := findStat(stats, "UPDATE T SET A = ? WHERE ID = ?")
+	if upd == nil || upd.Calls != 2 || upd.RowsAffected != 4 {
+		t.Fatalf("update stats: %+v", upd)
+	}
+	dup := findStat(stats, "INSERT INTO DUP VALUES (?+)")
+	if dup == nil || dup.Errors != 1 || dup.ErrorsByClass["conflict"] != 1 {
+		t.Fatalf("error stats: %+v", dup)
+	}
+
+	p.ResetStatementStats()
+	if n := len(p.StatementStats()); n != 0 {
+		t.Fatalf("reset left %d statements", n)
+	}
+}
+
+func TestStatementStats_IgnoresHousekeeping(t *testing.T) {
+	p := newStatsTestPool(t, StatementStatsConfig{})
+	config := DefaultSlowQueryConfig()
+	config.Enabled = true
+	config.Threshold = 0
+	p.slowQueryRecorder = NewSlowQueryRecorder(config, NewMemorySlowQueryStorage(10))
+	defer p.slowQueryRecorder.Close()
+	ctx := context.Background()
+
+	if _, err := p.HealthCheck(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = p.DeepHealthCheck(ctx)
+	_, _ = p.ServerStatus(ctx)
+	_, _ = p.ProcessList(ctx)
+	_, _ = p.LockWaits(ctx)
+	_, _ = p.Explain(ctx, "SELECT x FROM t")
+	if stats := p.StatementStats(); len(stats) != 0 {
+		t.Fatalf("housekeeping recorded: %+v", stats)
+	}
+	if records, _ := p.slowQueryRecorder.GetRecords(ctx, SlowQueryFilter{}); len(records) != 0 {
+		t.Fatalf("housekeeping recorded as slow: %+v", records)
+	}
+
+	if _, err := p.getDB().ExecContext(ctx, "UPDATE t SET a = 1 WHERE id = 7"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := p.StatementStats(); len(stats) != 1 {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
+
+func TestStatementStats_EvictsLeastTotalTime(t *testing.T) {
+	c := NewStatementStatsCollector(StatementStatsConfig{MaxStatements: 2})
+	c.Record("SELECT 1 FROM a", 50*time.Millisecond, 1, 0, nil)
+	c.Record("SELECT 1 FROM b", time.Millisecond, 1, 0, nil)
+	c.Record("SELECT 1 FROM c", 10*time.Millisecond, 1, 0, nil)
+
+	snap := c.Snapshot()
+	if len(snap.Statements) != 2 || snap.Evicted != 1 {
+		t.Fatalf("snapshot: %+v", snap)
+	}
+	if snap.Statements[0].Query != "SELECT ? FROM A" || snap.Statements[1].Query != "SELECT ? FROM C" {
+		t.Fatalf("wrong statement evicted: %+v", snap.Statements)
+	}
+}
+
+func TestStatementStats_Export(t *testing.T) {
+	p := &Pool{stmtStats: NewStatementStatsCollector(StatementStatsConfig{})}
+	p.stmtStats.Record("SELECT 1", time.Millisecond, 1, 0, nil)
+	p.stmtStats.Record("SELECT 2", 2*time.Millisecond, 3, 0, errors.New("boom"))
+	ctx := context.Background()
+
+	mem := NewMemorySlowQueryStorage(10)
+	file, err := NewFileSlowQueryStorage(filepath.Join(t.TempDir(), "stats.jsonl"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	for _, storage := range []SlowQueryStorage{mem, file} {
+		if err := p.ExportStatementStats(ctx, storage, false); err != nil {
+			t.Fatal(err)
+		}
+		records, err := storage.GetRecords(ctx, SlowQueryFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 1 {
+			t.Fatalf("%T records: %+v", storage, records)
+		}
+		r := records[0]
+		if r.NormalizedQuery != "SELECT ?" || r.Duration != 3*time.Millisecond || r.RowsSent != 4 ||
+			r.Statement == nil || r.Statement.Calls != 2 || r.Statement.Errors != 1 {
+			t.Fatalf("%T record: %+v", storage, r)
+		}
+	}
+
+	if err := p.ExportStatementStats(ctx, mem, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.StatementStats()) != 0 {
+		t.Fatalf("export with reset should clear statistics")
+	}
+	if err := (&Pool{}).ExportStatementStats(ctx, mem, false); err == nil {
+		t.Fatalf("expected error when statistics are disabled")
+	}
+}
+
+func TestStatementStats_SnapshotAndResetLosesNothing(t *testing.T) {
+	c := NewStatementStatsCollector(StatementStatsConfig{})
+	const n = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < n; i++ {
+			c.Record("SELECT 1", time.Microsecond, 0, 0, nil)
+		}
+	}()
+	var calls int64
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		for _, s := range c.snapshot(true).Statements {
+			calls += s.Calls
+		}
+	}
+	if calls != n {
+		t.Fatalf("counted %d of %d calls", calls, n)
+	}
+}
+
+func TestApplyEnv_StatementStats(t *testing.T) {
+	t.Setenv("YGGGO_MYSQL_STMTSTATS_STATEMENT_STATS_MAX_STATEMENTS", "50")
+	var cfg Config
+	if err := applyEnvPrefix(&cfg, envPrefixFor("stmtstats")); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.StatementStats == nil || cfg.StatementStats.MaxStatements != 50 || cfg.StatementStats.NormalizationMode != NormalizeBasic {
+		t.Fatalf("statement stats config: %+v", cfg.StatementStats)
+	}
+}
@@ -103,8 +103,11 @@ func (tx *Tx) Exec(ctx context.Context, query string, args ...any) (sql.Result,
 	if tx == nil || tx.inner == nil {
 		return nil, sql.ErrTxDone
 	}
+	if err := tx.pool.checkQuery(ctx, query); err != nil {
+		return nil, err
+	}
 
-	return tx.inner.ExecContext(ctx, query, args...)
+	return tx.inner.ExecContext(ctx, tx.pool.tagQuery(ctx, query), args...)
 }
 
 // WithinTx executes a function within a database transaction with automatic management.
@@ -177,6 +180,9 @@ func (tx *Tx) Exec(ctx context.Context, query string, args ...any) (sql.Result,
 // The method automatically retries transactions that fail due to transient
 // errors such as deadlocks (MySQL error 1213) or lock timeouts (MySQL error 1205).
 // The retry policy is configurable via the pool's RetryPolicy configuration.
+// With Config.Hosts, a transaction that fails because its server cannot be
+// reached, or is read-only under FailoverPreferWritable, is run once more
+// after a successful failover to another host.
 //
 // Observability:
 //
@@ -200,14 +206,25 @@ func (tx *Tx) Exec(ctx context.Context, query string, args ...any) (sql.Result,
 // transactions simultaneously, and each will receive its own isolated
 // transaction context.
 func (p *Pool) WithinTx(ctx context.Context, fn func(DatabaseTx) error, opts ...any) error {
-	if p == nil || p.db == nil {
+	if p.getDB() == nil {
 		return errors.New("nil pool")
 	}
 
+	// Register with Shutdown, which cancels ctx if it has to force-close
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	track, err := p.inflight.add("tx", cancel)
+	if err != nil {
+		return err
+	}
+	defer p.inflight.done(track)
+
 	start := time.Now()
 
-	op := func() error {
-		tx, err := p.db.BeginTx(ctx, nil)
+	failedOver := false
+	var op func() error
+	op = func() error {
+		tx, db, err := p.beginTx(ctx)
 		if err != nil {
 			return err
 		}
@@ -220,10 +237,19 @@ func (p *Pool) WithinTx(ctx context.Context, fn func(DatabaseTx) error, opts ...
 			return nil
 		}
 		_ = tx.Rollback()
+		p.captureDeadlock(ctx, err)
+		if !failedOver && p.shouldFailover(err) && p.failoverFrom(ctx, db, err) == nil {
+			// The transaction died with the failed host; retryWithPolicy
+			// does not retry connection errors, so run it once more here.
+			// Later failures are left to the next transaction, so that no
+			// failover happens without a retry following it.
+			failedOver = true
+			return op()
+		}
 		return err
 	}
 
-	err := retryWithPolicy(ctx, p.retry, op, Classify)
+	err = retryWithPolicy(ctx, p.retry, op, Classify)
 
 	// Record duration
 	duration := time.Since(start)
@@ -240,6 +266,20 @@ func (p *Pool) WithinTx(ctx context.Context, fn func(DatabaseTx) error, opts ...
 	return err
 }
 
+// beginTx starts a transaction on the current handle, failing over once
+// when the server cannot be reached. It also returns the handle used.
+func (p *Pool) beginTx(ctx context.Context) (*sql.Tx, *sql.DB, error) {
+	db := p.getDB()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil && p.shouldFailover(err) {
+		if p.failoverFrom(ctx, db, err) == nil {
+			db = p.getDB()
+			tx, err = db.BeginTx(ctx, nil)
+		}
+	}
+	return tx, db, err
+}
+
 func isRetryable(err error) bool {
 	var me *mysqlMySQLError // local shim to avoid importing mysql here
 	if errors.As(err, &me) {
@@ -2,68 +2,131 @@ package ygggo_mysql
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 
 	ggl "github.com/yggai/ygggo_log"
 )
 
 // ygggoHandler bridges slog records to ygggo_log package-level logging.
-// It respects slog levels and forwards attributes as key-value pairs.
+// Records below the minimum level are dropped in Enabled, so callers skip
+// building them. Attributes are forwarded as key=value pairs; attributes
+// inside groups (from WithGroup or slog.Group) are qualified with the
+// dotted group path, e.g. "db.query=...".
 type ygggoHandler struct {
-	group string
-	attrs []slog.Attr
+	level  slog.Leveler
+	prefix string // dotted group path, with a trailing '.'
+	attrs  []any  // pre-formatted handler attributes
+	emit   func(level slog.Level, msg string, kvs ...any)
 }
 
 func newYgggoHandler() slog.Handler {
 	// Ensure env-driven logging is initialized. It is safe to call multiple times.
 	ggl.InitLogEnv()
-	return &ygggoHandler{}
+	return &ygggoHandler{level: ygggoLevel(ggl.LoadConfigFromEnv().Level), emit: emitYgggo}
 }
 
-func (h *ygggoHandler) Enabled(_ context.Context, _ slog.Level) bool { return true }
-
-func (h *ygggoHandler) Handle(_ context.Context, r slog.Record) error {
-	// Collect attributes: handler-level attrs + record attrs
-	kvs := make([]any, 0, len(h.attrs)*2+8)
-	appendAttr := func(a slog.Attr) {
-		// Best-effort: forward key and value as-is
-		kvs = append(kvs, a.Key, a.Value.Any())
-	}
-	for _, a := range h.attrs {
-		appendAttr(a)
-	}
-	r.Attrs(func(a slog.Attr) bool { appendAttr(a); return true })
-	if h.group != "" {
-		kvs = append(kvs, "group", h.group)
+// ygggoLevel maps a ygggo_log level to the slog level.
+func ygggoLevel(l ggl.LogLevel) slog.Level {
+	switch l {
+	case ggl.DebugLevel:
+		return slog.LevelDebug
+	case ggl.InfoLevel:
+		return slog.LevelInfo
+	case ggl.WarningLevel:
+		return slog.LevelWarn
+	case ggl.ErrorLevel:
+		return slog.LevelError
+	default:
+		return slog.LevelError + 4
 	}
+}
 
-	// Map slog level to ygggo_log level
+// emitYgggo writes a record with the ygggo_log function for its level.
+func emitYgggo(level slog.Level, msg string, kvs ...any) {
 	switch {
-	case r.Level >= slog.LevelError:
-		ggl.Error(r.Message, kvs...)
-	case r.Level >= slog.LevelWarn:
-		ggl.Warning(r.Message, kvs...)
-	case r.Level >= slog.LevelInfo:
-		ggl.Info(r.Message, kvs...)
+	case level >= slog.LevelError:
+		ggl.Error(msg, kvs...)
+	case level >= slog.LevelWarn:
+		ggl.Warning(msg, kvs...)
+	case level >= slog.LevelInfo:
+		ggl.Info(msg, kvs...)
 	default:
-		ggl.Debug(r.Message, kvs...)
+		ggl.Debug(msg, kvs...)
+	}
+}
+
+func (h *ygggoHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.level != nil {
+		min = h.level.Level()
+	}
+	return level >= min
+}
+
+func (h *ygggoHandler) Handle(_ context.Context, r slog.Record) error {
+	// Handler-level attrs first, then the record's, in order
+	kvs := make([]any, 0, len(h.attrs)+r.NumAttrs())
+	kvs = append(kvs, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		kvs = appendYgggoAttr(kvs, h.prefix, a)
+		return true
+	})
+	emit := h.emit
+	if emit == nil {
+		emit = emitYgggo
 	}
+	emit(r.Level, r.Message, kvs...)
 	return nil
 }
 
+// appendYgggoAttr appends a as "key=value" strings, flattening groups.
+func appendYgggoAttr(kvs []any, prefix string, a slog.Attr) []any {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		if len(group) == 0 {
+			return kvs
+		}
+		// An unnamed group is inlined
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range group {
+			kvs = appendYgggoAttr(kvs, prefix, ga)
+		}
+		return kvs
+	}
+	v := fmt.Sprint(a.Value.Any())
+	if strings.ContainsAny(v, " \t\n\"=") || v == "" {
+		v = strconv.Quote(v)
+	}
+	return append(kvs, prefix+a.Key+"="+v)
+}
+
 func (h *ygggoHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
 	nh := *h
-	nh.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	nh.attrs = append([]any(nil), h.attrs...)
+	for _, a := range attrs {
+		nh.attrs = appendYgggoAttr(nh.attrs, h.prefix, a)
+	}
 	return &nh
 }
 
 func (h *ygggoHandler) WithGroup(name string) slog.Handler {
-	nh := *h
-	if nh.group == "" {
-		nh.group = name
-	} else if name != "" {
-		nh.group = nh.group + "." + name
+	if name == "" {
+		return h
 	}
+	nh := *h
+	nh.prefix = h.prefix + name + "."
 	return &nh
 }
 